@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/authorization"
+)
+
+// AccessControl returns the role definitions and the role and attributes of every user for
+// the authorizer.
+// Users of the legacy AllowedUserIds and AdminUserIds lists get the editor and admin roles,
+// unless they are listed in Users.
+func (c *TalkToDBConfig) AccessControl() (map[string]authorization.RoleDefinition, map[int64]authorization.User) {
+	roles := make(map[string]authorization.RoleDefinition, len(c.Roles))
+	for name, role := range c.Roles {
+		definition := authorization.RoleDefinition{Connections: role.Connections}
+		if role.Permissions != nil {
+			definition.Permissions = make([]authorization.Permission, 0, len(role.Permissions))
+			for _, permission := range role.Permissions {
+				definition.Permissions = append(definition.Permissions, authorization.Permission(permission))
+			}
+		}
+		roles[name] = definition
+	}
+
+	users := make(map[int64]authorization.User, len(c.Users)+len(c.AllowedUserIds)+len(c.AdminUserIds))
+	for _, userID := range c.AllowedUserIds {
+		users[userID] = authorization.User{Role: authorization.RoleEditor}
+	}
+	for _, userID := range c.AdminUserIds {
+		users[userID] = authorization.User{Role: authorization.RoleAdmin}
+	}
+	for _, user := range c.Users {
+		users[user.ID] = authorization.User{Role: user.Role, Attributes: user.Attributes}
+	}
+
+	return roles, users
+}
+
+func (c *TalkToDBConfig) validateAccessControl(problems *ValidationErrors) {
+	connectionNames := make([]string, 0, len(c.Databases))
+	for _, database := range c.Databases {
+		connectionNames = append(connectionNames, database.Name)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.Roles)) {
+		role := c.Roles[name]
+		path := "roles." + name
+		if role.Permissions == nil && !authorization.IsBuiltinRole(name) {
+			problems.add(path+".permissions", "is required for roles other than viewer, analyst, editor and admin")
+		}
+		for i, permission := range role.Permissions {
+			if !slices.Contains(authorization.Permissions, authorization.Permission(permission)) {
+				problems.add(fmt.Sprintf("%s.permissions[%d]", path, i),
+					fmt.Sprintf("unknown permission %q, use one of %s", permission, joinQuoted(authorization.Permissions)))
+			}
+		}
+		for i, connection := range role.Connections {
+			if connection != authorization.AllConnections && !slices.Contains(connectionNames, connection) {
+				problems.add(fmt.Sprintf("%s.connections[%d]", path, i), fmt.Sprintf("unknown connection %q", connection))
+			}
+		}
+		for i, rule := range role.Deny {
+			if !isValidDenyRule(rule) {
+				problems.add(fmt.Sprintf("%s.deny[%d]", path, i), fmt.Sprintf("%q must be table, table.column or *.column", rule))
+			}
+		}
+		for _, table := range slices.Sorted(maps.Keys(role.RowFilters)) {
+			if table == "" || table == "*" || strings.ContainsAny(table, ". \t") {
+				problems.add(path+".rowFilters", fmt.Sprintf("%q must be a table name", table))
+			} else if strings.TrimSpace(role.RowFilters[table]) == "" {
+				problems.add(path+".rowFilters."+table, "is empty")
+			}
+		}
+		for _, setting := range slices.Sorted(maps.Keys(role.Settings)) {
+			// Only customized options, with a prefix like app., can be set to arbitrary values
+			prefix, name, found := strings.Cut(setting, ".")
+			if !found || prefix == "" || name == "" || strings.ContainsAny(setting, " \t") {
+				problems.add(path+".settings", fmt.Sprintf("%q must be a prefixed name like app.region", setting))
+			}
+		}
+	}
+
+	userIndexes := make(map[int64]int, len(c.Users))
+	for i, user := range c.Users {
+		path := fmt.Sprintf("users[%d]", i)
+		if user.ID <= 0 {
+			problems.add(path+".id", fmt.Sprintf("%d is not a valid user ID", user.ID))
+		} else if first, ok := userIndexes[user.ID]; ok {
+			problems.add(path+".id", fmt.Sprintf("user %d is already listed in users[%d]", user.ID, first))
+		} else {
+			userIndexes[user.ID] = i
+		}
+
+		if _, defined := c.Roles[user.Role]; !defined && !authorization.IsBuiltinRole(user.Role) {
+			problems.add(path+".role", fmt.Sprintf("unknown role %q", user.Role))
+		}
+	}
+}
+
+func isValidDenyRule(rule string) bool {
+	table, column, isColumn := strings.Cut(strings.TrimSpace(rule), ".")
+	if !isColumn {
+		return table != "" && table != "*" && !strings.ContainsAny(table, " \t")
+	}
+	return table != "" && column != "" && column != "*" && !strings.ContainsAny(table+column, ". \t")
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// dsnSchemes maps the schemes of connection URLs to drivers
+var dsnSchemes = map[string]Driver{
+	"postgres":    Postgres,
+	"postgresql":  Postgres,
+	"mysql":       MySQL,
+	"cockroach":   Cockroach,
+	"cockroachdb": Cockroach,
+}
+
+// applyDSN fills the fields that are not set explicitly from the connection URL
+func (d *Database) applyDSN() error {
+	dsn, err := url.Parse(d.DSN)
+	if err != nil {
+		// url.Error includes the URL with its password
+		return fmt.Errorf("invalid DSN: %w", redactedURLError(err))
+	}
+
+	driver, ok := dsnSchemes[strings.ToLower(dsn.Scheme)]
+	if !ok {
+		return fmt.Errorf("unsupported DSN scheme %q", dsn.Scheme)
+	}
+	if d.Driver == "" {
+		d.Driver = driver
+	}
+
+	setIfEmpty(&d.Host, dsn.Hostname())
+	setIfEmpty(&d.Port, dsn.Port())
+	setIfEmpty(&d.DBName, strings.TrimPrefix(dsn.Path, "/"))
+	if dsn.User != nil {
+		setIfEmpty(&d.User, dsn.User.Username())
+		password, _ := dsn.User.Password()
+		setIfEmpty(&d.Pass, password)
+	}
+
+	query := dsn.Query()
+	setIfEmpty(&d.SSLMode, query.Get("sslmode"))
+	setIfEmpty(&d.SSLRootCert, query.Get("sslrootcert"))
+	setIfEmpty(&d.SSLCert, query.Get("sslcert"))
+	setIfEmpty(&d.SSLKey, query.Get("sslkey"))
+	setIfEmpty(&d.ApplicationName, query.Get("application_name"))
+
+	for key, field := range map[string]*int{
+		"connect_timeout":   &d.ConnectTimeoutSeconds,
+		"max_open_conns":    &d.MaxOpenConns,
+		"max_idle_conns":    &d.MaxIdleConns,
+		"conn_max_lifetime": &d.ConnMaxLifetimeSeconds,
+	} {
+		value := query.Get(key)
+		if value == "" || *field != 0 {
+			continue
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid DSN parameter %s: %q is not a number", key, value)
+		}
+		*field = number
+	}
+
+	return nil
+}
+
+func setIfEmpty(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+func redactedURLError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configEnv     = "config"
+	configFileEnv = "config_file"
+	fileRefPrefix = "file://"
+)
+
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// LoadConfig reads the config from a YAML, JSON or TOML file chosen by its extension.
+// Without a path, the file named by the config_file environment variable is read, and
+// without that the config environment variable is parsed as JSON.
+// String values may reference secrets as ${ENV_VAR} or file://path.
+func LoadConfig(path string) (*TalkToDBConfig, error) {
+	if path == "" {
+		path = os.Getenv(configFileEnv)
+	}
+
+	var raw map[string]any
+	var err error
+	if path != "" {
+		raw, err = readConfigFile(path)
+	} else {
+		raw, err = decodeConfig([]byte(os.Getenv(configEnv)), ".json")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	resolved, err := resolveSecrets(raw, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config secrets: %w", err)
+	}
+
+	// Every format is decoded through JSON so that keys match field names case-insensitively
+	data, err := json.Marshal(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	var result TalkToDBConfig
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	problems := result.normalizeDatabases()
+	problems = append(problems, result.validate()...)
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	return &result, nil
+}
+
+func readConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeConfig(data, strings.ToLower(filepath.Ext(path)))
+}
+
+func decodeConfig(data []byte, extension string) (map[string]any, error) {
+	var raw map[string]any
+	var err error
+	switch extension {
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q, use .yaml, .json or .toml", extension)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, errors.New("config is empty")
+	}
+
+	return raw, nil
+}
+
+// resolveSecrets replaces ${ENV_VAR} references in every string value with the variable and
+// values of the form file://path with the trimmed content of the file
+func resolveSecrets(value any, path string) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			resolved, err := resolveSecrets(item, path+"."+key)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = resolved
+		}
+		return v, nil
+	case []any:
+		for i, item := range v {
+			resolved, err := resolveSecrets(item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+		return v, nil
+	case []map[string]any: // arrays of tables in TOML
+		for i, item := range v {
+			if _, err := resolveSecrets(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case string:
+		return resolveSecret(v, path)
+	default:
+		return v, nil
+	}
+}
+
+func resolveSecret(value string, path string) (string, error) {
+	if filePath, ok := strings.CutPrefix(value, fileRefPrefix); ok {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", strings.TrimPrefix(path, "."), err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	var missing []string
+	result := envRefPattern.ReplaceAllStringFunc(value, func(reference string) string {
+		name := envRefPattern.FindStringSubmatch(reference)[1]
+		envValue, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return envValue
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%s: environment variable %s is not set", strings.TrimPrefix(path, "."), strings.Join(missing, ", "))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/authorization"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/masking"
+)
+
+// MaskingRules returns the masking rules for the masker
+func (c *TalkToDBConfig) MaskingRules() []masking.Rule {
+	rules := make([]masking.Rule, 0, len(c.Masking))
+	for _, rule := range c.Masking {
+		rules = append(rules, masking.Rule{
+			Column:        rule.Column,
+			Kind:          masking.Kind(rule.Kind),
+			UnmaskedRoles: rule.UnmaskedRoles,
+		})
+	}
+	return rules
+}
+
+func (c *TalkToDBConfig) validateMasking(problems *ValidationErrors) {
+	for i, rule := range c.Masking {
+		path := fmt.Sprintf("masking[%d]", i)
+		kind := masking.Kind(rule.Kind)
+		switch {
+		case !slices.Contains(masking.Kinds, kind):
+			problems.add(path+".kind", fmt.Sprintf("unknown kind %q, use one of %s", rule.Kind, joinQuoted(masking.Kinds)))
+		case rule.Column == "" && !slices.Contains(masking.DetectableKinds, kind):
+			problems.add(path+".column", fmt.Sprintf("is required for kind %q, which can not be detected", rule.Kind))
+		}
+
+		for j, role := range rule.UnmaskedRoles {
+			if _, defined := c.Roles[role]; !defined && !authorization.IsBuiltinRole(role) {
+				problems.add(fmt.Sprintf("%s.unmaskedRoles[%d]", path, j), fmt.Sprintf("unknown role %q", role))
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/ratelimit"
+)
+
+// UsageLimits returns the rate limits and token budgets for the limiter
+func (c *TalkToDBConfig) UsageLimits() ratelimit.Limits {
+	return ratelimit.Limits{
+		MessagesPerMinute: c.RateLimits.MessagesPerMinute,
+		MessageBurst:      c.RateLimits.MessageBurst,
+		QueriesPerMinute:  c.RateLimits.QueriesPerMinute,
+		QueryBurst:        c.RateLimits.QueryBurst,
+		DailyTokens:       c.RateLimits.DailyTokens,
+		MonthlyTokens:     c.RateLimits.MonthlyTokens,
+	}
+}
+
+func (c *TalkToDBConfig) validateRateLimits(problems *ValidationErrors) {
+	limits := c.RateLimits
+	for _, field := range []struct {
+		path     string
+		negative bool
+	}{
+		{"rateLimits.messagesPerMinute", limits.MessagesPerMinute < 0},
+		{"rateLimits.messageBurst", limits.MessageBurst < 0},
+		{"rateLimits.queriesPerMinute", limits.QueriesPerMinute < 0},
+		{"rateLimits.queryBurst", limits.QueryBurst < 0},
+		{"rateLimits.dailyTokens", limits.DailyTokens < 0},
+		{"rateLimits.monthlyTokens", limits.MonthlyTokens < 0},
+	} {
+		if field.negative {
+			problems.add(field.path, "must not be negative")
+		}
+	}
+	if limits.DailyTokens > 0 && limits.MonthlyTokens > 0 && limits.DailyTokens > limits.MonthlyTokens {
+		problems.add("rateLimits.dailyTokens", "must not be more than monthlyTokens")
+	}
+}
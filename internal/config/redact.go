@@ -0,0 +1,42 @@
+package config
+
+import (
+	"net/url"
+)
+
+const redacted = "******"
+
+// Redacted returns a copy of the config with every secret masked, safe to be logged
+func (c TalkToDBConfig) Redacted() TalkToDBConfig {
+	c.CliBot.Token = redactSecret(c.CliBot.Token)
+	c.AvalAi.ApiKey = redactSecret(c.AvalAi.ApiKey)
+
+	databases := make([]Database, len(c.Databases))
+	for i, database := range c.Databases {
+		database.Pass = redactSecret(database.Pass)
+		database.DSN = redactDSN(database.DSN)
+		databases[i] = database
+	}
+	c.Databases = databases
+
+	return c
+}
+
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+func redactDSN(dsn string) string {
+	if dsn == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return redacted
+	}
+	return parsed.Redacted()
+}
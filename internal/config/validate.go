@@ -0,0 +1,182 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ValidationError is a problem of a single config field, identified by its JSON path
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+// ValidationErrors are all problems found in a config
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("invalid config, %d problem(s):", len(e)))
+	for _, problem := range e {
+		lines = append(lines, fmt.Sprintf("  %s: %s", problem.Path, problem.Message))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *ValidationErrors) add(path string, message string) {
+	*e = append(*e, ValidationError{Path: path, Message: message})
+}
+
+var (
+	validDrivers  = []Driver{Postgres, MySQL, Cockroach}
+	validSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+)
+
+// validate checks every field and returns all problems at once
+func (c *TalkToDBConfig) validate() ValidationErrors {
+	var problems ValidationErrors
+
+	if strings.TrimSpace(c.CliBot.Token) == "" {
+		problems.add("cliBot.token", "is required")
+	}
+	if strings.TrimSpace(c.AvalAi.ApiKey) == "" {
+		problems.add("avalAi.apiKey", "is required")
+	}
+
+	if len(c.Databases) == 0 {
+		problems.add("databases", "at least one database is required")
+	}
+	names := make(map[string]int, len(c.Databases))
+	for i, database := range c.Databases {
+		path := fmt.Sprintf("databases[%d]", i)
+		database.validate(path, &problems)
+
+		if first, ok := names[database.Name]; ok && database.Name != "" {
+			problems.add(path+".name", fmt.Sprintf("%q is already used by databases[%d]", database.Name, first))
+		} else {
+			names[database.Name] = i
+		}
+	}
+
+	validateUserIds("allowedUserIds", c.AllowedUserIds, &problems)
+	validateUserIds("adminUserIds", c.AdminUserIds, &problems)
+	c.validateAccessControl(&problems)
+	c.validateMasking(&problems)
+	c.validateRateLimits(&problems)
+
+	switch c.Repo.Type {
+	case "", JsonRepo, SqliteRepo:
+	default:
+		problems.add("repo.type", fmt.Sprintf("unknown repository type %q, use %q or %q", c.Repo.Type, JsonRepo, SqliteRepo))
+	}
+
+	switch c.Audit.Type {
+	case "", JsonlAudit, SqliteAudit:
+	default:
+		problems.add("audit.type", fmt.Sprintf("unknown audit log type %q, use %q or %q", c.Audit.Type, JsonlAudit, SqliteAudit))
+	}
+	if c.Audit.MaxSizeMB < 0 {
+		problems.add("audit.maxSizeMB", "must not be negative")
+	}
+	if c.Audit.MaxFiles < 0 {
+		problems.add("audit.maxFiles", "must not be negative")
+	}
+
+	return problems
+}
+
+func (d Database) validate(path string, problems *ValidationErrors) {
+	if d.Name == "" {
+		problems.add(path+".name", "is required")
+	}
+	if !slices.Contains(validDrivers, d.Driver) {
+		problems.add(path+".driver", fmt.Sprintf("unknown driver %q, use one of %s", d.Driver, joinQuoted(validDrivers)))
+	}
+	if d.Host == "" {
+		problems.add(path+".host", "is required")
+	}
+	if d.Port == "" {
+		problems.add(path+".port", "is required")
+	} else if port, err := strconv.Atoi(d.Port); err != nil || port < 1 || port > 65535 {
+		problems.add(path+".port", fmt.Sprintf("%q is not a valid port", d.Port))
+	}
+	if d.User == "" {
+		problems.add(path+".user", "is required")
+	}
+	if d.DBName == "" {
+		problems.add(path+".dbName", "is required")
+	}
+
+	if d.SSLMode != "" && !slices.Contains(validSSLModes, d.SSLMode) {
+		problems.add(path+".sslMode", fmt.Sprintf("unknown sslmode %q, use one of %s", d.SSLMode, joinQuoted(validSSLModes)))
+	}
+	if d.MaxEstimatedRows < 0 {
+		problems.add(path+".maxEstimatedRows", "must not be negative")
+	}
+	if d.MaxEstimatedCost < 0 {
+		problems.add(path+".maxEstimatedCost", "must not be negative")
+	} else if d.MaxEstimatedCost > 0 && d.Driver == Cockroach {
+		problems.add(path+".maxEstimatedCost", "CockroachDB plans have no cost, use maxEstimatedRows")
+	}
+	switch d.ExpensiveQueries {
+	case "", RejectExpensiveQueries, ConfirmExpensiveQueries:
+	default:
+		problems.add(path+".expensiveQueries", fmt.Sprintf("unknown value %q, use %q or %q", d.ExpensiveQueries, RejectExpensiveQueries, ConfirmExpensiveQueries))
+	}
+
+	if (d.SSLCert == "") != (d.SSLKey == "") {
+		problems.add(path+".sslKey", "sslCert and sslKey must be set together")
+	}
+	for _, file := range []struct {
+		field string
+		path  string
+	}{
+		{"sslRootCert", d.SSLRootCert},
+		{"sslCert", d.SSLCert},
+		{"sslKey", d.SSLKey},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			problems.add(path+"."+file.field, fmt.Sprintf("can not read %s: %v", file.path, err))
+		}
+	}
+
+	for _, number := range []struct {
+		field string
+		value int
+	}{
+		{"connectTimeoutSeconds", d.ConnectTimeoutSeconds},
+		{"maxOpenConns", d.MaxOpenConns},
+		{"maxIdleConns", d.MaxIdleConns},
+		{"connMaxLifetimeSeconds", d.ConnMaxLifetimeSeconds},
+		{"resultCacheTTLSeconds", d.ResultCacheTTLSeconds},
+	} {
+		if number.value < 0 {
+			problems.add(path+"."+number.field, "must not be negative")
+		}
+	}
+	if d.MaxOpenConns > 0 && d.MaxIdleConns > d.MaxOpenConns {
+		problems.add(path+".maxIdleConns", "must not be greater than maxOpenConns")
+	}
+}
+
+func validateUserIds(path string, userIds []int64, problems *ValidationErrors) {
+	for i, userId := range userIds {
+		if userId <= 0 {
+			problems.add(fmt.Sprintf("%s[%d]", path, i), fmt.Sprintf("%d is not a valid user ID", userId))
+		}
+	}
+}
+
+func joinQuoted[T ~string](values []T) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = strconv.Quote(string(value))
+	}
+	return strings.Join(quoted, ", ")
+}
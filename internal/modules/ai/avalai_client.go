@@ -2,72 +2,175 @@ package ai
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
 	"github.com/openai/openai-go/packages/param"
+	"github.com/openai/openai-go/shared"
 )
 
+const defaultModel = "gpt-4o"
+
 type avalaiClient struct {
 	client openai.Client
+	model  string
 }
 
 func newAvalaiClient(apiKey string) *avalaiClient {
 	client := openai.NewClient(option.WithAPIKey(apiKey), option.WithBaseURL("https://api.avalai.ir/v1"))
-	return &avalaiClient{client: client}
+	return &avalaiClient{client: client, model: defaultModel}
 }
 
-func (c *avalaiClient) ask(dbContext string, question string) (string, error) {
-	ctx := context.Background()
-
+func (c *avalaiClient) ask(request QueryRequest) (string, Usage, error) {
 	// Build the system message with database context
 	systemMessage := `You are a SQL query generator. Given a database schema and a natural language question, generate a valid SQL query.
 Return ONLY the SQL query without any explanations, markdown formatting, or additional text.
 If the question cannot be answered with the given schema, return an empty string.`
+	if request.Write {
+		systemMessage = `You are a SQL statement generator. Given a database schema and a natural language request to change data, generate a single INSERT, UPDATE or DELETE statement.
+Return ONLY the SQL statement without any explanations, markdown formatting, or additional text.
+Never change the schema and never change more rows than the request asks for.
+If the request cannot be done with a single statement on the given schema, return an empty string.`
+	}
 
 	// Build the user message with database context and question
-	userMessage := fmt.Sprintf("Database Schema:\n%s\n\nQuestion: %s\n\nGenerate a SQL query:", dbContext, question)
+	userMessage := fmt.Sprintf("Database Schema:\n%s\n\n%sQuestion: %s\n\nGenerate a SQL query:", request.Schema, glossaryContext(request.Glossary), request.Question)
+
+	// Verified examples are sent as previous turns of the conversation (few-shot demonstrations).
+	// They are all queries, so they are left out when asking for a write.
+	examples := request.Examples
+	if request.Write {
+		examples = nil
+	}
+	messages := []openai.ChatCompletionMessageParamUnion{newSystemMessage(systemMessage)}
+	for _, example := range examples {
+		messages = append(messages,
+			newUserMessage(fmt.Sprintf("Question: %s\n\nGenerate a SQL query:", example.Question)),
+			newAssistantMessage(example.SQL),
+		)
+	}
+	messages = append(messages, newUserMessage(userMessage))
+
+	content, usage, err := c.complete(messages, openai.ChatCompletionNewParamsResponseFormatUnion{})
+	if err != nil {
+		return "", usage, err
+	}
+
+	sqlQuery := strings.TrimSpace(content)
+
+	// Remove markdown code blocks if present
+	sqlQuery = strings.TrimPrefix(sqlQuery, "```sql")
+	sqlQuery = strings.TrimPrefix(sqlQuery, "```")
+	sqlQuery = strings.TrimSuffix(sqlQuery, "```")
+	sqlQuery = strings.TrimSpace(sqlQuery)
+
+	return sqlQuery, usage, nil
+}
+
+// suggestDescriptions asks the model to describe a table and its columns based on the
+// table structure and a few sample rows
+func (c *avalaiClient) suggestDescriptions(tableContext string, sampleRows string) (DescriptionSuggestions, Usage, error) {
+	systemMessage := `You are a data catalog assistant. Given a table structure and sample rows, write short, precise descriptions of the table and of each column for analysts writing SQL.
+Describe the meaning of the data, units and notable value formats. Do not invent relationships that are not supported by the structure or the samples.
+Return ONLY a JSON object of the form {"table": "<table description>", "columns": {"<column name>": "<column description>"}}.`
+
+	userMessage := fmt.Sprintf("Table Structure:\n%s\n\nSample Rows:\n%s", tableContext, sampleRows)
+
+	messages := []openai.ChatCompletionMessageParamUnion{newSystemMessage(systemMessage), newUserMessage(userMessage)}
+	content, usage, err := c.complete(messages, openai.ChatCompletionNewParamsResponseFormatUnion{
+		OfJSONObject: &shared.ResponseFormatJSONObjectParam{},
+	})
+	if err != nil {
+		return DescriptionSuggestions{}, usage, err
+	}
+
+	var suggestions DescriptionSuggestions
+	if err := json.Unmarshal([]byte(content), &suggestions); err != nil {
+		return DescriptionSuggestions{}, usage, fmt.Errorf("failed to parse description suggestions: %w", err)
+	}
+
+	return suggestions, usage, nil
+}
+
+// complete sends the messages to the model and returns the content of the first choice and
+// the tokens the completion used
+func (c *avalaiClient) complete(messages []openai.ChatCompletionMessageParamUnion, responseFormat openai.ChatCompletionNewParamsResponseFormatUnion) (string, Usage, error) {
+	ctx := context.Background()
 
 	// Create the chat completion request
 	chatCompletion, err := c.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-		Model: "gpt-4o",
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			{
-				OfSystem: &openai.ChatCompletionSystemMessageParam{
-					Content: openai.ChatCompletionSystemMessageParamContentUnion{
-						OfString: param.Opt[string]{Value: systemMessage},
-					},
-				},
-			},
-			{
-				OfUser: &openai.ChatCompletionUserMessageParam{
-					Content: openai.ChatCompletionUserMessageParamContentUnion{
-						OfString: param.Opt[string]{Value: userMessage},
-					},
-				},
-			},
-		},
-		Temperature: param.Opt[float64]{Value: 0.3}, // Lower temperature for more deterministic SQL generation
+		Model:          c.model,
+		Messages:       messages,
+		ResponseFormat: responseFormat,
+		Temperature:    param.Opt[float64]{Value: 0.3}, // Lower temperature for more deterministic SQL generation
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("failed to create chat completion: %w", err)
+		return "", Usage{}, fmt.Errorf("failed to create chat completion: %w", err)
 	}
 
-	// Extract the SQL query from the response
+	usage := Usage{
+		PromptTokens:     chatCompletion.Usage.PromptTokens,
+		CompletionTokens: chatCompletion.Usage.CompletionTokens,
+		TotalTokens:      chatCompletion.Usage.TotalTokens,
+	}
+
+	// Extract the content from the response
 	if len(chatCompletion.Choices) == 0 {
-		return "", fmt.Errorf("no choices in chat completion response")
+		return "", usage, fmt.Errorf("no choices in chat completion response")
 	}
 
-	sqlQuery := strings.TrimSpace(chatCompletion.Choices[0].Message.Content)
+	return chatCompletion.Choices[0].Message.Content, usage, nil
+}
 
-	// Remove markdown code blocks if present
-	sqlQuery = strings.TrimPrefix(sqlQuery, "```sql")
-	sqlQuery = strings.TrimPrefix(sqlQuery, "```")
-	sqlQuery = strings.TrimSuffix(sqlQuery, "```")
-	sqlQuery = strings.TrimSpace(sqlQuery)
+func newSystemMessage(content string) openai.ChatCompletionMessageParamUnion {
+	return openai.ChatCompletionMessageParamUnion{
+		OfSystem: &openai.ChatCompletionSystemMessageParam{
+			Content: openai.ChatCompletionSystemMessageParamContentUnion{
+				OfString: param.Opt[string]{Value: content},
+			},
+		},
+	}
+}
+
+func newUserMessage(content string) openai.ChatCompletionMessageParamUnion {
+	return openai.ChatCompletionMessageParamUnion{
+		OfUser: &openai.ChatCompletionUserMessageParam{
+			Content: openai.ChatCompletionUserMessageParamContentUnion{
+				OfString: param.Opt[string]{Value: content},
+			},
+		},
+	}
+}
+
+func newAssistantMessage(content string) openai.ChatCompletionMessageParamUnion {
+	return openai.ChatCompletionMessageParamUnion{
+		OfAssistant: &openai.ChatCompletionAssistantMessageParam{
+			Content: openai.ChatCompletionAssistantMessageParamContentUnion{
+				OfString: param.Opt[string]{Value: content},
+			},
+		},
+	}
+}
+
+// glossaryContext renders the business glossary section of the user message
+func glossaryContext(glossary []GlossaryEntry) string {
+	if len(glossary) == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Business Glossary:\n")
+	for _, entry := range glossary {
+		builder.WriteString(fmt.Sprintf("- %s: %s\n", entry.Term, entry.Definition))
+		if entry.SQL != "" {
+			builder.WriteString(fmt.Sprintf("  SQL: %s\n", entry.SQL))
+		}
+	}
+	builder.WriteString("\n")
 
-	return sqlQuery, nil
+	return builder.String()
 }
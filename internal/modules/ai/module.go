@@ -1,18 +1,81 @@
 package ai
 
-import "log"
+import (
+	"log"
+	"sync"
+)
 
 type AIModule struct {
+	mu           sync.RWMutex
 	avalaiClient *avalaiClient
+	usage        UsageRecorder
 }
 
-func NewAIModule(apikey string) *AIModule {
-	return &AIModule{avalaiClient: newAvalaiClient(apikey)}
+// Usage is the number of tokens a completion used
+type Usage struct {
+	PromptTokens     int64
+	CompletionTokens int64
+	TotalTokens      int64
 }
 
-func (m *AIModule) GetQuery(databaseContext string, nlq string) string {
-	log.Println("NLQ", nlq)
-	ask, err := m.avalaiClient.ask(databaseContext, nlq)
+// UsageRecorder is told the tokens spent on behalf of each user, see ratelimit.Limiter
+type UsageRecorder interface {
+	RecordTokens(userID int64, tokens int64)
+}
+
+func NewAIModule(apikey string, usage UsageRecorder) *AIModule {
+	return &AIModule{avalaiClient: newAvalaiClient(apikey), usage: usage}
+}
+
+// SetApiKey replaces the client with one using the new API key. Requests already sent
+// keep using the previous client.
+func (m *AIModule) SetApiKey(apikey string) {
+	client := newAvalaiClient(apikey)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.avalaiClient = client
+}
+
+func (m *AIModule) client() *avalaiClient {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.avalaiClient
+}
+
+// GlossaryEntry is a business term the model should take into account when generating a query
+type GlossaryEntry struct {
+	Term       string
+	Definition string
+	SQL        string
+}
+
+// Example is a verified question and SQL pair used as a few-shot demonstration
+type Example struct {
+	Question string
+	SQL      string
+}
+
+type QueryRequest struct {
+	// UserID is the user the question is asked for, who is charged for the tokens
+	UserID   int64
+	Schema   string
+	Glossary []GlossaryEntry
+	Examples []Example
+	Question string
+	// Write asks for a single INSERT, UPDATE or DELETE statement instead of a query
+	Write bool
+}
+
+// Model returns the name of the model used to generate queries
+func (m *AIModule) Model() string {
+	return m.client().model
+}
+
+func (m *AIModule) GetQuery(request QueryRequest) string {
+	log.Println("NLQ", request.Question)
+	ask, usage, err := m.client().ask(request)
+	m.recordUsage(request.UserID, usage)
 	if err != nil {
 		log.Println("failed to ask:", err)
 		return ""
@@ -21,3 +84,27 @@ func (m *AIModule) GetQuery(databaseContext string, nlq string) string {
 	log.Println("ask result:", ask)
 	return ask
 }
+
+// DescriptionSuggestions holds the proposed descriptions of a table and its columns
+type DescriptionSuggestions struct {
+	Table   string            `json:"table"`
+	Columns map[string]string `json:"columns"`
+}
+
+// SuggestDescriptions asks for descriptions of a table on behalf of the user
+func (m *AIModule) SuggestDescriptions(userID int64, tableContext string, sampleRows string) (DescriptionSuggestions, error) {
+	suggestions, usage, err := m.client().suggestDescriptions(tableContext, sampleRows)
+	m.recordUsage(userID, usage)
+	if err != nil {
+		log.Println("failed to suggest descriptions:", err)
+		return DescriptionSuggestions{}, err
+	}
+
+	return suggestions, nil
+}
+
+func (m *AIModule) recordUsage(userID int64, usage Usage) {
+	if m.usage != nil && usage.TotalTokens > 0 {
+		m.usage.RecordTokens(userID, usage.TotalTokens)
+	}
+}
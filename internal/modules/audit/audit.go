@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"strings"
+	"time"
+)
+
+// Entry is the record of one question and the outcome of the query generated for it
+type Entry struct {
+	Time       time.Time     `json:"time"`
+	UserID     int64         `json:"user_id"`
+	Role       string        `json:"role"`
+	Connection string        `json:"connection"`
+	DatabaseID int           `json:"database_id"`
+	Question   string        `json:"question"`
+	SQL        string        `json:"sql,omitempty"`
+	Rows       int           `json:"rows"`
+	Duration   time.Duration `json:"duration_ns"`
+	Error      string        `json:"error,omitempty"`
+	// Decisions are what the access policy of the role did to the query, e.g. that it
+	// rejected it or filtered the rows of a table
+	Decisions []string `json:"decisions,omitempty"`
+}
+
+// Filter selects entries to show. Zero fields match every entry.
+type Filter struct {
+	UserID int64
+	// Text is searched case-insensitively in the question, SQL and error
+	Text string
+	// Limit is the number of most recent matching entries returned
+	Limit int
+}
+
+func (f Filter) matches(entry Entry) bool {
+	if f.UserID != 0 && entry.UserID != f.UserID {
+		return false
+	}
+	if f.Text == "" {
+		return true
+	}
+
+	text := strings.ToLower(f.Text)
+	for _, field := range []string{entry.Question, entry.SQL, entry.Error} {
+		if strings.Contains(strings.ToLower(field), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Log is an append-only trail of entries
+type Log interface {
+	Record(entry Entry) error
+	// Search returns the most recent entries matching the filter, newest first
+	Search(filter Filter) ([]Entry, error)
+	Close() error
+}
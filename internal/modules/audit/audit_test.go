@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestLogs(t *testing.T) map[string]Log {
+	dir := t.TempDir()
+	sqliteLog, err := NewSqliteLog(filepath.Join(dir, "audit.db"))
+	if err != nil {
+		t.Fatalf("failed to create sqlite log: %v", err)
+	}
+	t.Cleanup(func() { sqliteLog.Close() })
+
+	fileLog, err := NewFileLog(filepath.Join(dir, "audit.jsonl"), 1024*1024, 2)
+	if err != nil {
+		t.Fatalf("failed to create file log: %v", err)
+	}
+	t.Cleanup(func() { fileLog.Close() })
+
+	return map[string]Log{
+		"file":   fileLog,
+		"sqlite": sqliteLog,
+	}
+}
+
+func TestLog(t *testing.T) {
+	for name, auditLog := range newTestLogs(t) {
+		t.Run(name, func(t *testing.T) {
+			entries := []Entry{
+				{UserID: 1, Question: "how many users?", SQL: "SELECT count(*) FROM users", Rows: 1},
+				{UserID: 2, Question: "list salaries", Error: "the query is not allowed for your role", Decisions: []string{"rejected"}},
+				{UserID: 1, Question: "total payments", SQL: "SELECT sum(amount) FROM payments", Rows: 1, Duration: time.Second},
+			}
+			for i, entry := range entries {
+				entry.Time = time.UnixMicro(int64(i + 1))
+				if err := auditLog.Record(entry); err != nil {
+					t.Fatalf("Record: %v", err)
+				}
+			}
+
+			recent, err := auditLog.Search(Filter{UserID: 1, Limit: 1})
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if len(recent) != 1 || recent[0].Question != "total payments" || recent[0].Duration != time.Second {
+				t.Fatalf("unexpected entries: %+v", recent)
+			}
+
+			denied, err := auditLog.Search(Filter{Text: "NOT ALLOWED"})
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if len(denied) != 1 || denied[0].UserID != 2 || len(denied[0].Decisions) != 1 {
+				t.Fatalf("unexpected entries: %+v", denied)
+			}
+		})
+	}
+}
+
+func TestFileLog_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	fileLog, err := NewFileLog(path, 200, 1)
+	if err != nil {
+		t.Fatalf("NewFileLog: %v", err)
+	}
+	defer fileLog.Close()
+
+	for i := range 5 {
+		if err := fileLog.Record(Entry{UserID: int64(i + 1), Question: "how many users signed up today?"}); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Fatalf("expected a rotated file: %v", err)
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Fatalf("expected a single rotated file, got %v", err)
+	}
+
+	entries, err := fileLog.Search(Filter{})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(entries) == 0 || len(entries) == 5 || entries[0].UserID != 5 {
+		t.Fatalf("expected the newest entries of the kept files, got %+v", entries)
+	}
+}
+
+func TestFileLog_RotateFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	fileLog, err := NewFileLog(path, 200, 1)
+	if err != nil {
+		t.Fatalf("NewFileLog: %v", err)
+	}
+	defer fileLog.Close()
+
+	// A non-empty directory in the way of the rotated file makes the rotation fail
+	if err := os.MkdirAll(filepath.Join(path+".1", "blocked"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	entry := Entry{UserID: 1, Question: "how many users signed up today?"}
+	var rotateErr error
+	for range 5 {
+		if rotateErr = fileLog.Record(entry); rotateErr != nil {
+			break
+		}
+	}
+	if rotateErr == nil {
+		t.Fatalf("expected the rotation to fail")
+	}
+
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileLog.Record(entry); err != nil {
+		t.Fatalf("expected recording to work once the rotation can succeed, got %v", err)
+	}
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Fatalf("expected a rotated file: %v", err)
+	}
+}
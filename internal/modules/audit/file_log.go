@@ -0,0 +1,175 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+)
+
+// FileLog appends entries as JSON lines to a file. When the file would grow past maxSize it
+// is renamed to path.1, shifting older files up to path.<maxFiles>, and a new file is started.
+type FileLog struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func NewFileLog(path string, maxSize int64, maxFiles int) (*FileLog, error) {
+	l := &FileLog{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *FileLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to read audit log size: %w", err)
+	}
+
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+func (l *FileLog) Record(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// rotate starts a new file. If that fails, the current file is reopened so that the next
+// entry can try again.
+func (l *FileLog) rotate() error {
+	err := l.shiftFiles()
+	if err != nil {
+		if openErr := l.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+		return err
+	}
+
+	return l.open()
+}
+
+func (l *FileLog) shiftFiles() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+
+	if l.maxFiles > 0 {
+		// The oldest file is overwritten by the one before it
+		for i := l.maxFiles - 1; i >= 1; i-- {
+			err := os.Rename(l.rotatedPath(i), l.rotatedPath(i+1))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to rotate audit log: %w", err)
+			}
+		}
+		if err := os.Rename(l.path, l.rotatedPath(1)); err != nil {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	} else if err := os.Remove(l.path); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+
+	return nil
+}
+
+func (l *FileLog) rotatedPath(index int) string {
+	return fmt.Sprintf("%s.%d", l.path, index)
+}
+
+// Search reads the current file and then the rotated ones, newest first
+func (l *FileLog) Search(filter Filter) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var result []Entry
+	paths := []string{l.path}
+	for i := 1; i <= l.maxFiles; i++ {
+		paths = append(paths, l.rotatedPath(i))
+	}
+	for _, path := range paths {
+		entries, err := readEntries(path)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range slices.Backward(entries) {
+			if !filter.matches(entry) {
+				continue
+			}
+			result = append(result, entry)
+			if filter.Limit > 0 && len(result) == filter.Limit {
+				return result, nil
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// readEntries reads the entries of a file in the order they were written. Lines that can not
+// be decoded, like one cut short by a crash, are skipped.
+func readEntries(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log %s: %w", path, err)
+	}
+
+	return entries, nil
+}
+
+func (l *FileLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the audit table. Triggers keep entries from being changed or deleted
+// through the bot's connection.
+const sqliteSchema = `
+	CREATE TABLE IF NOT EXISTS audit_entries (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		time        INTEGER NOT NULL,
+		user_id     INTEGER NOT NULL,
+		role        TEXT    NOT NULL,
+		connection  TEXT    NOT NULL,
+		database_id INTEGER NOT NULL,
+		question    TEXT    NOT NULL,
+		sql         TEXT    NOT NULL,
+		rows        INTEGER NOT NULL,
+		duration_ns INTEGER NOT NULL,
+		error       TEXT    NOT NULL,
+		decisions   TEXT    NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS audit_entries_user_id ON audit_entries (user_id);
+
+	CREATE TRIGGER IF NOT EXISTS audit_entries_no_update BEFORE UPDATE ON audit_entries
+	BEGIN
+		SELECT RAISE(ABORT, 'audit entries are append-only');
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS audit_entries_no_delete BEFORE DELETE ON audit_entries
+	BEGIN
+		SELECT RAISE(ABORT, 'audit entries are append-only');
+	END;
+`
+
+// SqliteLog stores entries in the audit_entries table of a SQLite database
+type SqliteLog struct {
+	db *sql.DB
+}
+
+func NewSqliteLog(filePath string) (*SqliteLog, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)", filePath)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit database: %w", err)
+	}
+
+	// SQLite allows a single writer, serializing connections avoids busy errors
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create audit table: %w", err)
+	}
+
+	return &SqliteLog{db: db}, nil
+}
+
+func (l *SqliteLog) Record(entry Entry) error {
+	decisions, err := json.Marshal(entry.Decisions)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit decisions: %w", err)
+	}
+
+	_, err = l.db.Exec(`
+		INSERT INTO audit_entries (time, user_id, role, connection, database_id, question, sql, rows, duration_ns, error, decisions)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.Time.UnixMicro(), entry.UserID, entry.Role, entry.Connection, entry.DatabaseID, entry.Question, entry.SQL,
+		entry.Rows, int64(entry.Duration), entry.Error, string(decisions))
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	return nil
+}
+
+func (l *SqliteLog) Search(filter Filter) ([]Entry, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := l.db.Query(`
+		SELECT time, user_id, role, connection, database_id, question, sql, rows, duration_ns, error, decisions
+		FROM audit_entries
+		WHERE (? = 0 OR user_id = ?)
+		  AND (? = '' OR instr(lower(question), lower(?)) > 0 OR instr(lower(sql), lower(?)) > 0 OR instr(lower(error), lower(?)) > 0)
+		ORDER BY id DESC
+		LIMIT ?
+	`, filter.UserID, filter.UserID, filter.Text, filter.Text, filter.Text, filter.Text, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Entry
+	for rows.Next() {
+		var entry Entry
+		var entryTime, duration int64
+		var decisions string
+		err := rows.Scan(&entryTime, &entry.UserID, &entry.Role, &entry.Connection, &entry.DatabaseID, &entry.Question,
+			&entry.SQL, &entry.Rows, &duration, &entry.Error, &decisions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entry.Time = time.UnixMicro(entryTime)
+		entry.Duration = time.Duration(duration)
+		if err := json.Unmarshal([]byte(decisions), &entry.Decisions); err != nil {
+			return nil, fmt.Errorf("failed to decode audit decisions: %w", err)
+		}
+		result = append(result, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit entries: %w", err)
+	}
+
+	return result, nil
+}
+
+func (l *SqliteLog) Close() error {
+	return l.db.Close()
+}
@@ -0,0 +1,184 @@
+package authorization
+
+import (
+	"slices"
+	"sync"
+)
+
+type Permission string
+
+const (
+	// PermissionQuery allows asking questions, choosing snapshots and connections and reading the glossary
+	PermissionQuery Permission = "query"
+	// PermissionExport allows exporting descriptions
+	PermissionExport Permission = "export"
+	// PermissionEditDescriptions allows editing, importing and reviewing suggested descriptions
+	PermissionEditDescriptions Permission = "edit_descriptions"
+	// PermissionManageGlossary allows adding and deleting glossary terms
+	PermissionManageGlossary Permission = "manage_glossary"
+	// PermissionManageDatabases allows creating, deleting, renaming and refreshing snapshots
+	PermissionManageDatabases Permission = "manage_databases"
+	// PermissionViewStats allows reading the answer accuracy stats
+	PermissionViewStats Permission = "view_stats"
+	// PermissionViewAudit allows searching the audit log of questions and queries
+	PermissionViewAudit Permission = "view_audit"
+	// PermissionManageUsage allows viewing and resetting the usage and limits of every user
+	PermissionManageUsage Permission = "manage_usage"
+	// PermissionWrite allows requesting changes to data, which run once another user approves them
+	PermissionWrite Permission = "write"
+	// PermissionApproveWrites allows approving or rejecting the changes requested by others
+	PermissionApproveWrites Permission = "approve_writes"
+	// PermissionSchedule allows scheduling questions and queries whose results are sent periodically
+	PermissionSchedule Permission = "schedule"
+	// PermissionScheduleSQL allows pinning hand-written SQL to schedules instead of a question
+	PermissionScheduleSQL Permission = "schedule_sql"
+)
+
+var Permissions = []Permission{
+	PermissionQuery,
+	PermissionExport,
+	PermissionEditDescriptions,
+	PermissionManageGlossary,
+	PermissionManageDatabases,
+	PermissionViewStats,
+	PermissionViewAudit,
+	PermissionManageUsage,
+	PermissionWrite,
+	PermissionApproveWrites,
+	PermissionSchedule,
+	PermissionScheduleSQL,
+}
+
+const (
+	RoleViewer  = "viewer"
+	RoleAnalyst = "analyst"
+	RoleEditor  = "editor"
+	RoleAdmin   = "admin"
+)
+
+// AllConnections in the connections of a role allows every configured connection
+const AllConnections = "*"
+
+var defaultRolePermissions = map[string][]Permission{
+	RoleViewer:  {PermissionQuery},
+	RoleAnalyst: {PermissionQuery, PermissionExport, PermissionSchedule},
+	RoleEditor:  {PermissionQuery, PermissionExport, PermissionSchedule, PermissionEditDescriptions, PermissionManageGlossary},
+	RoleAdmin:   Permissions,
+}
+
+func IsBuiltinRole(role string) bool {
+	_, ok := defaultRolePermissions[role]
+	return ok
+}
+
+// RoleDefinition customizes a role. Nil permissions keep the defaults of a built-in role and
+// empty connections allow every connection.
+type RoleDefinition struct {
+	Permissions []Permission
+	Connections []string
+}
+
+// User is the role of a user and the attributes the row filters of the role refer to
+type User struct {
+	Role       string
+	Attributes map[string]string
+}
+
+type grant struct {
+	role        string
+	attributes  map[string]string
+	permissions []Permission
+	connections []string // nil allows every connection
+}
+
+// Authorizer decides what each user may do. It is the only place where roles are resolved,
+// handlers ask it for permissions instead of checking user IDs themselves.
+type Authorizer struct {
+	mu     sync.RWMutex
+	grants map[int64]grant
+}
+
+func NewAuthorizer(roles map[string]RoleDefinition, users map[int64]User) *Authorizer {
+	authorizer := &Authorizer{}
+	authorizer.Update(roles, users)
+	return authorizer
+}
+
+// Update replaces the roles and users, e.g. when the config is reloaded.
+// Users with an unknown role are ignored, the config validation reports them.
+func (a *Authorizer) Update(roles map[string]RoleDefinition, users map[int64]User) {
+	grants := make(map[int64]grant, len(users))
+	for userID, user := range users {
+		role := user.Role
+		definition, defined := roles[role]
+		permissions := definition.Permissions
+		if permissions == nil {
+			var builtin bool
+			permissions, builtin = defaultRolePermissions[role]
+			if !builtin && !defined {
+				continue
+			}
+		}
+
+		var connections []string
+		if len(definition.Connections) > 0 && !slices.Contains(definition.Connections, AllConnections) {
+			connections = definition.Connections
+		}
+
+		grants[userID] = grant{role: role, attributes: user.Attributes, permissions: permissions, connections: connections}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.grants = grants
+}
+
+// Role returns the role of the user, false if the user may not use the bot at all
+func (a *Authorizer) Role(userID int64) (string, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	userGrant, ok := a.grants[userID]
+	return userGrant.role, ok
+}
+
+// Attributes returns the attributes of the user, such as the region row filters compare with
+func (a *Authorizer) Attributes(userID int64) map[string]string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.grants[userID].attributes
+}
+
+func (a *Authorizer) Can(userID int64, permission Permission) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	userGrant, ok := a.grants[userID]
+	return ok && slices.Contains(userGrant.permissions, permission)
+}
+
+// UsersWith returns the users who have the permission, in ascending order
+func (a *Authorizer) UsersWith(permission Permission) []int64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var users []int64
+	for userID, userGrant := range a.grants {
+		if slices.Contains(userGrant.permissions, permission) {
+			users = append(users, userID)
+		}
+	}
+	slices.Sort(users)
+	return users
+}
+
+func (a *Authorizer) CanUseConnection(userID int64, connection string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	userGrant, ok := a.grants[userID]
+	if !ok {
+		return false
+	}
+	return userGrant.connections == nil || slices.Contains(userGrant.connections, connection)
+}
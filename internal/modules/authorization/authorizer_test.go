@@ -0,0 +1,48 @@
+package authorization
+
+import "testing"
+
+func TestAuthorizer(t *testing.T) {
+	authorizer := NewAuthorizer(map[string]RoleDefinition{
+		RoleViewer: {Connections: []string{"analytics"}},
+		"auditor":  {Permissions: []Permission{PermissionViewStats}},
+	}, map[int64]User{
+		1: {Role: RoleAdmin},
+		2: {Role: RoleViewer, Attributes: map[string]string{"region": "north"}},
+		3: {Role: "auditor"},
+		4: {Role: "unknown"},
+	})
+
+	if !authorizer.Can(1, PermissionManageDatabases) || !authorizer.CanUseConnection(1, "billing") {
+		t.Fatalf("admin should be allowed everything")
+	}
+	if !authorizer.Can(2, PermissionQuery) || authorizer.Can(2, PermissionExport) {
+		t.Fatalf("viewer should keep the default permissions")
+	}
+	if !authorizer.CanUseConnection(2, "analytics") || authorizer.CanUseConnection(2, "billing") {
+		t.Fatalf("viewer should be limited to the analytics connection")
+	}
+	if authorizer.Attributes(2)["region"] != "north" {
+		t.Fatalf("unexpected attributes: %v", authorizer.Attributes(2))
+	}
+	if !authorizer.Can(3, PermissionViewStats) || authorizer.Can(3, PermissionQuery) {
+		t.Fatalf("custom role should only have its own permissions")
+	}
+	if _, ok := authorizer.Role(4); ok {
+		t.Fatalf("user with an unknown role should not be allowed")
+	}
+	if approvers := authorizer.UsersWith(PermissionApproveWrites); len(approvers) != 1 || approvers[0] != 1 {
+		t.Fatalf("only the admin should approve writes, got %v", approvers)
+	}
+
+	authorizer.Update(nil, map[int64]User{2: {Role: RoleEditor}})
+	if _, ok := authorizer.Role(1); ok {
+		t.Fatalf("removed user should not be allowed after an update")
+	}
+	if !authorizer.Can(2, PermissionManageGlossary) || !authorizer.CanUseConnection(2, "billing") {
+		t.Fatalf("updated role should apply")
+	}
+	if !authorizer.Can(2, PermissionSchedule) || authorizer.Can(2, PermissionScheduleSQL) {
+		t.Fatalf("only admins should pin SQL to schedules by default")
+	}
+}
@@ -0,0 +1,92 @@
+package bot
+
+import (
+	"sync"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/database_handler"
+)
+
+const maxStoredAnswers = 1000
+
+// answerStore keeps the latest answers so that the buttons under a result message can
+// refer to the question and SQL it was generated from
+type answerStore struct {
+	answers map[int]storedAnswer
+	order   []int
+	nextID  int
+	lock    sync.Mutex
+}
+
+type storedAnswer struct {
+	UserID      int64
+	Answer      database_handler.QueryAnswer
+	HasFeedback bool
+}
+
+func newAnswerStore() *answerStore {
+	return &answerStore{
+		answers: make(map[int]storedAnswer),
+		nextID:  1,
+	}
+}
+
+func (s *answerStore) add(answer database_handler.QueryAnswer, userID int64) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	s.answers[id] = storedAnswer{UserID: userID, Answer: answer}
+	s.order = append(s.order, id)
+
+	// Evict the oldest answers
+	for len(s.order) > maxStoredAnswers {
+		delete(s.answers, s.order[0])
+		s.order = s.order[1:]
+	}
+
+	return id
+}
+
+// get returns the answer only to the user it was generated for
+func (s *answerStore) get(id int, userID int64) (database_handler.QueryAnswer, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stored, ok := s.answers[id]
+	if !ok || stored.UserID != userID {
+		return database_handler.QueryAnswer{}, false
+	}
+	return stored.Answer, true
+}
+
+// markFeedback reserves the answer for the user's feedback so that a second click can't
+// record it twice. It returns false if the answer is unknown or already has feedback.
+func (s *answerStore) markFeedback(id int, userID int64) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stored, ok := s.answers[id]
+	if !ok || stored.UserID != userID || stored.HasFeedback {
+		return false
+	}
+
+	stored.HasFeedback = true
+	s.answers[id] = stored
+	return true
+}
+
+// unmarkFeedback releases the reservation of markFeedback when the feedback couldn't be
+// recorded, so that the user can try again
+func (s *answerStore) unmarkFeedback(id int, userID int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stored, ok := s.answers[id]
+	if !ok || stored.UserID != userID {
+		return
+	}
+
+	stored.HasFeedback = false
+	s.answers[id] = stored
+}
@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/audit"
+)
+
+const (
+	auditUsage = "Usage: /audit [user:<id>] [text to search in questions, SQL and errors]"
+	// auditEntriesShown keeps the reply within a single message
+	auditEntriesShown = 10
+	auditSQLShown     = 300
+)
+
+func (u *UpdateHandler) handleAudit(args string, userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+
+	filter := audit.Filter{Limit: auditEntriesShown}
+	var words []string
+	for _, word := range strings.Fields(args) {
+		if value, ok := strings.CutPrefix(word, "user:"); ok {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				u.sendText(auditUsage, userID)
+				return
+			}
+			filter.UserID = id
+			continue
+		}
+		words = append(words, word)
+	}
+	filter.Text = strings.Join(words, " ")
+
+	entries, err := u.databaseHandler.SearchAudit(filter)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+	if len(entries) == 0 {
+		u.sendText("No audit entries found.", userID)
+		return
+	}
+
+	var builder strings.Builder
+	for _, entry := range entries {
+		builder.WriteString(fmt.Sprintf("%s user %d (%s) on %s:\n", entry.Time.Format(time.DateTime), entry.UserID, entry.Role, entry.Connection))
+		builder.WriteString(fmt.Sprintf("  Q: %s\n", entry.Question))
+		if entry.SQL != "" {
+			builder.WriteString(fmt.Sprintf("  SQL: %s\n", shorten(entry.SQL, auditSQLShown)))
+		}
+		if entry.Error != "" {
+			builder.WriteString(fmt.Sprintf("  Error: %s\n", entry.Error))
+		} else {
+			builder.WriteString(fmt.Sprintf("  %d rows in %s\n", entry.Rows, entry.Duration.Round(time.Millisecond)))
+		}
+		for _, decision := range entry.Decisions {
+			builder.WriteString(fmt.Sprintf("  Policy: %s\n", shorten(decision, auditSQLShown)))
+		}
+		builder.WriteString("\n")
+	}
+
+	u.sendText(builder.String(), userID)
+}
+
+func shorten(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length]) + "…"
+}
@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/authorization"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/bot/messages"
+)
+
+const (
+	permissionDeniedMessage = "Your role is not allowed to do this."
+	connectionDeniedMessage = "Your role is not allowed to use the %s connection."
+)
+
+// accessRule is what a user needs to run a command or press a button
+type accessRule struct {
+	permission authorization.Permission
+	// currentDatabase is set for actions on the snapshot the user selected, which require
+	// one to be selected and access to the connection it belongs to
+	currentDatabase bool
+}
+
+var commandRules = map[string]accessRule{
+	"/start":                {permission: authorization.PermissionQuery},
+	"/connect":              {permission: authorization.PermissionQuery},
+	"/list_dbs":             {permission: authorization.PermissionQuery},
+	"/glossary":             {permission: authorization.PermissionQuery, currentDatabase: true},
+	"/skip":                 {permission: authorization.PermissionQuery},
+	"/export_descriptions":  {permission: authorization.PermissionExport, currentDatabase: true},
+	"/set_description":      {permission: authorization.PermissionEditDescriptions, currentDatabase: true},
+	"/import_descriptions":  {permission: authorization.PermissionEditDescriptions, currentDatabase: true},
+	"/set_db_description":   {permission: authorization.PermissionEditDescriptions, currentDatabase: true},
+	"/suggest_descriptions": {permission: authorization.PermissionEditDescriptions, currentDatabase: true},
+	"/add_term":             {permission: authorization.PermissionManageGlossary, currentDatabase: true},
+	"/delete_term":          {permission: authorization.PermissionManageGlossary, currentDatabase: true},
+	"/create_db":            {permission: authorization.PermissionManageDatabases},
+	"/delete_db":            {permission: authorization.PermissionManageDatabases},
+	"/rename_db":            {permission: authorization.PermissionManageDatabases, currentDatabase: true},
+	"/refresh_db":           {permission: authorization.PermissionManageDatabases, currentDatabase: true},
+	"/stats":                {permission: authorization.PermissionViewStats},
+	"/audit":                {permission: authorization.PermissionViewAudit},
+	"/usage":                {permission: authorization.PermissionManageUsage},
+	"/fresh":                queryRule,
+	"/schedule":             {permission: authorization.PermissionSchedule, currentDatabase: true},
+	"/schedules":            {permission: authorization.PermissionSchedule},
+	"/write":                {permission: authorization.PermissionWrite, currentDatabase: true},
+}
+
+// queryRule applies to messages that are not commands, which end up as questions
+var queryRule = accessRule{permission: authorization.PermissionQuery, currentDatabase: true}
+
+var documentRule = accessRule{permission: authorization.PermissionEditDescriptions, currentDatabase: true}
+
+func callbackRule(callback string) accessRule {
+	switch {
+	case callback == messages.SuggestionAcceptCallback,
+		callback == messages.SuggestionEditCallback,
+		callback == messages.SuggestionSkipCallback,
+		strings.HasPrefix(callback, "table-data-"),
+		strings.HasPrefix(callback, "column-data-"):
+		return accessRule{permission: authorization.PermissionEditDescriptions, currentDatabase: true}
+	case strings.HasPrefix(callback, messages.DeleteDatabaseCallbackPrefix),
+		strings.HasPrefix(callback, messages.ConfirmDeleteDatabaseCallbackPrefix),
+		strings.HasPrefix(callback, messages.ConfirmRenameDatabaseCallbackPrefix),
+		strings.HasPrefix(callback, messages.ConfirmRefreshDatabaseCallbackPrefix):
+		return accessRule{permission: authorization.PermissionManageDatabases}
+	case callback == messages.ConfirmQueryCallback:
+		return queryRule
+	case strings.HasPrefix(callback, messages.ApproveWriteCallbackPrefix),
+		strings.HasPrefix(callback, messages.RejectWriteCallbackPrefix):
+		return accessRule{permission: authorization.PermissionApproveWrites}
+	case strings.HasPrefix(callback, messages.PauseScheduleCallbackPrefix),
+		strings.HasPrefix(callback, messages.ResumeScheduleCallbackPrefix),
+		strings.HasPrefix(callback, messages.DeleteScheduleCallbackPrefix):
+		return accessRule{permission: authorization.PermissionSchedule}
+	default:
+		return accessRule{permission: authorization.PermissionQuery}
+	}
+}
+
+func (u *UpdateHandler) isUserAllowedToUseBot(userID int64) bool {
+	_, ok := u.authorizer.Role(userID)
+	return ok
+}
+
+// authorize checks the rule and tells the user why it is not allowed
+func (u *UpdateHandler) authorize(userID int64, rule accessRule) bool {
+	if !u.authorizer.Can(userID, rule.permission) {
+		u.sendText(permissionDeniedMessage, userID)
+		return false
+	}
+
+	if rule.currentDatabase {
+		database, err := u.databaseHandler.GetCurrentDatabase(userID)
+		if err != nil {
+			u.sendText(err.Error(), userID)
+			return false
+		}
+		if !u.authorizeConnection(userID, database.Connection) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (u *UpdateHandler) authorizeConnection(userID int64, connection string) bool {
+	if !u.authorizer.CanUseConnection(userID, connection) {
+		u.sendText(fmt.Sprintf(connectionDeniedMessage, connection), userID)
+		return false
+	}
+	return true
+}
@@ -1,16 +1,19 @@
 package bot
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/authorization"
 	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/bot/messages"
 	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/database_handler"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/ratelimit"
 	"github.com/AliTaghipour1/Talk-to_DB/pkg/bot_api"
 	tgbotapi "github.com/ghiac/bale-bot-api"
 )
@@ -19,20 +22,24 @@ type UpdateHandler struct {
 	databaseHandler  *database_handler.DatabaseHandler
 	sender           bot_api.BotApi
 	botAPI           *tgbotapi.BotAPI
-	allowedUserIds   []int64
+	authorizer       *authorization.Authorizer
+	limiter          *ratelimit.Limiter
 	usersData        sync.Map
 	stateDataManager *stateDataManager
+	answers          *answerStore
 }
 
 func NewBotUpdateHandler(databaseHandler *database_handler.DatabaseHandler, sender bot_api.BotApi,
-	botAPI *tgbotapi.BotAPI, serviceConfig *config.TalkToDBConfig) *UpdateHandler {
+	botAPI *tgbotapi.BotAPI, authorizer *authorization.Authorizer, limiter *ratelimit.Limiter) *UpdateHandler {
 
 	result := &UpdateHandler{
 		botAPI:           botAPI,
 		sender:           sender,
-		allowedUserIds:   serviceConfig.AllowedUserIds,
+		authorizer:       authorizer,
+		limiter:          limiter,
 		databaseHandler:  databaseHandler,
 		stateDataManager: newStateDataManager(),
+		answers:          newAnswerStore(),
 	}
 
 	return result
@@ -73,13 +80,34 @@ func (u *UpdateHandler) handleCallback(update *tgbotapi.Update) {
 	callback := update.CallbackQuery.Data
 	userID := int64(update.CallbackQuery.From.ID)
 
-	if !u.isUserAllowedToUseBot(userID) {
+	if !u.isUserAllowedToUseBot(userID) || !u.allowMessage(userID) {
+		return
+	}
+
+	if !u.authorize(userID, callbackRule(callback)) {
 		return
 	}
 
 	switch callback {
+	case messages.SuggestionAcceptCallback:
+		u.handleAcceptSuggestion(userID)
+	case messages.SuggestionEditCallback:
+		u.handleEditSuggestion(userID)
+	case messages.SuggestionSkipCallback:
+		u.handleSkipSuggestion(userID)
+	case messages.ConfirmQueryCallback:
+		u.handleConfirmQuery(userID)
+	case messages.CancelCallback:
+		u.handleCancelAction(userID)
 	default:
-		if strings.HasPrefix(callback, "database-data-") {
+		if u.handleSnapshotCallback(callback, userID) || u.handleWriteCallback(callback, userID) ||
+			u.handleScheduleCallback(callback, userID) {
+			return
+		}
+
+		if strings.HasPrefix(callback, messages.ConnectionCallbackPrefix) {
+			u.handleSwitchConnection(strings.TrimPrefix(callback, messages.ConnectionCallbackPrefix), userID)
+		} else if strings.HasPrefix(callback, "database-data-") {
 			databaseID, err := strconv.Atoi(strings.TrimPrefix(callback, "database-data-"))
 			if err != nil {
 				log.Println("message - database callback parse failed:", err)
@@ -94,6 +122,20 @@ func (u *UpdateHandler) handleCallback(update *tgbotapi.Update) {
 			columnName := columnData[0]
 			tableName := columnData[1]
 			u.handleChosenColumn(tableName, columnName, userID)
+		} else if strings.HasPrefix(callback, messages.AnswerUpCallbackPrefix) {
+			answerID, err := strconv.Atoi(strings.TrimPrefix(callback, messages.AnswerUpCallbackPrefix))
+			if err != nil {
+				log.Println("message - answer callback parse failed:", err)
+				return
+			}
+			u.handleAnswerFeedback(answerID, userID, true)
+		} else if strings.HasPrefix(callback, messages.AnswerDownCallbackPrefix) {
+			answerID, err := strconv.Atoi(strings.TrimPrefix(callback, messages.AnswerDownCallbackPrefix))
+			if err != nil {
+				log.Println("message - answer callback parse failed:", err)
+				return
+			}
+			u.handleAnswerFeedback(answerID, userID, false)
 		}
 	}
 
@@ -102,21 +144,70 @@ func (u *UpdateHandler) handleCallback(update *tgbotapi.Update) {
 func (u *UpdateHandler) handleMessage(update *tgbotapi.Update) {
 	userID := int64(update.Message.From.ID)
 
-	if !u.isUserAllowedToUseBot(userID) {
+	if !u.isUserAllowedToUseBot(userID) || !u.allowMessage(userID) {
+		return
+	}
+
+	if update.Message.Document != nil {
+		if u.authorize(userID, documentRule) {
+			u.handleDocument(update.Message.Document, userID)
+		}
 		return
 	}
 
 	text := update.Message.Text
-	switch text {
+	command, args, _ := strings.Cut(text, " ")
+	if rule, ok := commandRules[command]; ok && !u.authorize(userID, rule) {
+		return
+	}
+
+	switch command {
 	case "/start":
 		u.handleStart(userID)
 	case "/create_db":
 		u.handleCreateDatabase(userID)
-	case "/connect_postgres", "/connect_mysql", "/connect_cockroach":
-		db := strings.TrimPrefix(text, "/connect_")
-		u.handleSwitchDriver(db, userID)
+	case "/connect":
+		u.handleConnectCommand(args, userID)
 	case "/set_description":
 		u.handleSetDescriptionCommand(userID)
+	case "/export_descriptions":
+		u.handleExportDescriptions(userID)
+	case "/import_descriptions":
+		u.handleImportDescriptionsCommand(userID)
+	case "/set_db_description":
+		u.handleSetDatabaseDescriptionCommand(userID)
+	case "/glossary":
+		u.handleGlossary(userID)
+	case "/suggest_descriptions":
+		u.handleSuggestDescriptions(userID)
+	case "/stats":
+		u.handleStats(userID)
+	case "/audit":
+		u.handleAudit(args, userID)
+	case "/usage":
+		u.handleUsage(args, userID)
+	case "/write":
+		u.handleWrite(args, userID)
+	case "/fresh":
+		u.handleFreshQuery(args, userID)
+	case "/schedule":
+		u.handleAddSchedule(args, userID)
+	case "/schedules":
+		u.handleSchedules(userID)
+	case "/skip":
+		u.handleSkip(userID)
+	case "/add_term":
+		u.handleAddGlossaryTerm(args, userID)
+	case "/delete_term":
+		u.handleDeleteGlossaryTerm(args, userID)
+	case "/list_dbs":
+		u.handleListDatabases(userID)
+	case "/delete_db":
+		u.handleDeleteDatabaseCommand(userID)
+	case "/rename_db":
+		u.handleRenameDatabaseCommand(args, userID)
+	case "/refresh_db":
+		u.handleRefreshDatabaseCommand(userID)
 	default:
 		u.handleStatefulMessage(text, userID)
 	}
@@ -125,12 +216,24 @@ func (u *UpdateHandler) handleMessage(update *tgbotapi.Update) {
 }
 
 func (u *UpdateHandler) handleStatefulMessage(text string, userID int64) {
+	if u.handleSetDatabaseDescription(text, userID) {
+		return
+	}
+
+	if u.handleEditedSuggestion(text, userID) {
+		return
+	}
+
+	if u.handleFeedbackCorrection(text, userID) {
+		return
+	}
+
 	ok := u.handleSetDescription(text, userID)
 	if ok {
 		return
 	}
 
-	u.handleQuery(text, userID)
+	u.handleQuery(text, userID, false)
 }
 
 func (u *UpdateHandler) handleSetDescription(text string, userID int64) bool {
@@ -141,7 +244,7 @@ func (u *UpdateHandler) handleSetDescription(text string, userID int64) bool {
 		return false
 	}
 
-	err := u.databaseHandler.SetDescription(data.Table, data.Column, text)
+	err := u.databaseHandler.SetDescription(userID, data.Table, data.Column, text)
 	if err != nil {
 		return false
 	}
@@ -153,8 +256,59 @@ func (u *UpdateHandler) handleSetDescription(text string, userID int64) bool {
 	return true
 }
 
-func (u *UpdateHandler) handleQuery(text string, userID int64) {
-	result, err := u.databaseHandler.Query(text)
+// handleFreshQuery answers the question without the cached SQL and results
+func (u *UpdateHandler) handleFreshQuery(args string, userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+
+	text := strings.TrimSpace(args)
+	if text == "" {
+		u.sendText("Usage: /fresh <question>", userID)
+		return
+	}
+	u.handleQuery(text, userID, true)
+}
+
+func (u *UpdateHandler) handleQuery(text string, userID int64, fresh bool) {
+	if !u.authorize(userID, queryRule) || !u.allowQuery(userID) {
+		return
+	}
+
+	role, _ := u.authorizer.Role(userID)
+	result, err := u.databaseHandler.Query(text, database_handler.Requester{
+		UserID:     userID,
+		Role:       role,
+		Attributes: u.authorizer.Attributes(userID),
+	}, fresh)
+	var confirmation *database_handler.ConfirmationRequiredError
+	if errors.As(err, &confirmation) {
+		u.stateDataManager.SetPendingQuery(confirmation.Pending, userID)
+		u.sender.SendMessage(bot_api.Message{
+			Text:        fmt.Sprintf("This query looks expensive, %s:\n%s\n\nRun it anyway?", confirmation.Pending.Reason, confirmation.Pending.SQL()),
+			ChatId:      userID,
+			ReplyMarkup: messages.GenerateConfirmationButtons(messages.ConfirmQueryCallback),
+		})
+		return
+	}
+	u.sendAnswer(result, err, userID)
+}
+
+// handleConfirmQuery runs the expensive query the user was asked to confirm
+func (u *UpdateHandler) handleConfirmQuery(userID int64) {
+	pending, ok := u.stateDataManager.GetPendingQuery(userID)
+	u.stateDataManager.EmptyUserStateData(userID)
+	if !ok {
+		u.sendText("There is no query waiting for confirmation.", userID)
+		return
+	}
+	if !u.authorizeConnection(userID, pending.Connection()) {
+		return
+	}
+
+	result, err := u.databaseHandler.ExecuteConfirmed(pending)
+	u.sendAnswer(result, err, userID)
+}
+
+func (u *UpdateHandler) sendAnswer(result database_handler.QueryAnswer, err error, userID int64) {
 	if err != nil {
 		log.Printf("error executing query: %v", err)
 		u.sender.SendMessage(bot_api.Message{
@@ -164,9 +318,14 @@ func (u *UpdateHandler) handleQuery(text string, userID int64) {
 		return
 	}
 
+	text := result.Result
+	if !result.CachedAt.IsZero() {
+		text += fmt.Sprintf("\n\nCached %s ago, send /fresh %s to run it again.", time.Since(result.CachedAt).Round(time.Second), result.Question)
+	}
 	u.sender.SendMessage(bot_api.Message{
-		Text:   result,
-		ChatId: userID,
+		Text:        text,
+		ChatId:      userID,
+		ReplyMarkup: messages.GenerateAnswerButtons(u.answers.add(result, userID)),
 	})
 }
 
@@ -176,6 +335,9 @@ func (u *UpdateHandler) handleStart(userID int64) {
 	if err != nil {
 		return
 	}
+	databases = slices.DeleteFunc(databases, func(database database_handler.Database) bool {
+		return !u.authorizer.CanUseConnection(userID, database.Connection)
+	})
 
 	u.sender.SendMessage(bot_api.Message{
 		Text:        "Choose databases:",
@@ -185,13 +347,23 @@ func (u *UpdateHandler) handleStart(userID int64) {
 }
 
 func (u *UpdateHandler) handleChoosingDatabase(userID int64, databaseID int) {
-	err := u.databaseHandler.HandleChoosingDatabase(databaseID)
+	database, err := u.databaseHandler.GetDatabase(databaseID)
 	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+	if !u.authorizeConnection(userID, database.Connection) {
+		return
+	}
+
+	connection, err := u.databaseHandler.HandleChoosingDatabase(userID, databaseID)
+	if err != nil {
+		u.sendText(err.Error(), userID)
 		return
 	}
 
 	u.sender.SendMessage(bot_api.Message{
-		Text:   "کوئری رو بگو:",
+		Text:   fmt.Sprintf("Connected to %s.\nکوئری رو بگو:", connection),
 		ChatId: userID,
 	})
 }
@@ -231,22 +403,12 @@ func createTableColumnsData(table database_handler.Table) []messages.ColumnData
 	return result
 }
 
-func (u *UpdateHandler) handleSwitchDriver(db string, userID int64) {
+func (u *UpdateHandler) handleCreateDatabase(userID int64) {
 	defer u.stateDataManager.EmptyUserStateData(userID)
-	err := u.databaseHandler.SwitchDriver(db)
-	if err != nil {
+	if connection := u.databaseHandler.CurrentConnection(userID); connection != "" && !u.authorizeConnection(userID, connection) {
 		return
 	}
-
-	u.sender.SendMessage(bot_api.Message{
-		Text:   "Connected to " + db,
-		ChatId: userID,
-	})
-}
-
-func (u *UpdateHandler) handleCreateDatabase(userID int64) {
-	defer u.stateDataManager.EmptyUserStateData(userID)
-	database, err := u.databaseHandler.CreateDatabase()
+	database, err := u.databaseHandler.CreateDatabase(userID)
 	if err != nil {
 		return
 	}
@@ -258,8 +420,11 @@ func (u *UpdateHandler) handleCreateDatabase(userID int64) {
 
 }
 
-func (u *UpdateHandler) isUserAllowedToUseBot(userId int64) bool {
-	return slices.Contains(u.allowedUserIds, userId)
+func (u *UpdateHandler) sendText(text string, userID int64) {
+	u.sender.SendMessage(bot_api.Message{
+		Text:   text,
+		ChatId: userID,
+	})
 }
 
 func (u *UpdateHandler) Start() {
@@ -275,7 +440,7 @@ func (u *UpdateHandler) Start() {
 
 func (u *UpdateHandler) handleSetDescriptionCommand(userID int64) {
 	defer u.stateDataManager.EmptyUserStateData(userID)
-	database, err := u.databaseHandler.GetCurrentDatabase()
+	database, err := u.databaseHandler.GetCurrentDatabase(userID)
 	if err != nil {
 		return
 	}
@@ -288,7 +453,7 @@ func (u *UpdateHandler) handleSetDescriptionCommand(userID int64) {
 }
 
 func (u *UpdateHandler) handleChosenTable(tableName string, userID int64) {
-	database, err := u.databaseHandler.GetCurrentDatabase()
+	database, err := u.databaseHandler.GetCurrentDatabase(userID)
 	if err != nil {
 		return
 	}
@@ -312,7 +477,7 @@ func (u *UpdateHandler) handleChosenTable(tableName string, userID int64) {
 
 func (u *UpdateHandler) handleChosenColumn(tableName string, columnName string, userID int64) {
 	fmt.Println(tableName, columnName)
-	database, err := u.databaseHandler.GetCurrentDatabase()
+	database, err := u.databaseHandler.GetCurrentDatabase(userID)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/bot/messages"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/database_handler"
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/bot_api"
+)
+
+// handleConnectCommand switches to the connection given as argument, or lists the
+// configured connections to choose from
+func (u *UpdateHandler) handleConnectCommand(args string, userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+	if name := strings.TrimSpace(args); name != "" {
+		u.handleSwitchConnection(name, userID)
+		return
+	}
+
+	connections := slices.DeleteFunc(u.databaseHandler.GetConnections(), func(connection database_handler.ConnectionStatus) bool {
+		return !u.authorizer.CanUseConnection(userID, connection.Name)
+	})
+	if len(connections) == 0 {
+		u.sendText("No connection is configured.", userID)
+		return
+	}
+
+	u.sender.SendMessage(bot_api.Message{
+		Text:        "Choose connection:",
+		ChatId:      userID,
+		ReplyMarkup: messages.GenerateConnectionButtons(createConnectionMessageData(connections)),
+	})
+}
+
+func (u *UpdateHandler) handleSwitchConnection(name string, userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+	if !u.authorizeConnection(userID, name) {
+		return
+	}
+
+	err := u.databaseHandler.SwitchConnection(userID, name)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	u.sendText("Connected to "+name, userID)
+}
+
+func createConnectionMessageData(connections []database_handler.ConnectionStatus) []messages.ConnectionData {
+	var result []messages.ConnectionData
+	for _, connection := range connections {
+		result = append(result, messages.ConnectionData{
+			Name:   connection.Name,
+			Status: string(connection.Status),
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/bot_api"
+	tgbotapi "github.com/ghiac/bale-bot-api"
+)
+
+func (u *UpdateHandler) handleExportDescriptions(userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+	fileName, data, err := u.databaseHandler.ExportDescriptions(userID)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	u.sender.SendMessage(bot_api.Message{
+		Text:   "Edit the descriptions and send the file back after /import_descriptions",
+		ChatId: userID,
+		File: &bot_api.File{
+			Content: bot_api.Content{
+				FileBytes: &bot_api.FileBytes{
+					Bytes: data,
+					Name:  fileName,
+				},
+			},
+			Type: bot_api.Document,
+		},
+	})
+}
+
+func (u *UpdateHandler) handleImportDescriptionsCommand(userID int64) {
+	u.stateDataManager.EmptyUserStateData(userID)
+	u.stateDataManager.SetAwaitingDescriptionsFile(userID)
+
+	u.sendText("Send the edited descriptions file (YAML or JSON).", userID)
+}
+
+func (u *UpdateHandler) handleDocument(document *tgbotapi.Document, userID int64) {
+	if !u.stateDataManager.IsAwaitingDescriptionsFile(userID) {
+		return
+	}
+	defer u.stateDataManager.EmptyUserStateData(userID)
+
+	data, err := u.sender.DownloadFile(document.FileID)
+	if err != nil {
+		log.Println("message - download descriptions file failed:", err)
+		u.sendText("Failed to download the file.", userID)
+		return
+	}
+
+	count, err := u.databaseHandler.ImportDescriptions(userID, data)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	u.sendText(fmt.Sprintf("Successfully updated %d descriptions.", count), userID)
+}
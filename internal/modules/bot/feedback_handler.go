@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+func (u *UpdateHandler) handleAnswerFeedback(answerID int, userID int64, positive bool) {
+	answer, ok := u.answers.get(answerID, userID)
+	if !ok {
+		return
+	}
+
+	if !u.answers.markFeedback(answerID, userID) {
+		u.sendText("Feedback is already recorded for this answer.", userID)
+		return
+	}
+
+	feedbackID, err := u.databaseHandler.RecordFeedback(answer, userID, positive)
+	if err != nil {
+		u.answers.unmarkFeedback(answerID, userID)
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	if !positive {
+		u.stateDataManager.EmptyUserStateData(userID)
+		u.stateDataManager.SetAwaitingFeedbackCorrection(feedbackID, userID)
+		u.sendText("Thanks for the feedback. Send what the correct answer or SQL should be, or /skip.", userID)
+		return
+	}
+
+	// Answers of pinned SQL have no question to learn from
+	if answer.Question == "" {
+		u.sendText("Thanks for the feedback.", userID)
+		return
+	}
+
+	err = u.databaseHandler.SaveExample(answer, userID)
+	if err != nil {
+		log.Println("message - save example failed:", err)
+		u.sendText("Thanks for the feedback. Saving it as a verified example failed: "+err.Error(), userID)
+		return
+	}
+
+	u.sendText("Thanks for the feedback. Saved as a verified example.", userID)
+}
+
+func (u *UpdateHandler) handleFeedbackCorrection(text string, userID int64) bool {
+	feedbackID, ok := u.stateDataManager.GetAwaitingFeedbackCorrection(userID)
+	if !ok {
+		return false
+	}
+	defer u.stateDataManager.EmptyUserStateData(userID)
+
+	err := u.databaseHandler.SetFeedbackCorrection(feedbackID, text)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return true
+	}
+
+	u.sendText("Thanks, the correction is saved.", userID)
+	return true
+}
+
+func (u *UpdateHandler) handleSkip(userID int64) {
+	u.stateDataManager.EmptyUserStateData(userID)
+	u.sendText("Skipped.", userID)
+}
+
+func (u *UpdateHandler) handleStats(userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+	stats, err := u.databaseHandler.GetFeedbackStats()
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	var builder strings.Builder
+	for _, periodStats := range stats {
+		builder.WriteString(periodStats.Period + ":\n")
+		if len(periodStats.ByDatabase) == 0 {
+			builder.WriteString("  no feedback\n\n")
+			continue
+		}
+
+		builder.WriteString("  Databases:\n")
+		for _, item := range periodStats.ByDatabase {
+			builder.WriteString(fmt.Sprintf("    %s: %.0f%% (%d/%d)\n", item.Name, item.Accuracy(), item.Positive, item.Total))
+		}
+		builder.WriteString("  Models:\n")
+		for _, item := range periodStats.ByModel {
+			builder.WriteString(fmt.Sprintf("    %s: %.0f%% (%d/%d)\n", item.Name, item.Accuracy(), item.Positive, item.Total))
+		}
+		builder.WriteString("\n")
+	}
+
+	u.sendText(builder.String(), userID)
+}
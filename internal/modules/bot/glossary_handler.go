@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/database_handler"
+)
+
+const (
+	addTermUsage    = "Usage: /add_term <term> | <definition> | <optional SQL>"
+	deleteTermUsage = "Usage: /delete_term <term>"
+)
+
+func (u *UpdateHandler) handleSetDatabaseDescriptionCommand(userID int64) {
+	u.stateDataManager.EmptyUserStateData(userID)
+	database, err := u.databaseHandler.GetCurrentDatabase(userID)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	u.stateDataManager.SetAwaitingDatabaseDescription(userID)
+	u.sendText(fmt.Sprintf("Send description for the database. current description for %s database: \n%s", database.Name, database.Description), userID)
+}
+
+func (u *UpdateHandler) handleSetDatabaseDescription(text string, userID int64) bool {
+	if !u.stateDataManager.IsAwaitingDatabaseDescription(userID) {
+		return false
+	}
+	defer u.stateDataManager.EmptyUserStateData(userID)
+
+	err := u.databaseHandler.SetDatabaseDescription(userID, text)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return true
+	}
+
+	u.sendText("Successfully set database description.", userID)
+	return true
+}
+
+func (u *UpdateHandler) handleGlossary(userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+	glossary, err := u.databaseHandler.GetGlossary(userID)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	if len(glossary) == 0 {
+		u.sendText("Glossary is empty.", userID)
+		return
+	}
+
+	var builder strings.Builder
+	for _, term := range glossary {
+		builder.WriteString(fmt.Sprintf("• %s: %s\n", term.Term, term.Definition))
+		if term.SQL != "" {
+			builder.WriteString(fmt.Sprintf("  SQL: %s\n", term.SQL))
+		}
+	}
+	u.sendText(builder.String(), userID)
+}
+
+func (u *UpdateHandler) handleAddGlossaryTerm(args string, userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+	parts := strings.SplitN(args, "|", 3)
+	if len(parts) < 2 {
+		u.sendText(addTermUsage, userID)
+		return
+	}
+
+	term := database_handler.GlossaryTerm{
+		Term:       parts[0],
+		Definition: parts[1],
+	}
+	if len(parts) == 3 {
+		term.SQL = parts[2]
+	}
+
+	err := u.databaseHandler.SetGlossaryTerm(userID, term)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	u.sendText("Successfully saved glossary term.", userID)
+}
+
+func (u *UpdateHandler) handleDeleteGlossaryTerm(args string, userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+	if strings.TrimSpace(args) == "" {
+		u.sendText(deleteTermUsage, userID)
+		return
+	}
+
+	err := u.databaseHandler.DeleteGlossaryTerm(userID, args)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	u.sendText("Successfully deleted glossary term.", userID)
+}
@@ -24,6 +24,37 @@ func GenerateDatabaseButtons(dbs []DatabaseData) tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: result}
 }
 
+const ConnectionCallbackPrefix = "connection-"
+
+type ConnectionData struct {
+	Name   string
+	Status string
+}
+
+func (d ConnectionData) button() tgbotapi.InlineKeyboardButton {
+	return createButton(fmt.Sprintf("%s %s (%s)", statusEmoji(d.Status), d.Name, d.Status), ConnectionCallbackPrefix+d.Name)
+}
+
+func GenerateConnectionButtons(connections []ConnectionData) tgbotapi.InlineKeyboardMarkup {
+	var result [][]tgbotapi.InlineKeyboardButton
+	for _, connection := range connections {
+		result = append(result, []tgbotapi.InlineKeyboardButton{connection.button()})
+	}
+
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: result}
+}
+
+func statusEmoji(status string) string {
+	switch status {
+	case "up":
+		return "🟢"
+	case "degraded":
+		return "🟡"
+	default:
+		return "🔴"
+	}
+}
+
 type TableData struct {
 	Name string
 }
@@ -59,6 +90,102 @@ func GenerateTableMenuButtons(dbs []ColumnData) tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: result}
 }
 
+const (
+	SuggestionAcceptCallback = "suggestion-accept"
+	SuggestionEditCallback   = "suggestion-edit"
+	SuggestionSkipCallback   = "suggestion-skip"
+)
+
+func GenerateSuggestionButtons() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{{
+		createButton("Accept", SuggestionAcceptCallback),
+		createButton("Edit", SuggestionEditCallback),
+		createButton("Skip", SuggestionSkipCallback),
+	}}}
+}
+
+const (
+	AnswerUpCallbackPrefix   = "answer-up-"
+	AnswerDownCallbackPrefix = "answer-down-"
+)
+
+func GenerateAnswerButtons(answerID int) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{{
+		createButton("👍", fmt.Sprintf("%s%d", AnswerUpCallbackPrefix, answerID)),
+		createButton("👎", fmt.Sprintf("%s%d", AnswerDownCallbackPrefix, answerID)),
+	}}}
+}
+
+const (
+	DeleteDatabaseCallbackPrefix         = "delete-db-"
+	ConfirmDeleteDatabaseCallbackPrefix  = "confirm-delete-db-"
+	ConfirmRenameDatabaseCallbackPrefix  = "confirm-rename-db-"
+	ConfirmRefreshDatabaseCallbackPrefix = "confirm-refresh-db-"
+	ConfirmQueryCallback                 = "confirm-query"
+	CancelCallback                       = "cancel-action"
+)
+
+func GenerateDeleteDatabaseButtons(dbs []DatabaseData) tgbotapi.InlineKeyboardMarkup {
+	var result [][]tgbotapi.InlineKeyboardButton
+	for _, db := range dbs {
+		text := fmt.Sprintf("%s (%d)", db.Name, db.ID)
+		result = append(result, []tgbotapi.InlineKeyboardButton{
+			createButton(text, fmt.Sprintf("%s%d", DeleteDatabaseCallbackPrefix, db.ID)),
+		})
+	}
+
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: result}
+}
+
+const (
+	ApproveWriteCallbackPrefix = "approve-write-"
+	RejectWriteCallbackPrefix  = "reject-write-"
+)
+
+// GenerateWriteApprovalButtons asks an approver to decide on a write request
+func GenerateWriteApprovalButtons(requestID int) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{{
+		createButton("Approve", fmt.Sprintf("%s%d", ApproveWriteCallbackPrefix, requestID)),
+		createButton("Reject", fmt.Sprintf("%s%d", RejectWriteCallbackPrefix, requestID)),
+	}}}
+}
+
+const (
+	PauseScheduleCallbackPrefix  = "pause-schedule-"
+	ResumeScheduleCallbackPrefix = "resume-schedule-"
+	DeleteScheduleCallbackPrefix = "delete-schedule-"
+)
+
+type ScheduleData struct {
+	ID     int
+	Paused bool
+}
+
+// GenerateScheduleButtons offers to pause or resume and to delete each schedule
+func GenerateScheduleButtons(schedules []ScheduleData) tgbotapi.InlineKeyboardMarkup {
+	var result [][]tgbotapi.InlineKeyboardButton
+	for _, schedule := range schedules {
+		toggle := createButton(fmt.Sprintf("Pause #%d", schedule.ID), fmt.Sprintf("%s%d", PauseScheduleCallbackPrefix, schedule.ID))
+		if schedule.Paused {
+			toggle = createButton(fmt.Sprintf("Resume #%d", schedule.ID), fmt.Sprintf("%s%d", ResumeScheduleCallbackPrefix, schedule.ID))
+		}
+		result = append(result, []tgbotapi.InlineKeyboardButton{
+			toggle,
+			createButton(fmt.Sprintf("Delete #%d", schedule.ID), fmt.Sprintf("%s%d", DeleteScheduleCallbackPrefix, schedule.ID)),
+		})
+	}
+
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: result}
+}
+
+// GenerateConfirmationButtons asks the user to confirm a destructive action
+func GenerateConfirmationButtons(confirmCallback string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{{
+		createButton("Yes", confirmCallback),
+		createButton("No", CancelCallback),
+	}}}
+}
+
 func createStaticButton(text string) tgbotapi.InlineKeyboardButton {
 	return tgbotapi.InlineKeyboardButton{
 		Text:         text,
@@ -0,0 +1,235 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/authorization"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/bot/messages"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/database_handler"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/scheduler"
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/bot_api"
+)
+
+const (
+	scheduleUsage = "Usage: /schedule <cron expression> <question>, or /schedule <cron expression> sql: <query> to pin the SQL.\n" +
+		"The expression has the fields minute, hour, day of month, month and day of week in server time, " +
+		"e.g. /schedule 0 9 * * mon weekly payment totals. @hourly, @daily, @weekly and @monthly work too."
+	pinnedSQLPrefix = "sql:"
+	// scheduleTitleShown keeps the list of schedules within a single message
+	scheduleTitleShown = 200
+)
+
+// handleAddSchedule schedules a question, or pinned SQL, on the current database for the user
+func (u *UpdateHandler) handleAddSchedule(args string, userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+
+	expression, text := splitCronExpression(args)
+	cron, err := scheduler.ParseCron(expression)
+	if err != nil || text == "" {
+		if err != nil {
+			u.sendText(err.Error(), userID)
+		}
+		u.sendText(scheduleUsage, userID)
+		return
+	}
+
+	question, sql := text, ""
+	if len(text) >= len(pinnedSQLPrefix) && strings.EqualFold(text[:len(pinnedSQLPrefix)], pinnedSQLPrefix) {
+		question, sql = "", strings.TrimSpace(text[len(pinnedSQLPrefix):])
+		if sql == "" {
+			u.sendText(scheduleUsage, userID)
+			return
+		}
+		if !u.authorizer.Can(userID, authorization.PermissionScheduleSQL) {
+			u.sendText(permissionDeniedMessage, userID)
+			return
+		}
+	}
+
+	schedule, err := u.databaseHandler.AddSchedule(userID, expression, question, sql)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	u.sendText(fmt.Sprintf("Added schedule #%d, it runs next at %s.", schedule.ID, formatNextRun(cron.Next(time.Now()))), userID)
+}
+
+// splitCronExpression splits the arguments into a cron expression, five fields or a
+// descriptor such as @daily, and the rest
+func splitCronExpression(args string) (string, string) {
+	rest := strings.TrimSpace(args)
+	fieldCount := 5
+	if strings.HasPrefix(rest, "@") {
+		fieldCount = 1
+	}
+
+	fields := make([]string, 0, fieldCount)
+	for len(fields) < fieldCount && rest != "" {
+		field, remaining, _ := strings.Cut(rest, " ")
+		fields = append(fields, field)
+		rest = strings.TrimSpace(remaining)
+	}
+	return strings.Join(fields, " "), rest
+}
+
+func formatNextRun(next time.Time) string {
+	if next.IsZero() {
+		return "never"
+	}
+	return next.Format("Mon 2006-01-02 15:04")
+}
+
+// handleSchedules lists the schedules of the user with buttons to pause, resume and delete them
+func (u *UpdateHandler) handleSchedules(userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+
+	schedules, err := u.databaseHandler.GetSchedules(userID)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+	if len(schedules) == 0 {
+		u.sendText("You have no schedules, add one with /schedule.", userID)
+		return
+	}
+
+	var text strings.Builder
+	var buttons []messages.ScheduleData
+	for _, schedule := range schedules {
+		next := "paused"
+		if !schedule.Paused {
+			next = "next run " + formatNextRun(nextRun(schedule))
+		}
+		fmt.Fprintf(&text, "#%d %s on %s, %s:\n%s\n\n", schedule.ID, schedule.Cron, schedule.Connection, next, scheduleTitle(schedule))
+		buttons = append(buttons, messages.ScheduleData{ID: schedule.ID, Paused: schedule.Paused})
+	}
+
+	u.sender.SendMessage(bot_api.Message{
+		Text:        strings.TrimSpace(text.String()),
+		ChatId:      userID,
+		ReplyMarkup: messages.GenerateScheduleButtons(buttons),
+	})
+}
+
+func nextRun(schedule database_handler.Schedule) time.Time {
+	cron, err := scheduler.ParseCron(schedule.Cron)
+	if err != nil {
+		return time.Time{}
+	}
+	return cron.Next(time.Now())
+}
+
+// scheduleTitle is the question of the schedule, or its pinned SQL
+func scheduleTitle(schedule database_handler.Schedule) string {
+	title := schedule.Question
+	if schedule.SQL != "" {
+		title = "SQL: " + schedule.SQL
+	}
+	return shorten(title, scheduleTitleShown)
+}
+
+// handleScheduleCallback pauses, resumes or deletes a schedule of the user. It returns false
+// if the callback is not for a schedule.
+func (u *UpdateHandler) handleScheduleCallback(callback string, userID int64) bool {
+	var prefix string
+	for _, candidate := range []string{
+		messages.PauseScheduleCallbackPrefix,
+		messages.ResumeScheduleCallbackPrefix,
+		messages.DeleteScheduleCallbackPrefix,
+	} {
+		if strings.HasPrefix(callback, candidate) {
+			prefix = candidate
+			break
+		}
+	}
+	if prefix == "" {
+		return false
+	}
+
+	scheduleID, err := strconv.Atoi(strings.TrimPrefix(callback, prefix))
+	if err != nil {
+		log.Println("message - schedule callback parse failed:", err)
+		return true
+	}
+
+	schedule, err := u.databaseHandler.GetSchedule(scheduleID)
+	if err != nil || schedule.UserID != userID {
+		u.sendText(fmt.Sprintf("Schedule #%d not found.", scheduleID), userID)
+		return true
+	}
+
+	switch prefix {
+	case messages.PauseScheduleCallbackPrefix:
+		err = u.databaseHandler.SetSchedulePaused(scheduleID, true)
+	case messages.ResumeScheduleCallbackPrefix:
+		err = u.databaseHandler.SetSchedulePaused(scheduleID, false)
+	case messages.DeleteScheduleCallbackPrefix:
+		err = u.databaseHandler.DeleteSchedule(scheduleID)
+	}
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return true
+	}
+
+	switch prefix {
+	case messages.PauseScheduleCallbackPrefix:
+		u.sendText(fmt.Sprintf("Paused schedule #%d.", scheduleID), userID)
+	case messages.ResumeScheduleCallbackPrefix:
+		u.sendText(fmt.Sprintf("Resumed schedule #%d, it runs next at %s.", scheduleID, formatNextRun(nextRun(schedule))), userID)
+	case messages.DeleteScheduleCallbackPrefix:
+		u.sendText(fmt.Sprintf("Deleted schedule #%d.", scheduleID), userID)
+	}
+	return true
+}
+
+// RunSchedule answers a due schedule for its user and sends the result, see scheduler.Scheduler.
+// The user may have lost access since adding the schedule, so it is checked again.
+func (u *UpdateHandler) RunSchedule(scheduleID int) {
+	schedule, err := u.databaseHandler.GetSchedule(scheduleID)
+	if err != nil {
+		log.Printf("failed to load schedule %d: %v", scheduleID, err)
+		return
+	}
+	if schedule.Paused {
+		return
+	}
+
+	userID := schedule.UserID
+	if !u.authorizer.Can(userID, authorization.PermissionSchedule) || !u.authorizer.Can(userID, authorization.PermissionQuery) ||
+		schedule.SQL != "" && !u.authorizer.Can(userID, authorization.PermissionScheduleSQL) ||
+		!u.authorizer.CanUseConnection(userID, schedule.Connection) {
+		log.Printf("skipped schedule %d, user %d may no longer run it", scheduleID, userID)
+		u.sendText(fmt.Sprintf("Schedule #%d was skipped, your role is no longer allowed to run it.", scheduleID), userID)
+		return
+	}
+	if schedule.SQL == "" {
+		if err := u.limiter.CheckBudget(userID); err != nil {
+			u.sendText(fmt.Sprintf("Schedule #%d was skipped: %v.", scheduleID, err), userID)
+			return
+		}
+	}
+
+	role, _ := u.authorizer.Role(userID)
+	result, err := u.databaseHandler.AnswerSchedule(schedule, database_handler.Requester{
+		UserID:     userID,
+		Role:       role,
+		Attributes: u.authorizer.Attributes(userID),
+	})
+	header := fmt.Sprintf("Schedule #%d: %s\n\n", scheduleID, scheduleTitle(schedule))
+	if err != nil {
+		log.Printf("error running schedule %d: %v", scheduleID, err)
+		u.sendText(header+err.Error(), userID)
+		return
+	}
+
+	u.sender.SendMessage(bot_api.Message{
+		Text:        header + result.Result,
+		ChatId:      userID,
+		ReplyMarkup: messages.GenerateAnswerButtons(u.answers.add(result, userID)),
+	})
+}
@@ -0,0 +1,201 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/bot/messages"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/database_handler"
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/bot_api"
+)
+
+const renameDatabaseUsage = "Usage: /rename_db <new name>"
+
+func (u *UpdateHandler) handleListDatabases(userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+	databases, err := u.databaseHandler.GetConnectionDatabases(userID)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	if len(databases) == 0 {
+		u.sendText("No database is created for the current connection. Use /create_db to create one.", userID)
+		return
+	}
+
+	var builder strings.Builder
+	for _, database := range databases {
+		builder.WriteString(fmt.Sprintf("• %d: %s (%d tables)\n", database.ID, database.Name, len(database.Tables)))
+	}
+	u.sendText(builder.String(), userID)
+}
+
+func (u *UpdateHandler) handleDeleteDatabaseCommand(userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+	databases, err := u.databaseHandler.GetAllDatabases()
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+	databases = slices.DeleteFunc(databases, func(database database_handler.Database) bool {
+		return !u.authorizer.CanUseConnection(userID, database.Connection)
+	})
+
+	if len(databases) == 0 {
+		u.sendText("There is no database to delete.", userID)
+		return
+	}
+
+	u.sender.SendMessage(bot_api.Message{
+		Text:        "Choose the database to delete:",
+		ChatId:      userID,
+		ReplyMarkup: messages.GenerateDeleteDatabaseButtons(createDatabaseMessageData(databases)),
+	})
+}
+
+func (u *UpdateHandler) handleRenameDatabaseCommand(args string, userID int64) {
+	u.stateDataManager.EmptyUserStateData(userID)
+	name := strings.TrimSpace(args)
+	if name == "" {
+		u.sendText(renameDatabaseUsage, userID)
+		return
+	}
+
+	database, err := u.databaseHandler.GetCurrentDatabase(userID)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	u.stateDataManager.SetPendingRename(&RenameDatabaseData{DatabaseID: database.ID, Name: name}, userID)
+	u.sender.SendMessage(bot_api.Message{
+		Text:        fmt.Sprintf("Rename database %s to %s?", database.Name, name),
+		ChatId:      userID,
+		ReplyMarkup: messages.GenerateConfirmationButtons(fmt.Sprintf("%s%d", messages.ConfirmRenameDatabaseCallbackPrefix, database.ID)),
+	})
+}
+
+func (u *UpdateHandler) handleRefreshDatabaseCommand(userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+	database, err := u.databaseHandler.GetCurrentDatabase(userID)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	u.sender.SendMessage(bot_api.Message{
+		Text:        fmt.Sprintf("Re-read the tables of database %s? Descriptions of dropped tables and columns will be lost.", database.Name),
+		ChatId:      userID,
+		ReplyMarkup: messages.GenerateConfirmationButtons(fmt.Sprintf("%s%d", messages.ConfirmRefreshDatabaseCallbackPrefix, database.ID)),
+	})
+}
+
+// handleSnapshotCallback handles the callbacks of the database management commands.
+// It returns false if the callback belongs to another command.
+func (u *UpdateHandler) handleSnapshotCallback(callback string, userID int64) bool {
+	var prefix string
+	for _, candidate := range []string{
+		messages.DeleteDatabaseCallbackPrefix,
+		messages.ConfirmDeleteDatabaseCallbackPrefix,
+		messages.ConfirmRenameDatabaseCallbackPrefix,
+		messages.ConfirmRefreshDatabaseCallbackPrefix,
+	} {
+		if strings.HasPrefix(callback, candidate) {
+			prefix = candidate
+			break
+		}
+	}
+	if prefix == "" {
+		return false
+	}
+
+	databaseID, err := strconv.Atoi(strings.TrimPrefix(callback, prefix))
+	if err != nil {
+		log.Println("message - database callback parse failed:", err)
+		return true
+	}
+
+	switch prefix {
+	case messages.DeleteDatabaseCallbackPrefix:
+		u.handleChosenDatabaseToDelete(databaseID, userID)
+	case messages.ConfirmDeleteDatabaseCallbackPrefix:
+		u.handleConfirmDeleteDatabase(databaseID, userID)
+	case messages.ConfirmRenameDatabaseCallbackPrefix:
+		u.handleConfirmRenameDatabase(databaseID, userID)
+	case messages.ConfirmRefreshDatabaseCallbackPrefix:
+		u.handleConfirmRefreshDatabase(databaseID, userID)
+	}
+	return true
+}
+
+func (u *UpdateHandler) handleChosenDatabaseToDelete(databaseID int, userID int64) {
+	database, err := u.databaseHandler.GetDatabase(databaseID)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	u.sender.SendMessage(bot_api.Message{
+		Text: fmt.Sprintf("Delete database %s (%d) with its %d tables, descriptions, glossary and examples? This can not be undone.",
+			database.Name, database.ID, len(database.Tables)),
+		ChatId:      userID,
+		ReplyMarkup: messages.GenerateConfirmationButtons(fmt.Sprintf("%s%d", messages.ConfirmDeleteDatabaseCallbackPrefix, database.ID)),
+	})
+}
+
+func (u *UpdateHandler) handleConfirmDeleteDatabase(databaseID int, userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+	database, err := u.databaseHandler.GetDatabase(databaseID)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+	if !u.authorizeConnection(userID, database.Connection) {
+		return
+	}
+
+	err = u.databaseHandler.DeleteDatabase(databaseID)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	u.sendText("Database deleted.", userID)
+}
+
+func (u *UpdateHandler) handleConfirmRenameDatabase(databaseID int, userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+	data, ok := u.stateDataManager.GetPendingRename(userID)
+	if !ok || data.DatabaseID != databaseID {
+		u.sendText("There is no pending rename. "+renameDatabaseUsage, userID)
+		return
+	}
+
+	err := u.databaseHandler.RenameDatabase(data.DatabaseID, data.Name)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	u.sendText("Database renamed to "+data.Name, userID)
+}
+
+func (u *UpdateHandler) handleConfirmRefreshDatabase(databaseID int, userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+	err := u.databaseHandler.RefreshDatabase(databaseID)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	u.sendText("Database tables refreshed.", userID)
+}
+
+func (u *UpdateHandler) handleCancelAction(userID int64) {
+	u.stateDataManager.EmptyUserStateData(userID)
+	u.sendText("Canceled.", userID)
+}
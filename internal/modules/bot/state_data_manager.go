@@ -3,6 +3,8 @@ package bot
 import (
 	"fmt"
 	"sync"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/database_handler"
 )
 
 type stateDataManager struct {
@@ -18,14 +20,57 @@ type DescriptionData struct {
 	Column *string
 }
 
+// SuggestionData holds the progress of a user reviewing AI suggested descriptions
+type SuggestionData struct {
+	Tables      []string // undocumented tables with no generated suggestions yet
+	Suggestions []database_handler.DescriptionSuggestion
+	Editing     bool
+}
+
+// RenameDatabaseData holds a rename waiting for the user's confirmation
+type RenameDatabaseData struct {
+	DatabaseID int
+	Name       string
+}
+
 const (
-	userDescriptionKey = "description-data-%d"
+	userDescriptionKey        = "description-data-%d"
+	userDescriptionsImportKey = "descriptions-import-%d"
+	userDatabaseDescribingKey = "database-description-%d"
+	userSuggestionKey         = "suggestion-data-%d"
+	userFeedbackKey           = "feedback-correction-%d"
+	userRenameDatabaseKey     = "rename-database-%d"
+	userPendingQueryKey       = "pending-query-%d"
 )
 
 func getDescriptionKey(userID int64) string {
 	return fmt.Sprintf(userDescriptionKey, userID)
 }
 
+func getDescriptionsImportKey(userID int64) string {
+	return fmt.Sprintf(userDescriptionsImportKey, userID)
+}
+
+func getDatabaseDescribingKey(userID int64) string {
+	return fmt.Sprintf(userDatabaseDescribingKey, userID)
+}
+
+func getSuggestionKey(userID int64) string {
+	return fmt.Sprintf(userSuggestionKey, userID)
+}
+
+func getFeedbackKey(userID int64) string {
+	return fmt.Sprintf(userFeedbackKey, userID)
+}
+
+func getRenameDatabaseKey(userID int64) string {
+	return fmt.Sprintf(userRenameDatabaseKey, userID)
+}
+
+func getPendingQueryKey(userID int64) string {
+	return fmt.Sprintf(userPendingQueryKey, userID)
+}
+
 func (s *stateDataManager) GetDescriptionData(userID int64) (DescriptionData, bool) {
 	value, ok := s.data.Load(getDescriptionKey(userID))
 	if !ok {
@@ -59,6 +104,90 @@ func (s *stateDataManager) AddDescriptionTableData(tableName string, userID int6
 	return nil
 }
 
+func (s *stateDataManager) SetAwaitingDescriptionsFile(userID int64) {
+	s.data.Store(getDescriptionsImportKey(userID), true)
+}
+
+func (s *stateDataManager) IsAwaitingDescriptionsFile(userID int64) bool {
+	_, ok := s.data.Load(getDescriptionsImportKey(userID))
+	return ok
+}
+
+func (s *stateDataManager) SetAwaitingDatabaseDescription(userID int64) {
+	s.data.Store(getDatabaseDescribingKey(userID), true)
+}
+
+func (s *stateDataManager) IsAwaitingDatabaseDescription(userID int64) bool {
+	_, ok := s.data.Load(getDatabaseDescribingKey(userID))
+	return ok
+}
+
+func (s *stateDataManager) SetSuggestionData(data *SuggestionData, userID int64) {
+	s.data.Store(getSuggestionKey(userID), data)
+}
+
+func (s *stateDataManager) GetSuggestionData(userID int64) (*SuggestionData, bool) {
+	value, ok := s.data.Load(getSuggestionKey(userID))
+	if !ok {
+		return nil, false
+	}
+
+	suggestionData, ok := value.(*SuggestionData)
+	return suggestionData, ok
+}
+
+func (s *stateDataManager) SetAwaitingFeedbackCorrection(feedbackID int, userID int64) {
+	s.data.Store(getFeedbackKey(userID), feedbackID)
+}
+
+func (s *stateDataManager) GetAwaitingFeedbackCorrection(userID int64) (int, bool) {
+	value, ok := s.data.Load(getFeedbackKey(userID))
+	if !ok {
+		return 0, false
+	}
+
+	feedbackID, ok := value.(int)
+	return feedbackID, ok
+}
+
+func (s *stateDataManager) SetPendingRename(data *RenameDatabaseData, userID int64) {
+	s.data.Store(getRenameDatabaseKey(userID), data)
+}
+
+func (s *stateDataManager) GetPendingRename(userID int64) (RenameDatabaseData, bool) {
+	value, ok := s.data.Load(getRenameDatabaseKey(userID))
+	if !ok {
+		return RenameDatabaseData{}, false
+	}
+
+	renameData, ok := value.(*RenameDatabaseData)
+	if !ok {
+		return RenameDatabaseData{}, false
+	}
+	return *renameData, true
+}
+
+// SetPendingQuery keeps an expensive query until the user confirms it
+func (s *stateDataManager) SetPendingQuery(pending database_handler.PendingQuery, userID int64) {
+	s.data.Store(getPendingQueryKey(userID), pending)
+}
+
+func (s *stateDataManager) GetPendingQuery(userID int64) (database_handler.PendingQuery, bool) {
+	value, ok := s.data.Load(getPendingQueryKey(userID))
+	if !ok {
+		return database_handler.PendingQuery{}, false
+	}
+
+	pending, ok := value.(database_handler.PendingQuery)
+	return pending, ok
+}
+
 func (s *stateDataManager) EmptyUserStateData(userID int64) {
 	s.data.Delete(getDescriptionKey(userID))
+	s.data.Delete(getDescriptionsImportKey(userID))
+	s.data.Delete(getDatabaseDescribingKey(userID))
+	s.data.Delete(getSuggestionKey(userID))
+	s.data.Delete(getFeedbackKey(userID))
+	s.data.Delete(getRenameDatabaseKey(userID))
+	s.data.Delete(getPendingQueryKey(userID))
 }
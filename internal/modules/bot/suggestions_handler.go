@@ -0,0 +1,140 @@
+package bot
+
+import (
+	"fmt"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/bot/messages"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/database_handler"
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/bot_api"
+)
+
+func (u *UpdateHandler) handleSuggestDescriptions(userID int64) {
+	u.stateDataManager.EmptyUserStateData(userID)
+	role, _ := u.authorizer.Role(userID)
+	tables, err := u.databaseHandler.GetUndocumentedTables(database_handler.Requester{
+		UserID:     userID,
+		Role:       role,
+		Attributes: u.authorizer.Attributes(userID),
+	})
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	if len(tables) == 0 {
+		u.sendText("All tables and columns already have descriptions.", userID)
+		return
+	}
+
+	u.stateDataManager.SetSuggestionData(&SuggestionData{Tables: tables}, userID)
+	u.sendNextSuggestion(userID)
+}
+
+// sendNextSuggestion shows the next pending suggestion, generating suggestions for the
+// next undocumented table when the current ones are reviewed
+func (u *UpdateHandler) sendNextSuggestion(userID int64) {
+	data, ok := u.stateDataManager.GetSuggestionData(userID)
+	if !ok {
+		return
+	}
+
+	for len(data.Suggestions) == 0 {
+		if len(data.Tables) == 0 {
+			u.stateDataManager.EmptyUserStateData(userID)
+			u.sendText("No more suggestions.", userID)
+			return
+		}
+
+		if !u.allowModelRequest(userID) {
+			u.stateDataManager.EmptyUserStateData(userID)
+			return
+		}
+
+		tableName := data.Tables[0]
+		data.Tables = data.Tables[1:]
+
+		u.sendText(fmt.Sprintf("Generating suggestions for %s table...", tableName), userID)
+		role, _ := u.authorizer.Role(userID)
+		suggestions, err := u.databaseHandler.SuggestTableDescriptions(tableName, database_handler.Requester{
+			UserID:     userID,
+			Role:       role,
+			Attributes: u.authorizer.Attributes(userID),
+		})
+		if err != nil {
+			u.sendText(fmt.Sprintf("Failed to suggest descriptions for %s table: %v", tableName, err), userID)
+			continue
+		}
+		data.Suggestions = suggestions
+	}
+
+	suggestion := data.Suggestions[0]
+	u.sender.SendMessage(bot_api.Message{
+		Text:        fmt.Sprintf("Suggested description for %s:\n%s", suggestionTarget(suggestion), suggestion.Description),
+		ChatId:      userID,
+		ReplyMarkup: messages.GenerateSuggestionButtons(),
+	})
+}
+
+func (u *UpdateHandler) handleAcceptSuggestion(userID int64) {
+	data, ok := u.stateDataManager.GetSuggestionData(userID)
+	if !ok || len(data.Suggestions) == 0 {
+		return
+	}
+
+	suggestion := data.Suggestions[0]
+	err := u.databaseHandler.SetDescription(userID, suggestion.Table, suggestion.Column, suggestion.Description)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	data.Suggestions = data.Suggestions[1:]
+	u.sendNextSuggestion(userID)
+}
+
+func (u *UpdateHandler) handleEditSuggestion(userID int64) {
+	data, ok := u.stateDataManager.GetSuggestionData(userID)
+	if !ok || len(data.Suggestions) == 0 {
+		return
+	}
+
+	data.Editing = true
+	u.sendText(fmt.Sprintf("Send description for %s.", suggestionTarget(data.Suggestions[0])), userID)
+}
+
+func (u *UpdateHandler) handleSkipSuggestion(userID int64) {
+	data, ok := u.stateDataManager.GetSuggestionData(userID)
+	if !ok || len(data.Suggestions) == 0 {
+		return
+	}
+
+	data.Editing = false
+	data.Suggestions = data.Suggestions[1:]
+	u.sendNextSuggestion(userID)
+}
+
+func (u *UpdateHandler) handleEditedSuggestion(text string, userID int64) bool {
+	data, ok := u.stateDataManager.GetSuggestionData(userID)
+	if !ok || !data.Editing || len(data.Suggestions) == 0 {
+		return false
+	}
+
+	suggestion := data.Suggestions[0]
+	err := u.databaseHandler.SetDescription(userID, suggestion.Table, suggestion.Column, text)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return true
+	}
+
+	data.Editing = false
+	data.Suggestions = data.Suggestions[1:]
+	u.sendNextSuggestion(userID)
+	return true
+}
+
+func suggestionTarget(suggestion database_handler.DescriptionSuggestion) string {
+	if suggestion.Column != nil {
+		return fmt.Sprintf("%s column of %s table", *suggestion.Column, suggestion.Table)
+	}
+	return fmt.Sprintf("%s table", suggestion.Table)
+}
@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/ratelimit"
+)
+
+const (
+	usageUsage   = "Usage: /usage, /usage reset <user id> or /usage reset all"
+	limitMessage = "Sorry, %v."
+)
+
+// allowMessage applies the message rate limit and tells the user once when it is reached.
+// Further messages are dropped silently until one is allowed again.
+func (u *UpdateHandler) allowMessage(userID int64) bool {
+	if err := u.limiter.AllowMessage(userID); err != nil {
+		if !errors.Is(err, ratelimit.ErrMessageDropped) {
+			u.sendText(fmt.Sprintf(limitMessage, err), userID)
+		}
+		return false
+	}
+	return true
+}
+
+// allowQuery applies the query rate limit and the token budgets before a question is sent to the model
+func (u *UpdateHandler) allowQuery(userID int64) bool {
+	if err := u.limiter.AllowQuery(userID); err != nil {
+		u.sendText(fmt.Sprintf(limitMessage, err), userID)
+		return false
+	}
+	return true
+}
+
+// allowModelRequest applies the token budgets to other requests to the model
+func (u *UpdateHandler) allowModelRequest(userID int64) bool {
+	if err := u.limiter.CheckBudget(userID); err != nil {
+		u.sendText(fmt.Sprintf(limitMessage, err), userID)
+		return false
+	}
+	return true
+}
+
+func (u *UpdateHandler) handleUsage(args string, userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+
+	fields := strings.Fields(args)
+	switch {
+	case len(fields) == 0:
+		u.sendUsage(userID)
+	case len(fields) == 2 && fields[0] == "reset" && fields[1] == "all":
+		if err := u.limiter.ResetAll(); err != nil {
+			u.sendText(err.Error(), userID)
+			return
+		}
+		u.sendText("Reset the usage of every user.", userID)
+	case len(fields) == 2 && fields[0] == "reset":
+		resetUserID, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			u.sendText(usageUsage, userID)
+			return
+		}
+		if err := u.limiter.Reset(resetUserID); err != nil {
+			u.sendText(err.Error(), userID)
+			return
+		}
+		u.sendText(fmt.Sprintf("Reset the usage of user %d.", resetUserID), userID)
+	default:
+		u.sendText(usageUsage, userID)
+	}
+}
+
+func (u *UpdateHandler) sendUsage(userID int64) {
+	limits := u.limiter.Limits()
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Token budgets per user: %s a day, %s a month\n\n", formatLimit(limits.DailyTokens), formatLimit(limits.MonthlyTokens)))
+
+	usages := u.limiter.Usage()
+	if len(usages) == 0 {
+		builder.WriteString("No usage this month.")
+	}
+	for _, usage := range usages {
+		builder.WriteString(fmt.Sprintf("User %d: %d questions and %d tokens today, %d tokens this month\n",
+			usage.UserID, usage.Queries, usage.DailyTokens, usage.MonthlyTokens))
+	}
+
+	u.sendText(builder.String(), userID)
+}
+
+func formatLimit(limit int64) string {
+	if limit <= 0 {
+		return "unlimited"
+	}
+	return strconv.FormatInt(limit, 10)
+}
@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/authorization"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/bot/messages"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/database_handler"
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/bot_api"
+)
+
+const writeUsage = "Usage: /write <the change to make, e.g. set the nickname of user 4 to nargess>"
+
+// handleWrite generates the statement for the requested change, tries it without keeping
+// the changes and sends it to the users who may approve writes on the connection
+func (u *UpdateHandler) handleWrite(args string, userID int64) {
+	defer u.stateDataManager.EmptyUserStateData(userID)
+
+	text := strings.TrimSpace(args)
+	if text == "" {
+		u.sendText(writeUsage, userID)
+		return
+	}
+	if !u.allowQuery(userID) {
+		return
+	}
+
+	role, _ := u.authorizer.Role(userID)
+	request, err := u.databaseHandler.RequestWrite(text, database_handler.Requester{
+		UserID:     userID,
+		Role:       role,
+		Attributes: u.authorizer.Attributes(userID),
+	})
+	if err != nil {
+		log.Printf("error requesting write: %v", err)
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	var approvers []int64
+	for _, approver := range u.authorizer.UsersWith(authorization.PermissionApproveWrites) {
+		if approver != userID && u.authorizer.CanUseConnection(approver, request.Connection) {
+			approvers = append(approvers, approver)
+		}
+	}
+	if len(approvers) == 0 {
+		u.sendText("No one else may approve writes on the "+request.Connection+" connection, so the statement was not sent.", userID)
+		return
+	}
+
+	for _, approver := range approvers {
+		u.sender.SendMessage(bot_api.Message{
+			Text: fmt.Sprintf("Write request %d from user %d on %s:\n%s\n\n%s\n\nA dry run changed %d rows.",
+				request.ID, userID, request.Connection, request.Question, request.SQL, request.Affected),
+			ChatId:      approver,
+			ReplyMarkup: messages.GenerateWriteApprovalButtons(request.ID),
+		})
+	}
+	u.sendText(fmt.Sprintf("%s\n\nA dry run changed %d rows. Sent to %d approvers as write request %d.",
+		request.SQL, request.Affected, len(approvers), request.ID), userID)
+}
+
+// handleWriteCallback decides on a write request. It returns false if the callback is not
+// for a write request.
+func (u *UpdateHandler) handleWriteCallback(callback string, userID int64) bool {
+	value, approve := strings.CutPrefix(callback, messages.ApproveWriteCallbackPrefix)
+	if !approve {
+		var reject bool
+		value, reject = strings.CutPrefix(callback, messages.RejectWriteCallbackPrefix)
+		if !reject {
+			return false
+		}
+	}
+
+	requestID, err := strconv.Atoi(value)
+	if err != nil {
+		log.Println("message - write callback parse failed:", err)
+		return true
+	}
+
+	// The approver may have lost access to the connection since the request was sent
+	request, err := u.databaseHandler.PendingWrite(requestID)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return true
+	}
+	if !u.authorizeConnection(userID, request.Connection) {
+		return true
+	}
+
+	if approve {
+		u.handleApproveWrite(requestID, userID)
+	} else {
+		u.handleRejectWrite(requestID, userID)
+	}
+	return true
+}
+
+func (u *UpdateHandler) handleApproveWrite(requestID int, userID int64) {
+	request, affected, err := u.databaseHandler.ApproveWrite(requestID, userID)
+	if errors.Is(err, database_handler.ErrWriteNotFound) || errors.Is(err, database_handler.ErrSelfApproval) {
+		u.sendText(err.Error(), userID)
+		return
+	}
+	if err != nil {
+		log.Printf("error executing write request %d: %v", requestID, err)
+		text := fmt.Sprintf("Write request %d was approved but failed: %v", requestID, err)
+		u.sendText(text, userID)
+		u.sendText(text, request.UserID)
+		return
+	}
+
+	u.sendText(fmt.Sprintf("Write request %d was executed and changed %d rows.", requestID, affected), userID)
+	u.sendText(fmt.Sprintf("Write request %d was approved by user %d and changed %d rows:\n%s", requestID, userID, affected, request.SQL), request.UserID)
+}
+
+func (u *UpdateHandler) handleRejectWrite(requestID int, userID int64) {
+	request, err := u.databaseHandler.RejectWrite(requestID, userID)
+	if err != nil {
+		u.sendText(err.Error(), userID)
+		return
+	}
+
+	u.sendText(fmt.Sprintf("Write request %d was rejected.", requestID), userID)
+	u.sendText(fmt.Sprintf("Write request %d was rejected by user %d:\n%s", requestID, userID, request.SQL), request.UserID)
+}
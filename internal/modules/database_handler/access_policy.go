@@ -0,0 +1,263 @@
+package database_handler
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/sqlparser"
+)
+
+var ErrAccessDenied = errors.New("the query is not allowed for your role")
+
+// anyTable in a rule such as *.national_id denies the column in every table
+const anyTable = "*"
+
+// AccessPolicy limits what a role reads. Denied tables and columns are left out of the
+// schema the model sees, and generated queries that still refer to them are rejected.
+// Row filters and settings limit the rows of the tables it may read, see row_security.go.
+type AccessPolicy struct {
+	deniedTables  map[string]bool
+	deniedColumns map[string][]string // by table, anyTable for columns denied everywhere
+	rowFilters    map[string]string   // condition by table
+	settings      map[string]string
+}
+
+// NewAccessPolicy builds a policy from deny rules of the form table, table.column or
+// *.column, conditions by table and PostgreSQL settings. Names are matched case-insensitively.
+func NewAccessPolicy(deny []string, rowFilters map[string]string, settings map[string]string) AccessPolicy {
+	policy := AccessPolicy{
+		deniedTables:  make(map[string]bool),
+		deniedColumns: make(map[string][]string),
+		rowFilters:    make(map[string]string, len(rowFilters)),
+		settings:      settings,
+	}
+	for table, condition := range rowFilters {
+		policy.rowFilters[strings.ToLower(table)] = condition
+	}
+	for _, rule := range deny {
+		table, column, isColumn := strings.Cut(strings.ToLower(strings.TrimSpace(rule)), ".")
+		if isColumn {
+			policy.deniedColumns[table] = append(policy.deniedColumns[table], column)
+		} else {
+			policy.deniedTables[table] = true
+		}
+	}
+
+	return policy
+}
+
+func (p AccessPolicy) hidesNothing() bool {
+	return len(p.deniedTables) == 0 && len(p.deniedColumns) == 0
+}
+
+func (p AccessPolicy) isTableDenied(table string) bool {
+	return p.deniedTables[strings.ToLower(table)]
+}
+
+func (p AccessPolicy) isColumnDenied(table string, column string) bool {
+	for _, deniedColumn := range p.columnsDeniedIn(table) {
+		if strings.EqualFold(deniedColumn, column) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p AccessPolicy) columnsDeniedIn(table string) []string {
+	return slices.Concat(p.deniedColumns[strings.ToLower(table)], p.deniedColumns[anyTable])
+}
+
+// filterDatabase removes the denied tables and columns from the schema
+func (p AccessPolicy) filterDatabase(database Database) Database {
+	if p.hidesNothing() {
+		return database
+	}
+
+	tables := make([]Table, 0, len(database.Tables))
+	for _, table := range database.Tables {
+		if p.isTableDenied(table.Name) {
+			continue
+		}
+
+		columns := make([]Column, 0, len(table.Columns))
+		for _, column := range table.Columns {
+			if !p.isColumnDenied(table.Name, column.Name) {
+				columns = append(columns, column)
+			}
+		}
+		table.Columns = columns
+		tables = append(tables, table)
+	}
+	database.Tables = tables
+
+	return database
+}
+
+// allowedExamples drops the examples whose SQL would teach the model about denied objects
+func (p AccessPolicy) allowedExamples(examples []repo.Example, database Database, driver config.Driver) []repo.Example {
+	if p.hidesNothing() {
+		return examples
+	}
+
+	var result []repo.Example
+	for _, example := range examples {
+		if p.checkQuery(example.SQL, database, driver) == nil {
+			result = append(result, example)
+		}
+	}
+	return result
+}
+
+// checkableStatements are the statements whose references the parser records completely
+var checkableStatements = map[string]bool{
+	"SELECT": true, "WITH": true, "VALUES": true, "TABLE": true,
+	"INSERT": true, "UPDATE": true, "DELETE": true,
+}
+
+// sqlTextFunctions read tables named in, or run queries given as, string arguments,
+// which the parser can not see into
+var sqlTextFunctions = map[string]bool{
+	"query_to_xml": true, "query_to_xmlschema": true, "query_to_xml_and_xmlschema": true,
+	"table_to_xml": true, "table_to_xmlschema": true, "table_to_xml_and_xmlschema": true,
+	"cursor_to_xml": true, "cursor_to_xmlschema": true,
+	"schema_to_xml": true, "schema_to_xmlschema": true, "schema_to_xml_and_xmlschema": true,
+	"database_to_xml": true, "database_to_xmlschema": true, "database_to_xml_and_xmlschema": true,
+	"dblink": true, "dblink_exec": true, "crosstab": true, "connectby": true,
+	"pg_read_file": true, "pg_read_binary_file": true, "lo_import": true, "load_file": true,
+}
+
+// checkQuery parses the query and rejects it if any of its statements refers to a denied
+// table or column. The schema tells which tables have the columns denied by *.column rules
+// and unqualified names; tables missing from it are assumed to have them.
+func (p AccessPolicy) checkQuery(query string, database Database, driver config.Driver) error {
+	if p.hidesNothing() {
+		return nil
+	}
+
+	statements, err := sqlparser.Parse(query, sqlDialect(driver))
+	if err != nil {
+		return fmt.Errorf("%w: it could not be checked: %v", ErrAccessDenied, err)
+	}
+
+	for _, statement := range statements {
+		if !checkableStatements[statement.Kind] {
+			return fmt.Errorf("%w: %s statements can not be checked", ErrAccessDenied, statement.Kind)
+		}
+
+		var violation string
+		statement.Scope.Walk(func(scope *sqlparser.Scope) {
+			if violation == "" {
+				violation = p.scopeViolation(scope, database)
+			}
+		})
+		if violation != "" {
+			return fmt.Errorf("%w: it uses %s", ErrAccessDenied, violation)
+		}
+	}
+
+	return nil
+}
+
+func sqlDialect(driver config.Driver) sqlparser.Dialect {
+	if driver == config.MySQL {
+		return sqlparser.MySQL
+	}
+	return sqlparser.Postgres
+}
+
+// scopeViolation describes the first denied object the scope refers to, if any
+func (p AccessPolicy) scopeViolation(scope *sqlparser.Scope, database Database) string {
+	for _, table := range scope.Tables {
+		if !table.Derived && p.isTableDenied(table.Name) {
+			return "table " + table.Name
+		}
+	}
+
+	for _, function := range scope.Functions {
+		if sqlTextFunctions[strings.ToLower(function)] {
+			return "function " + function
+		}
+	}
+
+	for _, star := range scope.Stars {
+		tables := scope.Tables
+		if star.Table != "" {
+			tables = scope.Lookup(star.Table)
+		} else if scope.Exists {
+			continue
+		}
+		if violation := p.allColumnsViolation(tables, database); violation != "" {
+			return violation
+		}
+	}
+
+	for _, column := range scope.Columns {
+		if violation := p.columnViolation(scope, column, database); violation != "" {
+			return violation
+		}
+	}
+
+	return ""
+}
+
+func (p AccessPolicy) columnViolation(scope *sqlparser.Scope, column sqlparser.ColumnRef, database Database) string {
+	if column.Table != "" {
+		tables := scope.Lookup(column.Table)
+		if len(tables) == 0 {
+			// Not a table, so either a schema or a composite column such as address.city
+			if violation := p.columnViolation(scope, sqlparser.ColumnRef{Name: column.Table}, database); violation != "" {
+				return violation
+			}
+			return p.columnViolation(scope, sqlparser.ColumnRef{Name: column.Name}, database)
+		}
+		for _, table := range tables {
+			if !table.Derived && p.isColumnDenied(table.Name, column.Name) {
+				return "column " + table.Name + "." + column.Name
+			}
+		}
+		return ""
+	}
+
+	for _, table := range scope.VisibleTables() {
+		if !table.Derived && p.isColumnDenied(table.Name, column.Name) && hasColumn(database, table.Name, column.Name) {
+			return "column " + table.Name + "." + column.Name
+		}
+	}
+
+	// A table name used as a value, as in row_to_json(u), carries the whole row
+	return p.allColumnsViolation(scope.Lookup(column.Name), database)
+}
+
+func (p AccessPolicy) allColumnsViolation(tables []sqlparser.TableRef, database Database) string {
+	for _, table := range tables {
+		if table.Derived {
+			continue
+		}
+		for _, column := range p.columnsDeniedIn(table.Name) {
+			if hasColumn(database, table.Name, column) {
+				return "all columns of " + table.Name
+			}
+		}
+	}
+	return ""
+}
+
+// hasColumn reports whether the table may have the column, true if the schema doesn't know
+func hasColumn(database Database, tableName string, columnName string) bool {
+	for _, table := range database.Tables {
+		if !strings.EqualFold(table.Name, tableName) {
+			continue
+		}
+		for _, column := range table.Columns {
+			if strings.EqualFold(column.Name, columnName) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package database_handler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
+)
+
+func TestAccessPolicy_CheckQuery(t *testing.T) {
+	policy := NewAccessPolicy([]string{"user_account.account_number", "salaries", "*.national_id"}, nil, nil)
+	database := Database{Tables: []Table{
+		{Name: "user_account", Columns: []Column{{Name: "id"}, {Name: "nickname"}, {Name: "account_number"}}},
+		{Name: "transfers", Columns: []Column{{Name: "user_id"}, {Name: "account_number"}, {Name: "amount"}}},
+		{Name: "customers", Columns: []Column{{Name: "id"}, {Name: "national_id"}}},
+	}}
+
+	allowed := []string{
+		"SELECT nickname FROM user_account",
+		"SELECT t.account_number FROM transfers t JOIN user_account u ON u.id = t.user_id",
+		"SELECT * FROM transfers",
+		"SELECT count(*) FROM user_account",
+		"SELECT id FROM user_account u WHERE EXISTS (SELECT * FROM user_account x WHERE x.id = u.id)",
+		"SELECT 'account_number' AS label FROM user_account",
+	}
+	for _, query := range allowed {
+		if err := policy.checkQuery(query, database, config.Postgres); err != nil {
+			t.Errorf("%s: %v", query, err)
+		}
+	}
+
+	denied := []string{
+		"SELECT account_number FROM user_account",
+		"SELECT u.account_number FROM transfers t JOIN user_account u ON u.id = t.user_id",
+		"SELECT * FROM user_account",
+		"SELECT u.* FROM user_account u",
+		"SELECT row_to_json(u) FROM user_account u",
+		"SELECT x FROM (SELECT account_number AS x FROM user_account) s",
+		"WITH a AS (SELECT * FROM user_account) SELECT nickname FROM a",
+		"SELECT id FROM transfers WHERE user_id IN (SELECT id FROM user_account WHERE account_number LIKE '1%')",
+		"SELECT * FROM public.salaries",
+		"SELECT national_id FROM customers",
+		"SELECT 1; SELECT account_number FROM user_account",
+		"SELECT query_to_xml('select account_number from user_account', true, true, '')",
+		"DO $$ BEGIN PERFORM 1; END $$",
+		"SELECT account_number FROM user_account WHERE note = 'unterminated",
+		"WITH salaries AS (SELECT * FROM salaries) SELECT * FROM salaries",
+		"WITH user_account AS (SELECT * FROM user_account) SELECT account_number FROM user_account",
+		`SELECT U&"account\005Fnumber" FROM user_account`,
+		`SELECT * FROM U&"salarie\0073"`,
+	}
+	for _, query := range denied {
+		if err := policy.checkQuery(query, database, config.Postgres); !errors.Is(err, ErrAccessDenied) {
+			t.Errorf("%s: expected access denied, got %v", query, err)
+		}
+	}
+}
+
+func TestAccessPolicy_FilterDatabase(t *testing.T) {
+	policy := NewAccessPolicy([]string{"user_account.account_number", "salaries"}, nil, nil)
+	database := policy.filterDatabase(Database{Tables: []Table{
+		{Name: "user_account", Columns: []Column{{Name: "id"}, {Name: "account_number"}}},
+		{Name: "salaries", Columns: []Column{{Name: "amount"}}},
+	}})
+
+	if len(database.Tables) != 1 || len(database.Tables[0].Columns) != 1 || database.Tables[0].Columns[0].Name != "id" {
+		t.Fatalf("unexpected filtered schema: %+v", database.Tables)
+	}
+}
@@ -0,0 +1,186 @@
+package database_handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/ai"
+)
+
+// maxCachedEntries bounds each cache, the oldest entries are dropped first
+const maxCachedEntries = 1000
+
+// sqlCacheKey identifies a question asked from a snapshot. The schema hash covers everything
+// the model is shown besides the question, so a refreshed snapshot, a new description, glossary
+// term or example, or a role that sees another part of the schema gets SQL generated anew.
+type sqlCacheKey struct {
+	databaseID int
+	question   string
+	schemaHash string
+}
+
+func newSQLCacheKey(databaseID int, request ai.QueryRequest) sqlCacheKey {
+	context, err := json.Marshal(struct {
+		Schema   string
+		Glossary []ai.GlossaryEntry
+		Examples []ai.Example
+	}{request.Schema, request.Glossary, request.Examples})
+	if err != nil {
+		panic(err)
+	}
+	hash := sha256.Sum256(context)
+
+	return sqlCacheKey{
+		databaseID: databaseID,
+		question:   normalizeQuestion(request.Question),
+		schemaHash: hex.EncodeToString(hash[:]),
+	}
+}
+
+// normalizeQuestion ignores case, punctuation and spacing, so that "How many users signed up
+// this week?" and "how many users signed up this week" are the same question
+func normalizeQuestion(question string) string {
+	return strings.TrimSpace(normalizeText(question))
+}
+
+// resultCacheKey identifies a query as run for a role. Row filters are part of the query and
+// the attributes of the requester are part of the query or the settings, so users only share
+// results they may all read. Results are masked for the role.
+type resultCacheKey struct {
+	connection string
+	role       string
+	query      string
+	settings   string
+}
+
+func newResultCacheKey(connection string, role string, query string, settings map[string]string) resultCacheKey {
+	var encoded strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(settings)) {
+		encoded.WriteString(name + "=" + settings[name] + "\n")
+	}
+
+	return resultCacheKey{connection: connection, role: role, query: query, settings: encoded.String()}
+}
+
+type cachedResult struct {
+	result   string
+	rows     int
+	cachedAt time.Time
+	expires  time.Time
+}
+
+// answerCache keeps generated SQL, and the results of connections that cache them, so that
+// repeated questions cost neither a request to the model nor a query
+type answerCache struct {
+	mu      sync.Mutex
+	sql     *boundedMap[sqlCacheKey, string]
+	results *boundedMap[resultCacheKey, cachedResult]
+	now     func() time.Time
+}
+
+func newAnswerCache() *answerCache {
+	return &answerCache{
+		sql:     newBoundedMap[sqlCacheKey, string](maxCachedEntries),
+		results: newBoundedMap[resultCacheKey, cachedResult](maxCachedEntries),
+		now:     time.Now,
+	}
+}
+
+func (c *answerCache) getSQL(key sqlCacheKey) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sql.get(key)
+}
+
+func (c *answerCache) putSQL(key sqlCacheKey, query string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sql.put(key, query)
+}
+
+// forgetSQL drops the SQL generated for the question from the snapshot, e.g. after it was
+// reported to be wrong
+func (c *answerCache) forgetSQL(databaseID int, question string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	question = normalizeQuestion(question)
+	c.sql.deleteFunc(func(key sqlCacheKey) bool {
+		return key.databaseID == databaseID && key.question == question
+	})
+}
+
+func (c *answerCache) getResult(key resultCacheKey) (cachedResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result, ok := c.results.get(key)
+	if ok && !c.now().Before(result.expires) {
+		c.results.deleteFunc(func(other resultCacheKey) bool { return other == key })
+		return cachedResult{}, false
+	}
+	return result, ok
+}
+
+func (c *answerCache) putResult(key resultCacheKey, result string, rows int, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.results.put(key, cachedResult{result: result, rows: rows, cachedAt: now, expires: now.Add(ttl)})
+}
+
+// forgetResults drops the results of the connection, e.g. after its data was changed
+func (c *answerCache) forgetResults(connection string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.results.deleteFunc(func(key resultCacheKey) bool { return key.connection == connection })
+}
+
+// forgetAllResults drops every result, e.g. after the rules they were read and masked by changed
+func (c *answerCache) forgetAllResults() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.results = newBoundedMap[resultCacheKey, cachedResult](maxCachedEntries)
+}
+
+// boundedMap is a map that drops its oldest entries once it holds more than limit
+type boundedMap[K comparable, V any] struct {
+	values map[K]V
+	order  []K
+	limit  int
+}
+
+func newBoundedMap[K comparable, V any](limit int) *boundedMap[K, V] {
+	return &boundedMap[K, V]{values: make(map[K]V), limit: limit}
+}
+
+func (m *boundedMap[K, V]) get(key K) (V, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+func (m *boundedMap[K, V]) put(key K, value V) {
+	if _, ok := m.values[key]; !ok {
+		m.order = append(m.order, key)
+	}
+	m.values[key] = value
+
+	for len(m.order) > m.limit {
+		delete(m.values, m.order[0])
+		m.order = m.order[1:]
+	}
+}
+
+func (m *boundedMap[K, V]) deleteFunc(del func(key K) bool) {
+	maps.DeleteFunc(m.values, func(key K, _ V) bool { return del(key) })
+	m.order = slices.DeleteFunc(m.order, del)
+}
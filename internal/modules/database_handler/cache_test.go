@@ -0,0 +1,86 @@
+package database_handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/ai"
+)
+
+func TestNewSQLCacheKey(t *testing.T) {
+	request := ai.QueryRequest{Schema: `{"Tables": []}`, Question: "How many users signed up this week?"}
+	key := newSQLCacheKey(1, request)
+
+	request.Question = "  how many users   signed up this week "
+	if newSQLCacheKey(1, request) != key {
+		t.Fatal("expected questions differing in case, spacing and punctuation to share a key")
+	}
+
+	request.Schema = `{"Tables": [{"Name": "users"}]}`
+	if newSQLCacheKey(1, request) == key {
+		t.Fatal("expected a changed schema to change the key")
+	}
+}
+
+func TestAnswerCache_Results(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	cache := newAnswerCache()
+	cache.now = func() time.Time { return now }
+
+	key := newResultCacheKey("main", "analyst", "SELECT 1", map[string]string{"app.region": "eu"})
+	cache.putResult(key, "[1]", 1, time.Minute)
+
+	if _, ok := cache.getResult(newResultCacheKey("main", "viewer", "SELECT 1", map[string]string{"app.region": "eu"})); ok {
+		t.Fatal("expected results not to be shared between roles")
+	}
+	if result, ok := cache.getResult(key); !ok || result.result != "[1]" || !result.cachedAt.Equal(now) {
+		t.Fatalf("expected the cached result, got %+v, %v", result, ok)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.getResult(key); ok {
+		t.Fatal("expected the result to expire")
+	}
+
+	cache.putResult(key, "[1]", 1, time.Minute)
+	cache.forgetResults("main")
+	if _, ok := cache.getResult(key); ok {
+		t.Fatal("expected the results of the connection to be forgotten")
+	}
+
+	cache.putResult(key, "[1]", 1, time.Minute)
+	cache.forgetAllResults()
+	if _, ok := cache.getResult(key); ok {
+		t.Fatal("expected every result to be forgotten")
+	}
+}
+
+func TestAnswerCache_ForgetSQL(t *testing.T) {
+	cache := newAnswerCache()
+	key := newSQLCacheKey(1, ai.QueryRequest{Question: "How many users?"})
+	cache.putSQL(key, "SELECT count(*) FROM users")
+
+	cache.forgetSQL(2, "how many users")
+	if _, ok := cache.getSQL(key); !ok {
+		t.Fatal("expected the SQL of another database to be kept")
+	}
+	cache.forgetSQL(1, "how many users")
+	if _, ok := cache.getSQL(key); ok {
+		t.Fatal("expected the SQL to be forgotten")
+	}
+}
+
+func TestBoundedMap_DropsOldest(t *testing.T) {
+	m := newBoundedMap[int, string](2)
+	m.put(1, "a")
+	m.put(2, "b")
+	m.put(1, "c")
+	m.put(3, "d")
+
+	if _, ok := m.get(1); ok {
+		t.Fatal("expected the oldest entry to be dropped")
+	}
+	if value, ok := m.get(2); !ok || value != "b" {
+		t.Fatalf("expected 2 to be kept, got %q", value)
+	}
+}
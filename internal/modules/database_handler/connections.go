@@ -0,0 +1,158 @@
+package database_handler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
+	db2 "github.com/AliTaghipour1/Talk-to_DB/internal/modules/db"
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+)
+
+// Connection is a configured database server, identified by its unique name
+type Connection struct {
+	Name      string
+	Driver    config.Driver
+	Database  db2.Database
+	CostLimit CostLimit
+	// ResultCacheTTL is how long results of the connection are reused, 0 disables caching them
+	ResultCacheTTL time.Duration
+}
+
+// ConnectionStatus is the latest known health of a configured connection
+type ConnectionStatus struct {
+	Name   string
+	Driver config.Driver
+	Status db2.Status
+}
+
+// GetConnections returns the configured connections with their health, in alphabetical order
+func (d *DatabaseHandler) GetConnections() []ConnectionStatus {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	result := make([]ConnectionStatus, 0, len(d.connections))
+	for _, connection := range d.connections {
+		result = append(result, ConnectionStatus{
+			Name:   connection.Name,
+			Driver: connection.Driver,
+			Status: connection.Database.Health().Status,
+		})
+	}
+	slices.SortFunc(result, func(a, b ConnectionStatus) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return result
+}
+
+// SetConnection makes a connection available. It returns the connection with the same name
+// it replaced, if any, so that the caller can close it. Results cached from the replaced
+// connection may come from another server, so they are dropped.
+func (d *DatabaseHandler) SetConnection(connection Connection) (Connection, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	previous, replaced := d.connections[connection.Name]
+	d.connections[connection.Name] = connection
+	d.cache.forgetResults(connection.Name)
+	return previous, replaced
+}
+
+// RemoveConnection stops offering the connection and returns it so that the caller can close it
+func (d *DatabaseHandler) RemoveConnection(name string) (Connection, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	connection, ok := d.connections[name]
+	delete(d.connections, name)
+	d.cache.forgetResults(name)
+	return connection, ok
+}
+
+// selection is the connection and snapshot a user works with
+type selection struct {
+	connection string
+	databaseID *int
+}
+
+func (d *DatabaseHandler) selectionOf(userID int64) selection {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.selections[userID]
+}
+
+func (d *DatabaseHandler) setSelection(userID int64, current selection) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.selections[userID] = current
+}
+
+// SwitchConnection selects the connection for the user
+func (d *DatabaseHandler) SwitchConnection(userID int64, name string) error {
+	if _, err := d.lookupConnection(name); err != nil {
+		return err
+	}
+	current := d.selectionOf(userID)
+	current.connection = name
+
+	// A selected snapshot of another connection would run its SQL against the wrong server
+	if current.databaseID != nil {
+		currentDatabase, err := d.databaseRepo.GetDatabase(*current.databaseID)
+		if err != nil || currentDatabase.Connection != name {
+			current.databaseID = nil
+		}
+	}
+	d.setSelection(userID, current)
+
+	return nil
+}
+
+// CurrentConnection returns the name of the connection the user selected, empty if none is selected
+func (d *DatabaseHandler) CurrentConnection(userID int64) string {
+	return d.selectionOf(userID).connection
+}
+
+func (d *DatabaseHandler) getCurrentConnection(userID int64) (Connection, error) {
+	name := d.CurrentConnection(userID)
+	if name == "" {
+		return Connection{}, ErrEmptyDriver
+	}
+
+	return d.lookupConnection(name)
+}
+
+// currentDatabase returns the snapshot the user selected
+func (d *DatabaseHandler) currentDatabase(userID int64) (repo.Database, error) {
+	current := d.selectionOf(userID)
+	if current.databaseID == nil {
+		return repo.Database{}, ErrNotConnected
+	}
+
+	return d.databaseRepo.GetDatabase(*current.databaseID)
+}
+
+// lookupConnection returns the configured connection with the given name
+func (d *DatabaseHandler) lookupConnection(name string) (Connection, error) {
+	d.mu.RLock()
+	connection, ok := d.connections[name]
+	d.mu.RUnlock()
+	if !ok {
+		return Connection{}, fmt.Errorf("%w: %q", ErrUnknownConnection, name)
+	}
+
+	return connection, nil
+}
+
+// connectionOf returns the connection a snapshot was taken from. It must be the connection
+// the user selected, so that generated SQL never runs against another server.
+func (d *DatabaseHandler) connectionOf(userID int64, name string) (Connection, error) {
+	if current := d.CurrentConnection(userID); name != current {
+		return Connection{}, fmt.Errorf("%w: it was taken from %q but the current connection is %q, choose it again with /start",
+			ErrConnectionMismatch, name, current)
+	}
+
+	return d.lookupConnection(name)
+}
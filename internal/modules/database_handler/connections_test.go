@@ -0,0 +1,67 @@
+package database_handler
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+)
+
+func TestDatabaseHandler_SelectionsPerUser(t *testing.T) {
+	databaseRepo, err := repo.NewDatabaseRepoMapImpl(filepath.Join(t.TempDir(), "data.json"), repo.MapRepoOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	salesID, err := databaseRepo.CreateNewDatabase(&repo.Database{Name: "sales", Driver: "postgres", Connection: "sales"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hrID, err := databaseRepo.CreateNewDatabase(&repo.Database{Name: "hr", Driver: "postgres", Connection: "hr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &DatabaseHandler{
+		connections:  map[string]Connection{"sales": {Name: "sales"}, "hr": {Name: "hr"}},
+		selections:   make(map[int64]selection),
+		databaseRepo: databaseRepo,
+	}
+
+	if _, err := d.HandleChoosingDatabase(1, salesID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.HandleChoosingDatabase(2, hrID); err != nil {
+		t.Fatal(err)
+	}
+	if database, err := d.GetCurrentDatabase(1); err != nil || database.ID != salesID {
+		t.Fatalf("expected user 1 to keep sales, got %+v, %v", database, err)
+	}
+	if database, err := d.GetCurrentDatabase(2); err != nil || database.ID != hrID {
+		t.Fatalf("expected user 2 to keep hr, got %+v, %v", database, err)
+	}
+	if _, err := d.GetCurrentDatabase(3); !errors.Is(err, ErrEmptyDriver) {
+		t.Fatalf("expected user 3 to have no database, got %v", err)
+	}
+
+	// Switching the connection of one user leaves the other alone
+	if err := d.SwitchConnection(2, "sales"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.GetCurrentDatabase(2); !errors.Is(err, ErrEmptyDriver) {
+		t.Fatalf("expected user 2 to lose the hr snapshot, got %v", err)
+	}
+	if d.CurrentConnection(1) != "sales" {
+		t.Fatalf("expected user 1 to stay on sales, got %q", d.CurrentConnection(1))
+	}
+	if _, err := d.connectionOf(2, "hr"); !errors.Is(err, ErrConnectionMismatch) {
+		t.Fatalf("expected a mismatch for user 2, got %v", err)
+	}
+
+	if err := d.DeleteDatabase(salesID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.GetCurrentDatabase(1); !errors.Is(err, ErrEmptyDriver) {
+		t.Fatalf("expected the deleted snapshot to be unselected, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package database_handler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/audit"
+	db2 "github.com/AliTaghipour1/Talk-to_DB/internal/modules/db"
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/sqlparser"
+)
+
+var (
+	ErrExpensiveQuery = errors.New("the query is too expensive to run")
+	ErrNotAQuery      = errors.New("the generated SQL is not a single SELECT statement")
+)
+
+// CostLimit keeps queries the planner expects to be expensive from running on a connection.
+// Zero limits are disabled.
+type CostLimit struct {
+	MaxRows float64
+	MaxCost float64
+	// Confirm holds expensive queries back until the user confirms them instead of rejecting them
+	Confirm bool
+}
+
+func (l CostLimit) enabled() bool {
+	return l.MaxRows > 0 || l.MaxCost > 0
+}
+
+// exceeded describes how the estimate is over the limits, or returns "" if it is not
+func (l CostLimit) exceeded(estimate db2.Estimate) string {
+	var reasons []string
+	if l.MaxRows > 0 && estimate.Rows > l.MaxRows {
+		reasons = append(reasons, fmt.Sprintf("it is estimated to produce %.0f rows in a step, the limit is %.0f", estimate.Rows, l.MaxRows))
+	}
+	if l.MaxCost > 0 && estimate.Cost > l.MaxCost {
+		reasons = append(reasons, fmt.Sprintf("its estimated cost is %.0f, the limit is %.0f", estimate.Cost, l.MaxCost))
+	}
+	if len(reasons) == 0 {
+		return ""
+	}
+
+	reason := strings.Join(reasons, " and ")
+	if len(estimate.FullScans) > 0 {
+		reason += " (full scan of " + strings.Join(estimate.FullScans, ", ") + ")"
+	}
+	return reason
+}
+
+// PendingQuery is a generated query held back until the user confirms it, see ExecuteConfirmed
+type PendingQuery struct {
+	// Reason tells why the query needs confirmation
+	Reason     string
+	entry      audit.Entry
+	connection string
+	executed   string
+	settings   map[string]string
+	model      string
+	sqlKey     sqlCacheKey
+}
+
+// SQL returns the generated query
+func (p PendingQuery) SQL() string {
+	return p.entry.SQL
+}
+
+// Connection returns the name of the connection the query runs on
+func (p PendingQuery) Connection() string {
+	return p.connection
+}
+
+func (p PendingQuery) resultKey(role string) resultCacheKey {
+	return newResultCacheKey(p.connection, role, p.executed, p.settings)
+}
+
+// answer renders the answer to the question of the audit entry
+func (p PendingQuery) answer(entry audit.Entry, result string) QueryAnswer {
+	return QueryAnswer{
+		DatabaseID: entry.DatabaseID,
+		Question:   entry.Question,
+		SQL:        entry.SQL,
+		Model:      p.model,
+		Result:     result,
+	}
+}
+
+// ConfirmationRequiredError is returned for queries over the cost limit of a connection that
+// runs them once confirmed
+type ConfirmationRequiredError struct {
+	Pending PendingQuery
+}
+
+func (e *ConfirmationRequiredError) Error() string {
+	return fmt.Sprintf("%v: %s, confirm to run it anyway", ErrExpensiveQuery, e.Pending.Reason)
+}
+
+func (e *ConfirmationRequiredError) Unwrap() error {
+	return ErrExpensiveQuery
+}
+
+// checkSingleRead accepts exactly one SELECT statement. Without parameters the drivers send
+// the text as is, so every statement of SELECT 1; SELECT * FROM huge would run, also the ones
+// after the statement EXPLAIN looks at.
+func checkSingleRead(query string, driver config.Driver) error {
+	statements, err := sqlparser.Parse(query, sqlDialect(driver))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotAQuery, err)
+	}
+	if len(statements) != 1 || !readStatements[statements[0].Kind] {
+		return ErrNotAQuery
+	}
+	return nil
+}
+
+// checkCost explains the query and rejects it, or holds it back for confirmation, if the
+// estimate is over the cost limit of the connection
+func (d *DatabaseHandler) checkCost(connection Connection, pending PendingQuery, entry *audit.Entry) error {
+	if !connection.CostLimit.enabled() {
+		return nil
+	}
+
+	estimate, err := connection.Database.Explain(pending.settings, pending.executed)
+	if err != nil {
+		return fmt.Errorf(`error explaining query on %s (%s): %v`, connection.Name, connection.Database.Health().Status, err)
+	}
+
+	reason := connection.CostLimit.exceeded(estimate)
+	if reason == "" {
+		return nil
+	}
+	if !connection.CostLimit.Confirm {
+		entry.Decisions = append(entry.Decisions, "rejected by the cost limit: "+reason)
+		return fmt.Errorf("%w: %s", ErrExpensiveQuery, reason)
+	}
+
+	entry.Decisions = append(entry.Decisions, "held back for confirmation: "+reason)
+	pending.Reason = reason
+	pending.entry = *entry
+	return &ConfirmationRequiredError{Pending: pending}
+}
+
+// ExecuteConfirmed runs a query held back by the cost limit after the user confirmed it
+func (d *DatabaseHandler) ExecuteConfirmed(pending PendingQuery) (answer QueryAnswer, err error) {
+	connection, err := d.connectionOf(pending.entry.UserID, pending.connection)
+	if err != nil {
+		return QueryAnswer{}, err
+	}
+
+	entry := pending.entry
+	entry.Time = time.Now()
+	entry.Error = ""
+	entry.Decisions = append(entry.Decisions[:len(entry.Decisions):len(entry.Decisions)], "run after the user confirmed it")
+	defer func() {
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		d.recordAudit(entry)
+	}()
+
+	return d.run(connection, pending, &entry)
+}
@@ -0,0 +1,51 @@
+package database_handler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
+	db2 "github.com/AliTaghipour1/Talk-to_DB/internal/modules/db"
+)
+
+func TestCostLimit_Exceeded(t *testing.T) {
+	limit := CostLimit{MaxRows: 1000000, MaxCost: 50000}
+
+	if reason := limit.exceeded(db2.Estimate{Rows: 1000, Cost: 40000}); reason != "" {
+		t.Fatalf("expected the estimate to be within the limit, got %q", reason)
+	}
+
+	reason := limit.exceeded(db2.Estimate{Rows: 2000000, Cost: 90000, FullScans: []string{"transfers"}})
+	for _, expected := range []string{"2000000 rows", "cost is 90000", "full scan of transfers"} {
+		if !strings.Contains(reason, expected) {
+			t.Fatalf("expected %q in %q", expected, reason)
+		}
+	}
+
+	if (CostLimit{}).enabled() {
+		t.Fatal("a zero limit must be disabled")
+	}
+}
+
+func TestCheckSingleRead(t *testing.T) {
+	for _, query := range []string{
+		"SELECT count(*) FROM transfers",
+		"WITH t AS (SELECT * FROM transfers) SELECT amount FROM t;",
+	} {
+		if err := checkSingleRead(query, config.Postgres); err != nil {
+			t.Fatalf("expected %q to be accepted, got %v", query, err)
+		}
+	}
+
+	for _, query := range []string{
+		"SELECT 1; SELECT * FROM huge",
+		"SHOW ALL",
+		"SELECT 'open",
+		"",
+	} {
+		if err := checkSingleRead(query, config.Postgres); !errors.Is(err, ErrNotAQuery) {
+			t.Fatalf("expected %q to be rejected, got %v", query, err)
+		}
+	}
+}
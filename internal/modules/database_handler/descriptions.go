@@ -0,0 +1,141 @@
+package database_handler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+	"gopkg.in/yaml.v3"
+)
+
+// DescriptionsDocument is the file format used for bulk editing descriptions.
+// JSON documents with the same keys are accepted as well since JSON is valid YAML.
+type DescriptionsDocument struct {
+	Database string                `yaml:"database" json:"database"`
+	Tables   []TableDescriptionDoc `yaml:"tables" json:"tables"`
+}
+
+type TableDescriptionDoc struct {
+	Name        string                 `yaml:"name" json:"name"`
+	Description string                 `yaml:"description" json:"description"`
+	Columns     []ColumnDescriptionDoc `yaml:"columns,omitempty" json:"columns,omitempty"`
+}
+
+type ColumnDescriptionDoc struct {
+	Name        string `yaml:"name" json:"name"`
+	DataType    string `yaml:"type,omitempty" json:"type,omitempty"`
+	Description string `yaml:"description" json:"description"`
+}
+
+var ErrEmptyDescriptionsDocument = errors.New("descriptions document has no tables")
+
+// ExportDescriptions returns a YAML document of every table and column of the database the
+// user selected
+func (d *DatabaseHandler) ExportDescriptions(userID int64) (string, []byte, error) {
+	currentDatabase, err := d.currentDatabase(userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	document := DescriptionsDocument{Database: currentDatabase.Name}
+	for _, table := range currentDatabase.Tables {
+		tableDoc := TableDescriptionDoc{
+			Name:        table.Name,
+			Description: table.Description,
+		}
+		for _, column := range table.Columns {
+			tableDoc.Columns = append(tableDoc.Columns, ColumnDescriptionDoc{
+				Name:        column.Name,
+				DataType:    column.DataType,
+				Description: column.Description,
+			})
+		}
+		document.Tables = append(document.Tables, tableDoc)
+	}
+
+	data, err := yaml.Marshal(document)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal descriptions: %w", err)
+	}
+
+	fileName := fmt.Sprintf("%s-%d-descriptions.yaml", currentDatabase.Name, currentDatabase.ID)
+	return fileName, data, nil
+}
+
+// ImportDescriptions parses an edited descriptions document and applies every changed
+// description to the database the user selected at once. It returns the number of applied changes.
+func (d *DatabaseHandler) ImportDescriptions(userID int64, data []byte) (int, error) {
+	currentDatabase, err := d.currentDatabase(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	var document DescriptionsDocument
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return 0, fmt.Errorf("failed to parse descriptions document: %w", err)
+	}
+	if len(document.Tables) == 0 {
+		return 0, ErrEmptyDescriptionsDocument
+	}
+
+	changes, err := buildDescriptionChanges(currentDatabase, document)
+	if err != nil {
+		return 0, err
+	}
+	if len(changes) == 0 {
+		return 0, nil
+	}
+
+	err = d.databaseRepo.SetDescriptions(currentDatabase.ID, changes)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(changes), nil
+}
+
+// buildDescriptionChanges validates the document against the repo database and returns
+// the changes for descriptions that differ from the stored ones.
+// All unknown tables and columns are reported together.
+func buildDescriptionChanges(database repo.Database, document DescriptionsDocument) ([]repo.DescriptionChange, error) {
+	var changes []repo.DescriptionChange
+	var problems []string
+	for _, tableDoc := range document.Tables {
+		table, found := database.GetTableByName(tableDoc.Name)
+		if !found {
+			problems = append(problems, fmt.Sprintf("unknown table %q", tableDoc.Name))
+			continue
+		}
+
+		if tableDoc.Description != table.Description {
+			changes = append(changes, repo.DescriptionChange{
+				FieldID:     table.ID,
+				FieldType:   repo.TableFieldType,
+				Description: tableDoc.Description,
+			})
+		}
+
+		for _, columnDoc := range tableDoc.Columns {
+			column, columnFound := table.GetColumnByName(columnDoc.Name)
+			if !columnFound {
+				problems = append(problems, fmt.Sprintf("unknown column %q in table %q", columnDoc.Name, tableDoc.Name))
+				continue
+			}
+
+			if columnDoc.Description != column.Description {
+				changes = append(changes, repo.DescriptionChange{
+					FieldID:     column.ID,
+					FieldType:   repo.ColumnFieldType,
+					Description: columnDoc.Description,
+				})
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid descriptions document:\n%s", strings.Join(problems, "\n"))
+	}
+
+	return changes, nil
+}
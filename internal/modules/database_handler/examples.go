@@ -0,0 +1,86 @@
+package database_handler
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/ai"
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+)
+
+const (
+	maxFewShotExamples       = 3
+	minimumExampleSimilarity = 0.2
+)
+
+// SaveExample stores a question and the generated SQL as a verified example of the
+// database the answer came from
+func (d *DatabaseHandler) SaveExample(answer QueryAnswer, userID int64) error {
+	_, err := d.databaseRepo.AddExample(answer.DatabaseID, repo.Example{
+		Question:   answer.Question,
+		SQL:        answer.SQL,
+		VerifiedBy: userID,
+	})
+	return err
+}
+
+// similarExamples returns the examples most similar to the question, measured by the
+// Jaccard similarity of their words
+func similarExamples(examples []repo.Example, question string) []ai.Example {
+	questionWords := wordSet(question)
+
+	type scoredExample struct {
+		example repo.Example
+		score   float64
+	}
+	var scored []scoredExample
+	for _, example := range examples {
+		score := jaccardSimilarity(questionWords, wordSet(example.Question))
+		if score >= minimumExampleSimilarity {
+			scored = append(scored, scoredExample{example: example, score: score})
+		}
+	}
+
+	slices.SortStableFunc(scored, func(a, b scoredExample) int {
+		if a.score > b.score {
+			return -1
+		}
+		if a.score < b.score {
+			return 1
+		}
+		return 0
+	})
+
+	var result []ai.Example
+	for _, item := range scored[:min(len(scored), maxFewShotExamples)] {
+		result = append(result, ai.Example{
+			Question: item.example.Question,
+			SQL:      item.example.SQL,
+		})
+	}
+
+	return result
+}
+
+func wordSet(text string) map[string]bool {
+	result := make(map[string]bool)
+	for _, word := range strings.Fields(normalizeText(text)) {
+		result[word] = true
+	}
+	return result
+}
+
+func jaccardSimilarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	intersection := 0
+	for word := range a {
+		if b[word] {
+			intersection++
+		}
+	}
+
+	return float64(intersection) / float64(len(a)+len(b)-intersection)
+}
@@ -0,0 +1,71 @@
+package database_handler
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+)
+
+func TestSimilarExamples(t *testing.T) {
+	tests := []struct {
+		name     string
+		examples []string
+		question string
+		expected []string
+	}{
+		{
+			name:     "ranks by similarity",
+			examples: []string{"how many orders today", "users signed up today", "how many users signed up yesterday"},
+			question: "how many users signed up today",
+			expected: []string{"how many users signed up yesterday", "users signed up today", "how many orders today"},
+		},
+		{
+			name:     "keeps the most similar examples",
+			examples: []string{"how many orders today", "users signed up today", "How many USERS signed up today?", "how many users signed up yesterday"},
+			question: "how many users signed up today",
+			expected: []string{"How many USERS signed up today?", "how many users signed up yesterday", "users signed up today"},
+		},
+		{
+			name:     "drops examples below the minimum similarity",
+			examples: []string{"total revenue today", "users signed up today"},
+			question: "how many users signed up today",
+			expected: []string{"users signed up today"},
+		},
+		{
+			name:     "keeps examples at the minimum similarity",
+			examples: []string{"a", "a f"},
+			question: "a b c d e",
+			expected: []string{"a"},
+		},
+		{
+			name:     "keeps the order of equally similar examples",
+			examples: []string{"active users today", "new users today"},
+			question: "users today",
+			expected: []string{"active users today", "new users today"},
+		},
+		{
+			name:     "returns nothing for an empty question",
+			examples: []string{"users signed up today"},
+			question: "?",
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var examples []repo.Example
+			for _, question := range test.examples {
+				examples = append(examples, repo.Example{Question: question, SQL: "SELECT 1"})
+			}
+
+			var questions []string
+			for _, example := range similarExamples(examples, test.question) {
+				questions = append(questions, example.Question)
+			}
+			if !slices.Equal(questions, test.expected) {
+				t.Fatalf("expected %q, got %q", test.expected, questions)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package database_handler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+)
+
+// AccuracyStats is the share of positive feedbacks of a database or a model
+type AccuracyStats struct {
+	Name     string
+	Positive int
+	Total    int
+}
+
+func (s AccuracyStats) Accuracy() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Positive) / float64(s.Total) * 100
+}
+
+// FeedbackStats is the accuracy per database and per model in a period of time
+type FeedbackStats struct {
+	Period     string
+	ByDatabase []AccuracyStats
+	ByModel    []AccuracyStats
+}
+
+var feedbackStatsPeriods = []struct {
+	name     string
+	duration time.Duration
+}{
+	{name: "Last 7 days", duration: 7 * 24 * time.Hour},
+	{name: "Last 30 days", duration: 30 * 24 * time.Hour},
+	{name: "All time"},
+}
+
+// RecordFeedback stores a user's judgement of an answer and returns the feedback ID. Wrong
+// SQL is not reused for the question.
+func (d *DatabaseHandler) RecordFeedback(answer QueryAnswer, userID int64, positive bool) (int, error) {
+	if !positive {
+		d.cache.forgetSQL(answer.DatabaseID, answer.Question)
+	}
+	return d.databaseRepo.AddFeedback(repo.Feedback{
+		DatabaseID: answer.DatabaseID,
+		UserID:     userID,
+		Question:   answer.Question,
+		SQL:        answer.SQL,
+		Model:      answer.Model,
+		Positive:   positive,
+	})
+}
+
+func (d *DatabaseHandler) SetFeedbackCorrection(feedbackID int, correction string) error {
+	return d.databaseRepo.SetFeedbackCorrection(feedbackID, strings.TrimSpace(correction))
+}
+
+func (d *DatabaseHandler) GetFeedbackStats() ([]FeedbackStats, error) {
+	feedbacks, err := d.databaseRepo.GetFeedbacks(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	databases, err := d.databaseRepo.GetAllDatabases()
+	if err != nil {
+		return nil, err
+	}
+	databaseNames := make(map[int]string)
+	for _, database := range databases {
+		databaseNames[database.ID] = fmt.Sprintf("%s (#%d)", database.Name, database.ID)
+	}
+
+	now := time.Now()
+	var result []FeedbackStats
+	for _, period := range feedbackStatsPeriods {
+		byDatabase := make(map[string]*AccuracyStats)
+		byModel := make(map[string]*AccuracyStats)
+		for _, feedback := range feedbacks {
+			if period.duration != 0 && feedback.CreatedAt.Before(now.Add(-period.duration)) {
+				continue
+			}
+
+			databaseName, ok := databaseNames[feedback.DatabaseID]
+			if !ok {
+				databaseName = fmt.Sprintf("deleted (#%d)", feedback.DatabaseID)
+			}
+			addFeedbackToStats(byDatabase, databaseName, feedback.Positive)
+			addFeedbackToStats(byModel, feedback.Model, feedback.Positive)
+		}
+
+		result = append(result, FeedbackStats{
+			Period:     period.name,
+			ByDatabase: sortedAccuracyStats(byDatabase),
+			ByModel:    sortedAccuracyStats(byModel),
+		})
+	}
+
+	return result, nil
+}
+
+func addFeedbackToStats(stats map[string]*AccuracyStats, name string, positive bool) {
+	item, ok := stats[name]
+	if !ok {
+		item = &AccuracyStats{Name: name}
+		stats[name] = item
+	}
+
+	item.Total++
+	if positive {
+		item.Positive++
+	}
+}
+
+func sortedAccuracyStats(stats map[string]*AccuracyStats) []AccuracyStats {
+	var result []AccuracyStats
+	for _, item := range stats {
+		result = append(result, *item)
+	}
+
+	slices.SortFunc(result, func(a, b AccuracyStats) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return result
+}
@@ -0,0 +1,90 @@
+package database_handler
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/ai"
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+)
+
+var ErrEmptyGlossaryTerm = errors.New("glossary term must not be empty")
+
+func (d *DatabaseHandler) SetDatabaseDescription(userID int64, description string) error {
+	currentDatabase, err := d.currentDatabase(userID)
+	if err != nil {
+		return err
+	}
+
+	return d.databaseRepo.SetDescription(currentDatabase.ID, description, currentDatabase.ID, repo.DatabaseFieldType)
+}
+
+func (d *DatabaseHandler) GetGlossary(userID int64) ([]GlossaryTerm, error) {
+	database, err := d.GetCurrentDatabase(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return database.Glossary, nil
+}
+
+func (d *DatabaseHandler) SetGlossaryTerm(userID int64, term GlossaryTerm) error {
+	currentDatabase, err := d.currentDatabase(userID)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(term.Term) == "" {
+		return ErrEmptyGlossaryTerm
+	}
+
+	_, err = d.databaseRepo.SetGlossaryTerm(currentDatabase.ID, repo.GlossaryTerm{
+		Term:       strings.TrimSpace(term.Term),
+		Definition: strings.TrimSpace(term.Definition),
+		SQL:        strings.TrimSpace(term.SQL),
+	})
+	return err
+}
+
+func (d *DatabaseHandler) DeleteGlossaryTerm(userID int64, term string) error {
+	currentDatabase, err := d.currentDatabase(userID)
+	if err != nil {
+		return err
+	}
+
+	return d.databaseRepo.DeleteGlossaryTerm(currentDatabase.ID, strings.TrimSpace(term))
+}
+
+// relevantGlossary returns the glossary entries whose term is mentioned in the question
+func (s Database) relevantGlossary(question string) []ai.GlossaryEntry {
+	normalizedQuestion := normalizeText(question)
+
+	var result []ai.GlossaryEntry
+	for _, term := range s.Glossary {
+		normalizedTerm := normalizeText(term.Term)
+		if normalizedTerm == "" || !strings.Contains(normalizedQuestion, normalizedTerm) {
+			continue
+		}
+
+		result = append(result, ai.GlossaryEntry{
+			Term:       term.Term,
+			Definition: term.Definition,
+			SQL:        term.SQL,
+		})
+	}
+
+	return result
+}
+
+// normalizeText lower-cases the text and replaces punctuation with spaces, padding the
+// result with spaces so that terms can be matched on word boundaries
+func normalizeText(text string) string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return " " + strings.Join(fields, " ") + " "
+}
@@ -3,58 +3,80 @@ package database_handler
 import (
 	"errors"
 	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
 	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/ai"
-	db2 "github.com/AliTaghipour1/Talk-to_DB/internal/modules/db"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/audit"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/masking"
 	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
 )
 
 type DatabaseHandler struct {
-	allowedUserIds    []int64
-	databases         map[config.Driver]db2.Database
-	currentDriver     config.Driver
-	currentDatabase   *repo.Database
-	currentDatabaseID *int
-	databaseRepo      repo.DatabaseRepo
-	aiModule          *ai.AIModule
+	// mu guards connections and policies, which change when the config is reloaded, and
+	// the selections of the users
+	mu          sync.RWMutex
+	connections map[string]Connection
+	policies    map[string]AccessPolicy // by role
+	// selections are the connection and snapshot each user works with, by user
+	selections   map[int64]selection
+	masker       *masking.Masker
+	databaseRepo repo.DatabaseRepo
+	aiModule     *ai.AIModule
+	auditLog     audit.Log
+	writes       *writeRequests
+	cache        *answerCache
 }
 
-func NewDatabaseHandler(allowedUserIds []int64, databases map[config.Driver]db2.Database, databaseRepo repo.DatabaseRepo,
-	aiModule *ai.AIModule) *DatabaseHandler {
+func NewDatabaseHandler(connections []Connection, policies map[string]AccessPolicy, masker *masking.Masker,
+	databaseRepo repo.DatabaseRepo, aiModule *ai.AIModule, auditLog audit.Log) *DatabaseHandler {
+	connectionsByName := make(map[string]Connection, len(connections))
+	for _, connection := range connections {
+		connectionsByName[connection.Name] = connection
+	}
+
 	return &DatabaseHandler{
-		allowedUserIds: allowedUserIds,
-		databases:      databases,
-		databaseRepo:   databaseRepo,
-		aiModule:       aiModule,
+		connections:  connectionsByName,
+		policies:     policies,
+		selections:   make(map[int64]selection),
+		masker:       masker,
+		databaseRepo: databaseRepo,
+		aiModule:     aiModule,
+		auditLog:     auditLog,
+		writes:       newWriteRequests(),
+		cache:        newAnswerCache(),
 	}
 }
 
 var (
-	ErrEmptyDriver  = errors.New("no available database driver")
-	ErrNotConnected = errors.New("not connected")
+	ErrEmptyDriver        = errors.New("no connection is selected, choose one with /connect")
+	ErrNotConnected       = errors.New("not connected")
+	ErrUnknownConnection  = errors.New("connection is not configured")
+	ErrConnectionMismatch = errors.New("database belongs to another connection")
 )
 
-func (d *DatabaseHandler) HandleChoosingDatabase(databaseID int) error {
-	if d.currentDriver == "" {
-		return ErrEmptyDriver
+// HandleChoosingDatabase selects the snapshot for the user and switches to the connection it
+// was taken from
+func (d *DatabaseHandler) HandleChoosingDatabase(userID int64, databaseID int) (string, error) {
+	database, err := d.databaseRepo.GetDatabase(databaseID)
+	if err != nil {
+		return "", err
 	}
 
-	_, err := d.databaseRepo.GetDatabase(databaseID)
-	if err != nil {
-		return err
+	if _, err := d.lookupConnection(database.Connection); err != nil {
+		return "", fmt.Errorf("can not use database %s: %w", database.Name, err)
 	}
 
-	d.currentDatabaseID = &databaseID
+	d.setSelection(userID, selection{connection: database.Connection, databaseID: &databaseID})
 
-	return nil
+	return database.Connection, nil
 }
 
 func (d *DatabaseHandler) GetDatabases() ([]Database, error) {
-	if d.currentDriver == "" {
-		return nil, ErrEmptyDriver
-	}
-
 	databases, err := d.databaseRepo.GetAllDatabases()
 	if err != nil {
 		return nil, err
@@ -63,12 +85,14 @@ func (d *DatabaseHandler) GetDatabases() ([]Database, error) {
 	return convertRepoDatabasesToModuleModel(databases), nil
 }
 
-func (d *DatabaseHandler) GetCurrentDatabase() (Database, error) {
-	if d.currentDatabaseID == nil {
+// GetCurrentDatabase returns the snapshot the user selected
+func (d *DatabaseHandler) GetCurrentDatabase(userID int64) (Database, error) {
+	current := d.selectionOf(userID)
+	if current.databaseID == nil {
 		return Database{}, ErrEmptyDriver
 	}
 
-	currentDatabase, err := d.databaseRepo.GetDatabase(*d.currentDatabaseID)
+	currentDatabase, err := d.databaseRepo.GetDatabase(*current.databaseID)
 	if err != nil {
 		return Database{}, err
 	}
@@ -77,19 +101,23 @@ func (d *DatabaseHandler) GetCurrentDatabase() (Database, error) {
 	return db[0], nil
 }
 
-func (d *DatabaseHandler) CreateDatabase() (int, error) {
-	if d.currentDriver == "" {
-		return 0, ErrEmptyDriver
+// CreateDatabase takes a snapshot of the connection the user selected
+func (d *DatabaseHandler) CreateDatabase(userID int64) (int, error) {
+	connection, err := d.getCurrentConnection(userID)
+	if err != nil {
+		return 0, err
 	}
 
-	tables, err := d.databases[d.currentDriver].GetTables()
+	tables, err := connection.Database.GetTables()
 	if err != nil {
 		return 0, err
 	}
 
 	db := &repo.Database{
-		Name:   string(d.currentDriver),
-		Tables: tables.ToRepositoryTableList(),
+		Name:       connection.Name,
+		Driver:     string(connection.Driver),
+		Connection: connection.Name,
+		Tables:     tables.ToRepositoryTableList(),
 	}
 	databaseID, err := d.databaseRepo.CreateNewDatabase(db)
 	if err != nil {
@@ -100,54 +128,204 @@ func (d *DatabaseHandler) CreateDatabase() (int, error) {
 
 }
 
-func (d *DatabaseHandler) SwitchDriver(db string) error {
-	switch db {
-	case "postgres":
-		d.currentDriver = config.Postgres
-	case "mysql":
-		d.currentDriver = config.MySQL
-	case "cockroach":
-		d.currentDriver = config.Cockroach
-	default:
-		return errors.New("unknown database driver")
-	}
+// SetAccessPolicies replaces the policies of the roles, e.g. when the config is reloaded
+func (d *DatabaseHandler) SetAccessPolicies(policies map[string]AccessPolicy) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.policies = policies
+}
 
-	return nil
+// ForgetCachedResults drops every cached result, which was read and masked by the rules
+// in force at the time, e.g. when the config is reloaded
+func (d *DatabaseHandler) ForgetCachedResults() {
+	d.cache.forgetAllResults()
+}
+
+func (d *DatabaseHandler) accessPolicy(role string) AccessPolicy {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.policies[role]
 }
 
-func (d *DatabaseHandler) Query(text string) (string, error) {
-	if d.currentDatabaseID == nil {
-		return "", ErrNotConnected
+// Query answers the question, on the snapshot the requester selected, with SQL generated from the part of the schema the role of the
+// requester may see, reading only the rows the row filters of the role let through. Every
+// question asked from a database is recorded in the audit log. SQL generated for the same
+// question and schema before is reused, as are results of connections that cache them,
+// unless fresh is set.
+func (d *DatabaseHandler) Query(text string, requester Requester, fresh bool) (QueryAnswer, error) {
+	currentDatabase, err := d.currentDatabase(requester.UserID)
+	if err != nil {
+		return QueryAnswer{}, err
 	}
 
-	currentDatabase, err := d.databaseRepo.GetDatabase(*d.currentDatabaseID)
+	connection, err := d.connectionOf(requester.UserID, currentDatabase.Connection)
 	if err != nil {
-		return "", err
+		return QueryAnswer{}, err
+	}
+
+	return d.answer(currentDatabase, connection, question{text: text, fresh: fresh}, requester)
+}
+
+// question is what answer is asked
+type question struct {
+	text string
+	// pinnedSQL is run instead of SQL generated for the text if set
+	pinnedSQL string
+	fresh     bool
+	// decisions are recorded in the audit entry before any other
+	decisions []string
+}
+
+// answer runs the question through the pipeline of Query on the snapshot of the connection
+func (d *DatabaseHandler) answer(currentDatabase repo.Database, connection Connection, q question, requester Requester) (answer QueryAnswer, err error) {
+	text, fresh := q.text, q.fresh
+	entry := audit.Entry{
+		Time:       time.Now(),
+		UserID:     requester.UserID,
+		Role:       requester.Role,
+		Connection: connection.Name,
+		DatabaseID: currentDatabase.ID,
+		Question:   text,
+		Decisions:  slices.Clone(q.decisions),
 	}
+	defer func() {
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		d.recordAudit(entry)
+	}()
 
+	policy := d.accessPolicy(requester.Role)
 	database := convertRepoDatabaseToModuleModel(currentDatabase)
+	visibleDatabase := policy.filterDatabase(database)
+	if !policy.hidesNothing() && q.pinnedSQL == "" {
+		entry.Decisions = append(entry.Decisions, "hid denied tables and columns from the model")
+	}
+
+	request := ai.QueryRequest{
+		UserID:   requester.UserID,
+		Schema:   visibleDatabase.Scheme(),
+		Glossary: visibleDatabase.relevantGlossary(text),
+		Examples: similarExamples(policy.allowedExamples(currentDatabase.Examples, database, connection.Driver), text),
+		Question: text,
+	}
+	sqlKey := newSQLCacheKey(currentDatabase.ID, request)
+	query, cached := d.cache.getSQL(sqlKey)
+	switch {
+	case q.pinnedSQL != "":
+		query = q.pinnedSQL
+		sqlKey = sqlCacheKey{}
+		entry.Decisions = append(entry.Decisions, "ran the pinned SQL")
+	case cached && !fresh:
+		entry.Decisions = append(entry.Decisions, "reused the SQL generated for the question before")
+	default:
+		query = d.aiModule.GetQuery(request)
+	}
+	entry.SQL = query
+
+	if isWrite(query, connection.Driver) {
+		entry.Decisions = append(entry.Decisions, "rejected as a write")
+		return QueryAnswer{}, ErrWriteInQuery
+	}
+	if err := checkSingleRead(query, connection.Driver); err != nil {
+		entry.Decisions = append(entry.Decisions, "rejected as not a single SELECT statement")
+		return QueryAnswer{}, err
+	}
+	if err := policy.checkQuery(query, database, connection.Driver); err != nil {
+		log.Printf("rejected query for role %s: %v\n%s", requester.Role, err, query)
+		entry.Decisions = append(entry.Decisions, "rejected by the deny rules")
+		return QueryAnswer{}, err
+	}
+	if err := checkMaskedColumns(query, d.masker.ForRole(requester.Role).Columns(), database, connection.Driver); err != nil {
+		log.Printf("rejected query for role %s: %v\n%s", requester.Role, err, query)
+		entry.Decisions = append(entry.Decisions, "rejected by the masking rules")
+		return QueryAnswer{}, err
+	}
+
+	executed, err := policy.applyRowFilters(query, connection.Driver, requester.Attributes)
+	if err != nil {
+		log.Printf("rejected query for role %s: %v\n%s", requester.Role, err, query)
+		entry.Decisions = append(entry.Decisions, "rejected by the row filters")
+		return QueryAnswer{}, err
+	}
+	if executed != query {
+		entry.Decisions = append(entry.Decisions, "filtered rows: "+executed)
+	}
+	settings, err := policy.sessionSettings(executed, readStatements, connection.Driver, requester.Attributes)
+	if err != nil {
+		log.Printf("rejected query for role %s: %v\n%s", requester.Role, err, executed)
+		entry.Decisions = append(entry.Decisions, "rejected by the settings of the role")
+		return QueryAnswer{}, err
+	}
+	if len(settings) > 0 {
+		entry.Decisions = append(entry.Decisions, "set "+strings.Join(slices.Sorted(maps.Keys(settings)), ", "))
+	}
+
+	pending := PendingQuery{
+		connection: connection.Name,
+		executed:   executed,
+		settings:   settings,
+		model:      d.aiModule.Model(),
+		sqlKey:     sqlKey,
+	}
+	if result, ok := d.cache.getResult(pending.resultKey(entry.Role)); ok && !fresh {
+		entry.Decisions = append(entry.Decisions, "answered with the result cached at "+result.cachedAt.Format(time.RFC3339))
+		entry.Rows = result.rows
+		answer := pending.answer(entry, result.result)
+		answer.CachedAt = result.cachedAt
+		return answer, nil
+	}
+	if err := d.checkCost(connection, pending, &entry); err != nil {
+		return QueryAnswer{}, err
+	}
+
+	return d.run(connection, pending, &entry)
+}
 
-	query := d.aiModule.GetQuery(database.Scheme(), text)
+// run executes the query and renders its result for the role of the audit entry
+func (d *DatabaseHandler) run(connection Connection, pending PendingQuery, entry *audit.Entry) (QueryAnswer, error) {
+	started := time.Now()
+	defer func() { entry.Duration = time.Since(started) }()
 
-	rows, err := d.databases[d.currentDriver].Query(query)
+	// Queries always run in a read-only transaction, changes go through RequestWrite
+	rows, err := connection.Database.QueryWithSettings(pending.settings, pending.executed)
 	if err != nil {
-		return "", fmt.Errorf(`error executing query: %v`, err)
+		return QueryAnswer{}, fmt.Errorf(`error executing query on %s (%s): %v`, connection.Name, connection.Database.Health().Status, err)
 	}
 	defer rows.Close()
 
-	result, err := rows.Json()
+	result, rowCount, err := rows.Json(d.masker.ForRole(entry.Role))
 	if err != nil {
-		return "", fmt.Errorf("Error converting result to json: %v", err)
+		return QueryAnswer{}, fmt.Errorf("Error converting result to json: %v", err)
 	}
+	entry.Rows = rowCount
 
-	return result, nil
+	// Only SQL that ran is worth asking again
+	if pending.sqlKey != (sqlCacheKey{}) {
+		d.cache.putSQL(pending.sqlKey, entry.SQL)
+	}
+	if connection.ResultCacheTTL > 0 {
+		d.cache.putResult(pending.resultKey(entry.Role), result, rowCount, connection.ResultCacheTTL)
+	}
+
+	return pending.answer(*entry, result), nil
 }
 
-func (d *DatabaseHandler) SetDescription(tableName string, columnName *string, description string) error {
-	if d.currentDatabaseID == nil {
-		return ErrNotConnected
+// recordAudit writes the entry to the audit log. A failing log is reported but doesn't keep
+// the answer from the user.
+func (d *DatabaseHandler) recordAudit(entry audit.Entry) {
+	if err := d.auditLog.Record(entry); err != nil {
+		log.Printf("failed to record audit entry: %v", err)
 	}
-	currentDatabase, err := d.databaseRepo.GetDatabase(*d.currentDatabaseID)
+}
+
+// SearchAudit returns the most recent audit entries matching the filter
+func (d *DatabaseHandler) SearchAudit(filter audit.Filter) ([]audit.Entry, error) {
+	return d.auditLog.Search(filter)
+}
+
+func (d *DatabaseHandler) SetDescription(userID int64, tableName string, columnName *string, description string) error {
+	currentDatabase, err := d.currentDatabase(userID)
 	if err != nil {
 		return err
 	}
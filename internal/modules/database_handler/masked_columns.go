@@ -0,0 +1,86 @@
+package database_handler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/sqlparser"
+)
+
+// checkMaskedColumns rejects queries whose result may carry a masked column under another
+// name. Column rules mask result columns by name, so a masked column may only be selected as
+// a whole item without an alias. Aliased, wrapped or renamed, as in card_number AS c,
+// substr(card_number, 1, 16) or a subquery with column aliases, its values would be shown in
+// clear. Conditions and other uses outside of select lists are left alone.
+func checkMaskedColumns(query string, masked []string, database Database, driver config.Driver) error {
+	if len(masked) == 0 {
+		return nil
+	}
+
+	statements, err := sqlparser.Parse(query, sqlDialect(driver))
+	if err != nil {
+		return fmt.Errorf("%w: it could not be checked: %v", ErrAccessDenied, err)
+	}
+
+	for _, statement := range statements {
+		var violation string
+		statement.Scope.Walk(func(scope *sqlparser.Scope) {
+			if violation == "" && !scope.Exists {
+				violation = maskedColumnViolation(scope, masked, database)
+			}
+		})
+		if violation != "" {
+			return fmt.Errorf("%w: it %s, select masked columns as they are", ErrAccessDenied, violation)
+		}
+	}
+
+	return nil
+}
+
+// maskedColumnViolation describes the first use of a masked column in the scope that would
+// not be masked, if any
+func maskedColumnViolation(scope *sqlparser.Scope, masked []string, database Database) string {
+	isMasked := func(name string) bool {
+		return slices.ContainsFunc(masked, func(column string) bool { return strings.EqualFold(column, name) })
+	}
+	// mayHaveMasked reports whether the table may have a masked column, true for derived tables
+	mayHaveMasked := func(table sqlparser.TableRef) bool {
+		return table.Derived || slices.ContainsFunc(masked, func(column string) bool { return hasColumn(database, table.Name, column) })
+	}
+
+	for _, table := range scope.Tables {
+		if table.ColumnAliases && mayHaveMasked(table) {
+			return "renames the columns of " + table.Name
+		}
+	}
+
+	for _, column := range scope.Columns {
+		if !column.Output {
+			continue
+		}
+		if isMasked(column.Name) && (!column.Bare || scope.Renamed) {
+			return "renames or wraps " + column.Name
+		}
+		// A table name used as a value, as in row_to_json(u), carries the whole row
+		if column.Table == "" && !isMasked(column.Name) && slices.ContainsFunc(scope.Lookup(column.Name), mayHaveMasked) {
+			return "reads whole rows of " + column.Name
+		}
+	}
+
+	for _, star := range scope.Stars {
+		if !star.Output || star.Bare && !scope.Renamed {
+			continue
+		}
+		tables := scope.Tables
+		if star.Table != "" {
+			tables = scope.Lookup(star.Table)
+		}
+		if slices.ContainsFunc(tables, mayHaveMasked) {
+			return "renames or wraps the columns selected by *"
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,63 @@
+package database_handler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
+)
+
+func TestCheckMaskedColumns(t *testing.T) {
+	masked := []string{"card_number"}
+	database := Database{Tables: []Table{
+		{Name: "cards", Columns: []Column{{Name: "id"}, {Name: "user_id"}, {Name: "card_number"}}},
+		{Name: "user_account", Columns: []Column{{Name: "id"}, {Name: "nickname"}}},
+	}}
+
+	allowed := []string{
+		"SELECT card_number FROM cards",
+		"SELECT c.card_number, u.nickname FROM cards c JOIN user_account u ON u.id = c.user_id",
+		"SELECT DISTINCT card_number FROM cards WHERE card_number LIKE '6037%' ORDER BY card_number",
+		"SELECT * FROM cards",
+		"SELECT c.* FROM cards c",
+		"SELECT card_number FROM (SELECT card_number FROM cards) s",
+		"WITH c AS (SELECT * FROM cards) SELECT card_number FROM c",
+		"SELECT nickname FROM user_account u WHERE EXISTS (SELECT * FROM cards WHERE user_id = u.id)",
+		"SELECT count(*) FROM cards",
+		"SELECT x FROM user_account AS u(x)",
+	}
+	for _, query := range allowed {
+		if err := checkMaskedColumns(query, masked, database, config.Postgres); err != nil {
+			t.Errorf("%s: %v", query, err)
+		}
+	}
+
+	evading := []string{
+		"SELECT card_number AS c FROM cards",
+		"SELECT card_number c FROM cards",
+		"SELECT substr(card_number, 1, 16) FROM cards",
+		"SELECT card_number || '' FROM cards",
+		"SELECT card_number::text FROM cards",
+		"SELECT CASE WHEN true THEN card_number END FROM cards",
+		"SELECT x FROM (SELECT card_number AS x FROM cards) s",
+		"SELECT x FROM (SELECT card_number FROM cards) s(x)",
+		"SELECT x FROM cards AS c(i, u, x)",
+		"WITH c(x) AS (SELECT card_number FROM cards) SELECT x FROM c",
+		"SELECT (SELECT card_number FROM cards LIMIT 1) AS x",
+		"SELECT nickname FROM user_account UNION SELECT card_number FROM cards",
+		"SELECT row_to_json(c) FROM cards c",
+		"SELECT to_json(c.*) FROM cards c",
+		"SELECT json_agg(s) FROM (SELECT card_number FROM cards) s",
+		"SELECT x FROM cards, unnest(array[card_number]) AS u(x)",
+		"SELECT x FROM (SELECT * FROM cards) s(a, b, x)",
+	}
+	for _, query := range evading {
+		if err := checkMaskedColumns(query, masked, database, config.Postgres); !errors.Is(err, ErrAccessDenied) {
+			t.Errorf("%s: expected access denied, got %v", query, err)
+		}
+	}
+
+	if err := checkMaskedColumns("SELECT card_number AS c FROM cards", nil, database, config.Postgres); err != nil {
+		t.Errorf("expected no check without masked columns, got %v", err)
+	}
+}
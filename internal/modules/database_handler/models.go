@@ -2,6 +2,7 @@ package database_handler
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
 )
@@ -21,8 +22,28 @@ type Column struct {
 type Database struct {
 	ID          int
 	Name        string
+	Driver      string
+	Connection  string
 	Description string
 	Tables      []Table
+	Glossary    []GlossaryTerm `json:"-"` // only relevant terms are sent along with the scheme
+}
+
+// QueryAnswer is the outcome of a natural language question asked from a database
+type QueryAnswer struct {
+	DatabaseID int
+	Question   string
+	SQL        string
+	Model      string
+	Result     string
+	// CachedAt is when the result was cached, zero if the query ran for this answer
+	CachedAt time.Time
+}
+
+type GlossaryTerm struct {
+	Term       string
+	Definition string
+	SQL        string
 }
 
 func (s Database) GetTableByName(name string) (Table, bool) {
@@ -64,11 +85,26 @@ func convertRepoDatabaseToModuleModel(database repo.Database) Database {
 	return Database{
 		ID:          database.ID,
 		Name:        database.Name,
+		Driver:      database.Driver,
+		Connection:  database.Connection,
 		Description: database.Description,
 		Tables:      convertRepoTableToModuleModel(database.Tables),
+		Glossary:    convertRepoGlossaryToModuleModel(database.Glossary),
 	}
 }
 
+func convertRepoGlossaryToModuleModel(terms []repo.GlossaryTerm) []GlossaryTerm {
+	var result []GlossaryTerm
+	for _, term := range terms {
+		result = append(result, GlossaryTerm{
+			Term:       term.Term,
+			Definition: term.Definition,
+			SQL:        term.SQL,
+		})
+	}
+	return result
+}
+
 func convertRepoTableToModuleModel(tables []repo.Table) []Table {
 	var result []Table
 	for _, table := range tables {
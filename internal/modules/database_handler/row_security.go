@@ -0,0 +1,184 @@
+package database_handler
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/sqlparser"
+)
+
+var ErrMissingAttribute = errors.New("your user lacks an attribute your role needs, ask an admin to set it")
+
+// Requester is the user a question is answered for
+type Requester struct {
+	UserID int64
+	Role   string
+	// Attributes are the values the row filters and settings of the role refer to
+	Attributes map[string]string
+}
+
+// readStatements are the statements whose tables can be replaced by filtered subqueries
+var readStatements = map[string]bool{"SELECT": true, "WITH": true}
+
+// applyRowFilters replaces every table of the query that has a row filter with a subquery
+// of the rows the requester may read. The subquery takes over the alias, or the table name
+// if there is none, so the rest of the query reads it like the table.
+func (p AccessPolicy) applyRowFilters(query string, driver config.Driver, attributes map[string]string) (string, error) {
+	if len(p.rowFilters) == 0 {
+		return query, nil
+	}
+
+	dialect := sqlDialect(driver)
+	statements, err := sqlparser.Parse(query, dialect)
+	if err != nil {
+		return "", fmt.Errorf("%w: rows could not be filtered: %v", ErrAccessDenied, err)
+	}
+
+	type replacement struct {
+		start, end int
+		text       string
+	}
+	var replacements []replacement
+	for _, statement := range statements {
+		var tables []sqlparser.TableRef
+		statement.Scope.Walk(func(scope *sqlparser.Scope) {
+			for _, table := range scope.Tables {
+				if _, filtered := p.rowFilters[strings.ToLower(table.Name)]; filtered && !table.Derived {
+					tables = append(tables, table)
+				}
+			}
+		})
+		if len(tables) > 0 && !readStatements[statement.Kind] {
+			return "", fmt.Errorf("%w: rows of %s can only be filtered in SELECT statements", ErrAccessDenied, tables[0].Name)
+		}
+
+		for _, table := range tables {
+			condition, err := bindAttributes(p.rowFilters[strings.ToLower(table.Name)], dialect, attributes)
+			if err != nil {
+				return "", err
+			}
+
+			name := query[table.Start:table.End]
+			text := fmt.Sprintf("(SELECT * FROM %s WHERE %s)", name, condition)
+			if table.Alias == "" {
+				text += " AS " + lastNamePart(name, dialect)
+			}
+			replacements = append(replacements, replacement{start: table.Start, end: table.End, text: text})
+		}
+	}
+
+	// Replace from the end so that the offsets of the remaining tables stay valid
+	slices.SortFunc(replacements, func(a, b replacement) int { return b.start - a.start })
+	for _, r := range replacements {
+		query = query[:r.start] + r.text + query[r.end:]
+	}
+
+	return query, nil
+}
+
+// lastNamePart returns the table name of a name that may be qualified with a schema, as written
+func lastNamePart(name string, dialect sqlparser.Dialect) string {
+	tokens, err := sqlparser.Tokenize(name, dialect)
+	if err != nil || len(tokens) == 0 {
+		return name
+	}
+	last := tokens[len(tokens)-1]
+	return name[last.Pos:last.End]
+}
+
+// bindAttributes replaces the :name placeholders of a condition with the attributes of the
+// requester, quoted as string literals
+func bindAttributes(condition string, dialect sqlparser.Dialect, attributes map[string]string) (string, error) {
+	tokens, err := sqlparser.Tokenize(condition, dialect)
+	if err != nil {
+		return "", fmt.Errorf("invalid row filter %q: %w", condition, err)
+	}
+
+	var bound strings.Builder
+	last := 0
+	for i := 0; i+1 < len(tokens); i++ {
+		colon, name := tokens[i], tokens[i+1]
+		// The placeholder colon may end an operator, as in =:region, but a :: cast is no placeholder
+		if colon.Kind != sqlparser.TokenOperator || !strings.HasSuffix(colon.Value, ":") || strings.HasSuffix(colon.Value, "::") ||
+			name.Kind != sqlparser.TokenWord || name.Pos != colon.End {
+			continue
+		}
+
+		value, ok := attributes[name.Value]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrMissingAttribute, name.Value)
+		}
+		bound.WriteString(condition[last : colon.End-1])
+		bound.WriteString(quoteLiteral(value, dialect))
+		last = name.End
+	}
+	bound.WriteString(condition[last:])
+
+	return "(" + bound.String() + ")", nil
+}
+
+func quoteLiteral(value string, dialect sqlparser.Dialect) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	if dialect == sqlparser.MySQL {
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	// An escape string means the same whatever standard_conforming_strings is set to
+	return "E'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+var settingPlaceholder = regexp.MustCompile(`:([A-Za-z_][A-Za-z0-9_]*)`)
+
+// settingFunctions change settings from within a statement
+var settingFunctions = map[string]bool{"set_config": true}
+
+// sessionSettings returns the settings of the role with the attributes of the requester. The
+// statement they are set for must be a single statement of one of the given kinds that calls
+// no set_config, so that it can not widen what the settings let it see. SET and RESET are no
+// such kinds.
+func (p AccessPolicy) sessionSettings(statement string, kinds map[string]bool, driver config.Driver, attributes map[string]string) (map[string]string, error) {
+	if len(p.settings) == 0 {
+		return nil, nil
+	}
+
+	statements, err := sqlparser.Parse(statement, sqlDialect(driver))
+	if err != nil {
+		return nil, fmt.Errorf("%w: it could not be checked: %v", ErrAccessDenied, err)
+	}
+	if len(statements) != 1 || !kinds[statements[0].Kind] {
+		return nil, fmt.Errorf("%w: only a single %s statement can run with the settings of your role",
+			ErrAccessDenied, strings.Join(slices.Sorted(maps.Keys(kinds)), " or "))
+	}
+	var function string
+	statements[0].Scope.Walk(func(scope *sqlparser.Scope) {
+		for _, name := range scope.Functions {
+			if settingFunctions[strings.ToLower(name)] {
+				function = name
+			}
+		}
+	})
+	if function != "" {
+		return nil, fmt.Errorf("%w: it uses function %s", ErrAccessDenied, function)
+	}
+
+	settings := make(map[string]string, len(p.settings))
+	for name, value := range p.settings {
+		var missing string
+		settings[name] = settingPlaceholder.ReplaceAllStringFunc(value, func(placeholder string) string {
+			attribute, ok := attributes[placeholder[1:]]
+			if !ok {
+				missing = placeholder[1:]
+			}
+			return attribute
+		})
+		if missing != "" {
+			return nil, fmt.Errorf("%w: %s", ErrMissingAttribute, missing)
+		}
+	}
+
+	return settings, nil
+}
@@ -0,0 +1,95 @@
+package database_handler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
+)
+
+func TestAccessPolicy_ApplyRowFilters(t *testing.T) {
+	policy := NewAccessPolicy(nil, map[string]string{"User_Account": "region = :region AND kind::text <> 'bot'"}, nil)
+	attributes := map[string]string{"region": "north's"}
+
+	tests := []struct {
+		query    string
+		driver   config.Driver
+		expected string
+	}{
+		{
+			query:    "SELECT nickname FROM user_account WHERE id = 1",
+			driver:   config.Postgres,
+			expected: "SELECT nickname FROM (SELECT * FROM user_account WHERE (region = E'north''s' AND kind::text <> 'bot')) AS user_account WHERE id = 1",
+		},
+		{
+			query:    "SELECT u.nickname, t.amount FROM transfers t JOIN public.user_account u ON u.id = t.user_id",
+			driver:   config.Postgres,
+			expected: "SELECT u.nickname, t.amount FROM transfers t JOIN (SELECT * FROM public.user_account WHERE (region = E'north''s' AND kind::text <> 'bot')) u ON u.id = t.user_id",
+		},
+		{
+			query:    "SELECT count(*) FROM `user_account`",
+			driver:   config.MySQL,
+			expected: "SELECT count(*) FROM (SELECT * FROM `user_account` WHERE (region = 'north''s' AND kind::text <> 'bot')) AS `user_account`",
+		},
+		{
+			// The name in the body of a CTE is still the table
+			query:    "WITH user_account AS (SELECT * FROM user_account) SELECT * FROM user_account",
+			driver:   config.Postgres,
+			expected: "WITH user_account AS (SELECT * FROM (SELECT * FROM user_account WHERE (region = E'north''s' AND kind::text <> 'bot')) AS user_account) SELECT * FROM user_account",
+		},
+		{
+			query:    "SELECT amount FROM transfers",
+			driver:   config.Postgres,
+			expected: "SELECT amount FROM transfers",
+		},
+	}
+	for _, test := range tests {
+		filtered, err := policy.applyRowFilters(test.query, test.driver, attributes)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+		} else if filtered != test.expected {
+			t.Errorf("%s:\nexpected %s\ngot      %s", test.query, test.expected, filtered)
+		}
+	}
+
+	if _, err := policy.applyRowFilters("DELETE FROM user_account", config.Postgres, attributes); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("expected filtered writes to be denied, got %v", err)
+	}
+	if _, err := policy.applyRowFilters(`SELECT * FROM U&"user_account"`, config.Postgres, attributes); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("expected unicode escaped names to be denied, got %v", err)
+	}
+	if _, err := policy.applyRowFilters("SELECT * FROM user_account", config.Postgres, nil); !errors.Is(err, ErrMissingAttribute) {
+		t.Errorf("expected a missing attribute error, got %v", err)
+	}
+}
+
+func TestAccessPolicy_SessionSettings(t *testing.T) {
+	policy := NewAccessPolicy(nil, nil, map[string]string{"app.region": ":region", "app.tenant": "tenant-:tenant"})
+
+	query := "SELECT amount FROM transfers"
+	settings, err := policy.sessionSettings(query, readStatements, config.Postgres, map[string]string{"region": "north", "tenant": "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings["app.region"] != "north" || settings["app.tenant"] != "tenant-7" {
+		t.Errorf("unexpected settings %v", settings)
+	}
+
+	if _, err := policy.sessionSettings(query, readStatements, config.Postgres, map[string]string{"region": "north"}); !errors.Is(err, ErrMissingAttribute) {
+		t.Errorf("expected a missing attribute error, got %v", err)
+	}
+
+	// Statements that could change the settings before reading
+	for _, query := range []string{
+		"SELECT set_config('app.region', 'south', true), amount FROM transfers",
+		"SELECT amount FROM transfers WHERE pg_catalog.set_config('app.region', 'south', true) <> ''",
+		"SET app.region = 'south'; SELECT amount FROM transfers",
+		"RESET app.region",
+		"SELECT 1; SELECT amount FROM transfers",
+		"DELETE FROM transfers",
+	} {
+		if _, err := policy.sessionSettings(query, readStatements, config.Postgres, map[string]string{"region": "north", "tenant": "7"}); !errors.Is(err, ErrAccessDenied) {
+			t.Errorf("%s: expected access denied, got %v", query, err)
+		}
+	}
+}
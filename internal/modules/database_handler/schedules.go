@@ -0,0 +1,137 @@
+package database_handler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+)
+
+// Schedule answers a question, or runs a pinned SQL query, on a cron schedule for a user
+type Schedule struct {
+	ID         int
+	UserID     int64
+	DatabaseID int
+	Connection string
+	Cron       string
+	Question   string
+	// SQL is run instead of SQL generated for the question if set
+	SQL       string
+	Paused    bool
+	LastRunAt time.Time
+}
+
+func convertRepoScheduleToModuleModel(schedule repo.Schedule) Schedule {
+	return Schedule{
+		ID:         schedule.ID,
+		UserID:     schedule.UserID,
+		DatabaseID: schedule.DatabaseID,
+		Connection: schedule.Connection,
+		Cron:       schedule.Cron,
+		Question:   schedule.Question,
+		SQL:        schedule.SQL,
+		Paused:     schedule.Paused,
+		LastRunAt:  schedule.LastRunAt,
+	}
+}
+
+// AddSchedule schedules the question, or the pinned SQL, on the snapshot the user selected.
+// The cron expression must have been validated.
+func (d *DatabaseHandler) AddSchedule(userID int64, cron string, question string, sql string) (Schedule, error) {
+	currentDatabase, err := d.currentDatabase(userID)
+	if err != nil {
+		return Schedule{}, err
+	}
+	connection, err := d.connectionOf(userID, currentDatabase.Connection)
+	if err != nil {
+		return Schedule{}, err
+	}
+	if sql != "" && isWrite(sql, connection.Driver) {
+		return Schedule{}, ErrWriteInQuery
+	}
+
+	schedule := repo.Schedule{
+		UserID:     userID,
+		DatabaseID: currentDatabase.ID,
+		Connection: currentDatabase.Connection,
+		Cron:       cron,
+		Question:   question,
+		SQL:        sql,
+	}
+	schedule.ID, err = d.databaseRepo.AddSchedule(schedule)
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	return convertRepoScheduleToModuleModel(schedule), nil
+}
+
+// GetSchedules returns the schedules of the user
+func (d *DatabaseHandler) GetSchedules(userID int64) ([]Schedule, error) {
+	schedules, err := d.databaseRepo.GetSchedules()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Schedule
+	for _, schedule := range schedules {
+		if schedule.UserID == userID {
+			result = append(result, convertRepoScheduleToModuleModel(schedule))
+		}
+	}
+	return result, nil
+}
+
+// GetSchedule returns the schedule with the given ID
+func (d *DatabaseHandler) GetSchedule(ID int) (Schedule, error) {
+	schedules, err := d.databaseRepo.GetSchedules()
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	for _, schedule := range schedules {
+		if schedule.ID == ID {
+			return convertRepoScheduleToModuleModel(schedule), nil
+		}
+	}
+	return Schedule{}, fmt.Errorf("schedule with ID %d not found", ID)
+}
+
+func (d *DatabaseHandler) SetSchedulePaused(ID int, paused bool) error {
+	return d.databaseRepo.SetSchedulePaused(ID, paused)
+}
+
+func (d *DatabaseHandler) DeleteSchedule(ID int) error {
+	return d.databaseRepo.DeleteSchedule(ID)
+}
+
+// AnswerSchedule answers the question of the schedule, or runs its pinned SQL, like Query does
+// for the requester, on the database of the schedule rather than the current one. Nobody is
+// there to confirm expensive queries, so they are rejected.
+func (d *DatabaseHandler) AnswerSchedule(schedule Schedule, requester Requester) (QueryAnswer, error) {
+	database, err := d.databaseRepo.GetDatabase(schedule.DatabaseID)
+	if err != nil {
+		return QueryAnswer{}, err
+	}
+	if database.Connection != schedule.Connection {
+		return QueryAnswer{}, fmt.Errorf("%w: it was taken from %q but the schedule runs on %q",
+			ErrConnectionMismatch, database.Connection, schedule.Connection)
+	}
+
+	connection, err := d.lookupConnection(schedule.Connection)
+	if err != nil {
+		return QueryAnswer{}, err
+	}
+
+	answer, err := d.answer(database, connection, question{
+		text:      schedule.Question,
+		pinnedSQL: schedule.SQL,
+		decisions: []string{fmt.Sprintf("run by schedule %d", schedule.ID)},
+	}, requester)
+	var confirmation *ConfirmationRequiredError
+	if errors.As(err, &confirmation) {
+		return QueryAnswer{}, fmt.Errorf("%w: %s", ErrExpensiveQuery, confirmation.Pending.Reason)
+	}
+	return answer, err
+}
@@ -0,0 +1,89 @@
+package database_handler
+
+import (
+	"fmt"
+
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+)
+
+// GetConnectionDatabases returns the snapshots taken from the connection the user selected
+func (d *DatabaseHandler) GetConnectionDatabases(userID int64) ([]Database, error) {
+	connection, err := d.getCurrentConnection(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	databases, err := d.databaseRepo.ListByDriver(string(connection.Driver))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []repo.Database
+	for _, database := range databases {
+		if database.Connection == connection.Name {
+			result = append(result, database)
+		}
+	}
+
+	return convertRepoDatabasesToModuleModel(result), nil
+}
+
+func (d *DatabaseHandler) GetDatabase(databaseID int) (Database, error) {
+	database, err := d.databaseRepo.GetDatabase(databaseID)
+	if err != nil {
+		return Database{}, err
+	}
+
+	return convertRepoDatabaseToModuleModel(database), nil
+}
+
+func (d *DatabaseHandler) GetAllDatabases() ([]Database, error) {
+	databases, err := d.databaseRepo.GetAllDatabases()
+	if err != nil {
+		return nil, err
+	}
+
+	return convertRepoDatabasesToModuleModel(databases), nil
+}
+
+func (d *DatabaseHandler) DeleteDatabase(databaseID int) error {
+	err := d.databaseRepo.DeleteDatabase(databaseID)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for userID, current := range d.selections {
+		if current.databaseID != nil && *current.databaseID == databaseID {
+			current.databaseID = nil
+			d.selections[userID] = current
+		}
+	}
+	return nil
+}
+
+func (d *DatabaseHandler) RenameDatabase(databaseID int, name string) error {
+	return d.databaseRepo.RenameDatabase(databaseID, name)
+}
+
+// RefreshDatabase takes a new snapshot of the tables of the connection the given
+// snapshot was taken from, keeping the descriptions of the tables and columns that still exist
+func (d *DatabaseHandler) RefreshDatabase(databaseID int) error {
+	database, err := d.databaseRepo.GetDatabase(databaseID)
+	if err != nil {
+		return err
+	}
+
+	connection, err := d.lookupConnection(database.Connection)
+	if err != nil {
+		return fmt.Errorf("can not refresh database %s: %w", database.Name, err)
+	}
+
+	tables, err := connection.Database.GetTables()
+	if err != nil {
+		return err
+	}
+
+	return d.databaseRepo.UpdateTables(databaseID, tables.ToRepositoryTableList())
+}
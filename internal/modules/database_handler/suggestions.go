@@ -0,0 +1,157 @@
+package database_handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	sampleRowsLimit     = 5
+	maxSampleRowsLength = 4000
+)
+
+// DescriptionSuggestion is a description proposed by the AI module for a table, or for
+// a column of the table when Column is set
+type DescriptionSuggestion struct {
+	Table       string
+	Column      *string
+	Description string
+}
+
+// GetUndocumentedTables returns the names of the tables of the database the requester
+// selected that have no description, or have a column without description. Tables and
+// columns the role of the requester may not see are left out.
+func (d *DatabaseHandler) GetUndocumentedTables(requester Requester) ([]string, error) {
+	database, err := d.GetCurrentDatabase(requester.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, table := range d.accessPolicy(requester.Role).filterDatabase(database).Tables {
+		if table.isUndocumented() {
+			result = append(result, table.Name)
+		}
+	}
+
+	return result, nil
+}
+
+// SuggestTableDescriptions asks the AI module for descriptions of the undocumented parts
+// of the given table on behalf of the requester, based on its structure and a few sample
+// rows. The model sees only the columns the role of the requester may see.
+func (d *DatabaseHandler) SuggestTableDescriptions(tableName string, requester Requester) ([]DescriptionSuggestion, error) {
+	database, err := d.GetCurrentDatabase(requester.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := d.accessPolicy(requester.Role)
+	if policy.isTableDenied(tableName) {
+		return nil, fmt.Errorf("%w: table %s is denied", ErrAccessDenied, tableName)
+	}
+	table, ok := policy.filterDatabase(database).GetTableByName(tableName)
+	if !ok {
+		return nil, errors.New("table not found")
+	}
+
+	tableContext, err := json.MarshalIndent(table, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	sampleRows, err := d.getSampleRows(policy, requester, database.Connection, table)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions, err := d.aiModule.SuggestDescriptions(requester.UserID, string(tableContext), sampleRows)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []DescriptionSuggestion
+	if table.Description == "" && strings.TrimSpace(suggestions.Table) != "" {
+		result = append(result, DescriptionSuggestion{
+			Table:       table.Name,
+			Description: strings.TrimSpace(suggestions.Table),
+		})
+	}
+
+	for _, column := range table.Columns {
+		description := strings.TrimSpace(suggestions.Columns[column.Name])
+		if column.Description != "" || description == "" {
+			continue
+		}
+
+		columnName := column.Name
+		result = append(result, DescriptionSuggestion{
+			Table:       table.Name,
+			Column:      &columnName,
+			Description: description,
+		})
+	}
+
+	return result, nil
+}
+
+// getSampleRows reads a few rows of the given columns of the table, limited to the rows the
+// row filters and settings of the policy let the requester read
+func (d *DatabaseHandler) getSampleRows(policy AccessPolicy, requester Requester, connectionName string, table Table) (string, error) {
+	connection, err := d.connectionOf(requester.UserID, connectionName)
+	if err != nil {
+		return "", err
+	}
+
+	if len(table.Columns) == 0 {
+		return "", nil
+	}
+	columns := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		columns[i] = column.Name
+	}
+	query := connection.Database.SampleRowsQuery(table.Name, columns, sampleRowsLimit)
+
+	executed, err := policy.applyRowFilters(query, connection.Driver, requester.Attributes)
+	if err != nil {
+		return "", err
+	}
+	settings, err := policy.sessionSettings(executed, readStatements, connection.Driver, requester.Attributes)
+	if err != nil {
+		return "", err
+	}
+
+	rows, err := connection.Database.QueryWithSettings(settings, executed)
+	if err != nil {
+		return "", fmt.Errorf("error getting sample rows: %v", err)
+	}
+	defer rows.Close()
+
+	// Sample rows are sent to the model, so every masking rule applies
+	result, _, err := rows.Json(d.masker.ForRole(""))
+	if err != nil {
+		return "", fmt.Errorf("error converting sample rows to json: %v", err)
+	}
+
+	if len(result) > maxSampleRowsLength {
+		result = strings.ToValidUTF8(result[:maxSampleRowsLength], "")
+	}
+
+	return result, nil
+}
+
+func (s Table) isUndocumented() bool {
+	if s.Description == "" {
+		return true
+	}
+
+	for _, column := range s.Columns {
+		if column.Description == "" {
+			return true
+		}
+	}
+
+	return false
+}
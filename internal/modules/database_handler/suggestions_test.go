@@ -0,0 +1,99 @@
+package database_handler
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
+	db2 "github.com/AliTaghipour1/Talk-to_DB/internal/modules/db"
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+)
+
+var errQueryRecorded = errors.New("query recorded")
+
+// recordingDatabase records the queries it is asked to run and fails them
+type recordingDatabase struct {
+	query    string
+	settings map[string]string
+}
+
+func (r *recordingDatabase) QueryWithSettings(settings map[string]string, query string) (*db2.QueryResult, error) {
+	r.query, r.settings = query, settings
+	return nil, errQueryRecorded
+}
+
+// SampleRowsQuery quotes like PostgreSQL does
+func (r *recordingDatabase) SampleRowsQuery(tableName string, columns []string, limit int) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = `"` + column + `"`
+	}
+	return `SELECT ` + strings.Join(quoted, ", ") + ` FROM "public"."` + tableName + `" LIMIT 5`
+}
+
+func (r *recordingDatabase) GetTables() (db2.Tables, error) { return nil, nil }
+func (r *recordingDatabase) Query(string, ...interface{}) (*db2.QueryResult, error) {
+	return nil, errQueryRecorded
+}
+func (r *recordingDatabase) Explain(map[string]string, string) (db2.Estimate, error) {
+	return db2.Estimate{}, nil
+}
+func (r *recordingDatabase) DryRun(map[string]string, string) (int64, error) { return 0, nil }
+func (r *recordingDatabase) Exec(map[string]string, string, int64) (int64, error) {
+	return 0, nil
+}
+func (r *recordingDatabase) Health() db2.Health { return db2.Health{Status: db2.StatusUp} }
+func (r *recordingDatabase) Connect() error     { return nil }
+func (r *recordingDatabase) RunHealthChecks()   {}
+func (r *recordingDatabase) Close() error       { return nil }
+
+func TestDatabaseHandler_SuggestionsFollowAccessPolicy(t *testing.T) {
+	databaseRepo, err := repo.NewDatabaseRepoMapImpl(filepath.Join(t.TempDir(), "data.json"), repo.MapRepoOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	databaseID, err := databaseRepo.CreateNewDatabase(&repo.Database{Name: "bank", Driver: "postgres", Connection: "bank", Tables: []repo.Table{
+		{Name: "user_account", Columns: []repo.Column{{Name: "id"}, {Name: "account_number"}, {Name: "region"}}},
+		{Name: "salaries", Columns: []repo.Column{{Name: "amount"}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	database := &recordingDatabase{}
+	d := &DatabaseHandler{
+		connections: map[string]Connection{"bank": {Name: "bank", Driver: config.Postgres, Database: database}},
+		policies: map[string]AccessPolicy{
+			"support": NewAccessPolicy([]string{"user_account.account_number", "salaries"},
+				map[string]string{"user_account": "region = :region"}, map[string]string{"app.region": ":region"}),
+		},
+		selections:   make(map[int64]selection),
+		databaseRepo: databaseRepo,
+	}
+	if _, err := d.HandleChoosingDatabase(1, databaseID); err != nil {
+		t.Fatal(err)
+	}
+	requester := Requester{UserID: 1, Role: "support", Attributes: map[string]string{"region": "eu"}}
+
+	tables, err := d.GetUndocumentedTables(requester)
+	if err != nil || !slices.Equal(tables, []string{"user_account"}) {
+		t.Fatalf("expected only the allowed table, got %v, %v", tables, err)
+	}
+
+	if _, err := d.SuggestTableDescriptions("salaries", requester); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("expected the denied table to be refused, got %v", err)
+	}
+
+	if _, err := d.SuggestTableDescriptions("user_account", requester); err == nil || database.query == "" {
+		t.Fatalf("expected the sample rows to be queried, got %v", err)
+	}
+	if strings.Contains(database.query, "account_number") || !strings.Contains(database.query, `"id", "region"`) {
+		t.Fatalf("expected the sample rows of the allowed columns only, got %s", database.query)
+	}
+	if !strings.Contains(database.query, "WHERE (region = E'eu')") || database.settings["app.region"] != "eu" {
+		t.Fatalf("expected the sample rows of the requester's region, got %s with %v", database.query, database.settings)
+	}
+}
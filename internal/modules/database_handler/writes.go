@@ -0,0 +1,279 @@
+package database_handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/ai"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/audit"
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/sqlparser"
+)
+
+var (
+	ErrNotAWrite      = errors.New("the generated SQL is not a single INSERT, UPDATE or DELETE statement")
+	ErrWriteInQuery   = errors.New("the generated SQL changes data, ask with /write to send it for approval")
+	ErrWriteNotFound  = errors.New("the write request does not exist or was already decided")
+	ErrSelfApproval   = errors.New("write requests must be approved by someone else")
+	ErrNothingToWrite = errors.New("the statement changes no rows")
+)
+
+// maxPendingWrites bounds the write requests kept waiting for approval, the oldest are dropped
+const maxPendingWrites = 1000
+
+// writeStatements are the statements that change data and need approval
+var writeStatements = map[string]bool{"INSERT": true, "UPDATE": true, "DELETE": true}
+
+// WriteRequest is a generated INSERT, UPDATE or DELETE statement that was tried in a rolled
+// back transaction and waits for an approver, see ApproveWrite
+type WriteRequest struct {
+	ID         int
+	UserID     int64
+	Question   string
+	SQL        string
+	Connection string
+	// Affected is the number of rows the statement changed in its dry run
+	Affected int64
+	entry    audit.Entry
+	executed string
+	settings map[string]string
+}
+
+// writeRequests keeps the write requests waiting for approval
+type writeRequests struct {
+	mu      sync.Mutex
+	pending map[int]WriteRequest
+	order   []int
+	nextID  int
+}
+
+func newWriteRequests() *writeRequests {
+	return &writeRequests{pending: make(map[int]WriteRequest), nextID: 1}
+}
+
+func (w *writeRequests) add(request WriteRequest) WriteRequest {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	request.ID = w.nextID
+	w.nextID++
+	w.pending[request.ID] = request
+	w.order = append(w.order, request.ID)
+
+	for len(w.order) > maxPendingWrites {
+		delete(w.pending, w.order[0])
+		w.order = w.order[1:]
+	}
+
+	return request
+}
+
+// take removes the request for the approver so that it is decided only once
+func (w *writeRequests) take(id int, approverID int64) (WriteRequest, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	request, ok := w.pending[id]
+	if !ok {
+		return WriteRequest{}, ErrWriteNotFound
+	}
+	if request.UserID == approverID {
+		return WriteRequest{}, ErrSelfApproval
+	}
+
+	delete(w.pending, id)
+	w.order = slices.DeleteFunc(w.order, func(pending int) bool { return pending == id })
+	return request, nil
+}
+
+func (w *writeRequests) get(id int) (WriteRequest, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	request, ok := w.pending[id]
+	return request, ok
+}
+
+// checkWrite accepts exactly one INSERT, UPDATE or DELETE statement
+func checkWrite(statement string, driver config.Driver) error {
+	statements, err := sqlparser.Parse(statement, sqlDialect(driver))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotAWrite, err)
+	}
+	if len(statements) != 1 || !writeStatements[statements[0].Kind] {
+		return ErrNotAWrite
+	}
+	return nil
+}
+
+// isWrite tells whether any statement of the query changes data. Queries the parser can
+// not read are left to the read-only transaction they run in.
+func isWrite(query string, driver config.Driver) bool {
+	statements, err := sqlparser.Parse(query, sqlDialect(driver))
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(statements, func(statement sqlparser.Statement) bool {
+		return writeStatements[statement.Kind]
+	})
+}
+
+// RequestWrite generates a statement that changes data as asked, tries it in a transaction
+// that is rolled back to count the rows it changes and keeps it until an approver decides
+// on it. The statement is subject to the same rules as the queries of the role.
+func (d *DatabaseHandler) RequestWrite(text string, requester Requester) (request WriteRequest, err error) {
+	currentDatabase, err := d.currentDatabase(requester.UserID)
+	if err != nil {
+		return WriteRequest{}, err
+	}
+
+	connection, err := d.connectionOf(requester.UserID, currentDatabase.Connection)
+	if err != nil {
+		return WriteRequest{}, err
+	}
+
+	entry := audit.Entry{
+		Time:       time.Now(),
+		UserID:     requester.UserID,
+		Role:       requester.Role,
+		Connection: connection.Name,
+		DatabaseID: currentDatabase.ID,
+		Question:   text,
+	}
+	defer func() {
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		d.recordAudit(entry)
+	}()
+
+	policy := d.accessPolicy(requester.Role)
+	database := convertRepoDatabaseToModuleModel(currentDatabase)
+	visibleDatabase := policy.filterDatabase(database)
+
+	statement := d.aiModule.GetQuery(ai.QueryRequest{
+		UserID:   requester.UserID,
+		Schema:   visibleDatabase.Scheme(),
+		Glossary: visibleDatabase.relevantGlossary(text),
+		Question: text,
+		Write:    true,
+	})
+	entry.SQL = statement
+
+	if err := checkWrite(statement, connection.Driver); err != nil {
+		return WriteRequest{}, err
+	}
+	if err := policy.checkQuery(statement, database, connection.Driver); err != nil {
+		log.Printf("rejected write for role %s: %v\n%s", requester.Role, err, statement)
+		entry.Decisions = append(entry.Decisions, "rejected by the deny rules")
+		return WriteRequest{}, err
+	}
+	// Tables with row filters can only be read, so this rejects writes to them
+	executed, err := policy.applyRowFilters(statement, connection.Driver, requester.Attributes)
+	if err != nil {
+		log.Printf("rejected write for role %s: %v\n%s", requester.Role, err, statement)
+		entry.Decisions = append(entry.Decisions, "rejected by the row filters")
+		return WriteRequest{}, err
+	}
+	settings, err := policy.sessionSettings(executed, writeStatements, connection.Driver, requester.Attributes)
+	if err != nil {
+		log.Printf("rejected write for role %s: %v\n%s", requester.Role, err, executed)
+		entry.Decisions = append(entry.Decisions, "rejected by the settings of the role")
+		return WriteRequest{}, err
+	}
+	if len(settings) > 0 {
+		entry.Decisions = append(entry.Decisions, "set "+strings.Join(slices.Sorted(maps.Keys(settings)), ", "))
+	}
+
+	started := time.Now()
+	affected, err := connection.Database.DryRun(settings, executed)
+	entry.Duration = time.Since(started)
+	if err != nil {
+		return WriteRequest{}, fmt.Errorf(`error trying statement on %s (%s): %v`, connection.Name, connection.Database.Health().Status, err)
+	}
+	entry.Rows = int(affected)
+	if affected == 0 {
+		entry.Decisions = append(entry.Decisions, "dry run changed no rows")
+		return WriteRequest{}, ErrNothingToWrite
+	}
+
+	entry.Decisions = append(entry.Decisions, fmt.Sprintf("dry run changed %d rows, sent for approval", affected))
+	return d.writes.add(WriteRequest{
+		UserID:     requester.UserID,
+		Question:   text,
+		SQL:        statement,
+		Connection: connection.Name,
+		Affected:   affected,
+		entry:      entry,
+		executed:   executed,
+		settings:   settings,
+	}), nil
+}
+
+// PendingWrite returns the write request if it still waits for approval
+func (d *DatabaseHandler) PendingWrite(id int) (WriteRequest, error) {
+	request, ok := d.writes.get(id)
+	if !ok {
+		return WriteRequest{}, ErrWriteNotFound
+	}
+	return request, nil
+}
+
+// ApproveWrite runs the write request for the approver. It is committed only if it changes
+// as many rows as in its dry run.
+func (d *DatabaseHandler) ApproveWrite(id int, approverID int64) (request WriteRequest, affected int64, err error) {
+	request, err = d.writes.take(id, approverID)
+	if err != nil {
+		return WriteRequest{}, 0, err
+	}
+
+	entry := request.entry
+	entry.Time = time.Now()
+	entry.Decisions = append(entry.Decisions[:len(entry.Decisions):len(entry.Decisions)], fmt.Sprintf("approved by user %d", approverID))
+	defer func() {
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		d.recordAudit(entry)
+	}()
+
+	connection, err := d.lookupConnection(request.Connection)
+	if err != nil {
+		return request, 0, err
+	}
+
+	started := time.Now()
+	affected, err = connection.Database.Exec(request.settings, request.executed, request.Affected)
+	entry.Duration = time.Since(started)
+	entry.Rows = int(affected)
+	if err != nil {
+		return request, affected, fmt.Errorf(`error executing statement on %s (%s): %v`, connection.Name, connection.Database.Health().Status, err)
+	}
+	// Cached results may show the rows as they were before
+	d.cache.forgetResults(connection.Name)
+
+	return request, affected, nil
+}
+
+// RejectWrite drops the write request without running it
+func (d *DatabaseHandler) RejectWrite(id int, approverID int64) (WriteRequest, error) {
+	request, err := d.writes.take(id, approverID)
+	if err != nil {
+		return WriteRequest{}, err
+	}
+
+	entry := request.entry
+	entry.Time = time.Now()
+	entry.Rows = 0
+	entry.Duration = 0
+	entry.Decisions = append(entry.Decisions[:len(entry.Decisions):len(entry.Decisions)], fmt.Sprintf("rejected by user %d", approverID))
+	d.recordAudit(entry)
+
+	return request, nil
+}
@@ -0,0 +1,50 @@
+package database_handler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
+)
+
+func TestCheckWrite(t *testing.T) {
+	for _, statement := range []string{
+		"UPDATE user_account SET nickname = 'nargess' WHERE id = 4",
+		"INSERT INTO glossary (term) VALUES ('churn')",
+		"DELETE FROM sessions WHERE expires_at < now()",
+	} {
+		if err := checkWrite(statement, config.Postgres); err != nil {
+			t.Fatalf("expected %q to be accepted, got %v", statement, err)
+		}
+	}
+
+	for _, statement := range []string{
+		"SELECT * FROM user_account",
+		"DROP TABLE user_account",
+		"UPDATE a SET x = 1; DELETE FROM b",
+		"",
+	} {
+		if err := checkWrite(statement, config.Postgres); !errors.Is(err, ErrNotAWrite) {
+			t.Fatalf("expected %q to be rejected, got %v", statement, err)
+		}
+	}
+
+	if !isWrite("SELECT 1; DELETE FROM sessions", config.Postgres) || isWrite("SELECT * FROM sessions", config.Postgres) {
+		t.Fatal("isWrite must detect the statements that change data")
+	}
+}
+
+func TestWriteRequests_Take(t *testing.T) {
+	writes := newWriteRequests()
+	request := writes.add(WriteRequest{UserID: 7, SQL: "DELETE FROM sessions"})
+
+	if _, err := writes.take(request.ID, 7); !errors.Is(err, ErrSelfApproval) {
+		t.Fatalf("expected the requester to be refused, got %v", err)
+	}
+	if taken, err := writes.take(request.ID, 1); err != nil || taken.SQL != request.SQL {
+		t.Fatalf("expected the approver to take the request, got %+v, %v", taken, err)
+	}
+	if _, err := writes.take(request.ID, 1); !errors.Is(err, ErrWriteNotFound) {
+		t.Fatalf("expected a decided request to be gone, got %v", err)
+	}
+}
@@ -1,9 +1,9 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"strings"
 
 	_ "github.com/lib/pq"
 )
@@ -14,8 +14,8 @@ type cockroachConfig struct {
 	User     string
 	Password string
 	Database string
-	SSLMode  string
 	Schema   string // Default to "public" if empty
+	ConnectionOptions
 }
 
 type databaseCockroachImpl struct {
@@ -23,49 +23,22 @@ type databaseCockroachImpl struct {
 	config cockroachConfig
 }
 
-func (d *databaseCockroachImpl) connect() error {
-	// Set default schema if not provided
-	schema := d.config.Schema
-	if schema == "" {
-		schema = "public"
-	}
-
-	// Set default SSL mode if not provided (CockroachDB typically requires SSL in production)
-	sslMode := d.config.SSLMode
-	if sslMode == "" {
-		sslMode = "disable" // Default to disable for local development
-	}
-
+// open connects to the server and verifies the connection, leaving d unchanged
+func (d *databaseCockroachImpl) open() (*sql.DB, error) {
 	// Build CockroachDB connection string (PostgreSQL-compatible format)
 	// Format: host=... port=... user=... password=... dbname=... sslmode=...
 	connParts := []string{
-		fmt.Sprintf("host=%s", d.config.Host),
-		fmt.Sprintf("port=%s", d.config.Port),
-		fmt.Sprintf("user=%s", d.config.User),
-		fmt.Sprintf("password=%s", d.config.Password),
-		fmt.Sprintf("dbname=%s", d.config.Database),
-		fmt.Sprintf("sslmode=%s", sslMode),
+		postgresParam("host", d.config.Host),
+		postgresParam("port", d.config.Port),
+		postgresParam("user", d.config.User),
+		postgresParam("password", d.config.Password),
+		postgresParam("dbname", d.config.Database),
 	}
-	connStr := strings.Join(connParts, " ")
-
-	// Open database connection (CockroachDB uses PostgreSQL driver)
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return fmt.Errorf("failed to open CockroachDB connection: %w", err)
-	}
-
-	// Verify connection by pinging
-	if err := db.Ping(); err != nil {
-		db.Close()
-		return fmt.Errorf("failed to ping CockroachDB database: %w", err)
-	}
-
-	// Set connection pool settings for thread safety
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
+	return d.config.openPostgres(connParts, "CockroachDB")
+}
 
+func (d *databaseCockroachImpl) setDB(db *sql.DB) {
 	d.db = db
-	return nil
 }
 
 func (d *databaseCockroachImpl) GetTables() (Tables, error) {
@@ -166,6 +139,88 @@ func (d *databaseCockroachImpl) Query(query string, args ...interface{}) (*Query
 	return &QueryResult{Rows: rows}, nil
 }
 
+func (d *databaseCockroachImpl) QueryWithSettings(settings map[string]string, query string) (*QueryResult, error) {
+	if d.db == nil {
+		return nil, fmt.Errorf("database connection is not established")
+	}
+
+	return queryWithSettings(d.db, settings, query)
+}
+
+// Explain estimates the number of rows the query reads with the settings applied, see
+// queryWithSettings. CockroachDB does not report plan costs.
+func (d *databaseCockroachImpl) Explain(settings map[string]string, query string) (Estimate, error) {
+	if d.db == nil {
+		return Estimate{}, fmt.Errorf("database connection is not established")
+	}
+
+	tx, err := beginWithSettings(d.db, settings)
+	if err != nil {
+		return Estimate{}, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("EXPLAIN " + query)
+	if err != nil {
+		return Estimate{}, fmt.Errorf("failed to explain query: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return Estimate{}, fmt.Errorf("failed to scan plan: %w", err)
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return Estimate{}, fmt.Errorf("error iterating plan rows: %w", err)
+	}
+
+	return parseCockroachPlan(lines)
+}
+
+// DryRun returns the number of rows the statement affects with the settings applied and
+// rolls it back
+func (d *databaseCockroachImpl) DryRun(settings map[string]string, statement string) (int64, error) {
+	if d.db == nil {
+		return 0, fmt.Errorf("database connection is not established")
+	}
+
+	return dryRun(d.db, settings, statement)
+}
+
+// Exec runs the statement with the settings applied if it affects the expected number of rows
+func (d *databaseCockroachImpl) Exec(settings map[string]string, statement string, expected int64) (int64, error) {
+	if d.db == nil {
+		return 0, fmt.Errorf("database connection is not established")
+	}
+
+	return execExpecting(d.db, settings, statement, expected)
+}
+
+// SampleRowsQuery returns a query of up to limit rows of the given columns of the table
+func (d *databaseCockroachImpl) SampleRowsQuery(tableName string, columns []string, limit int) string {
+	// Determine schema to use
+	schema := d.config.Schema
+	if schema == "" {
+		schema = "public"
+	}
+
+	table := quotePostgresIdentifier(schema) + "." + quotePostgresIdentifier(tableName)
+	return sampleRowsQuery(table, columns, limit, quotePostgresIdentifier)
+}
+
+// ping checks that the database is still reachable
+func (d *databaseCockroachImpl) ping(ctx context.Context) error {
+	if d.db == nil {
+		return fmt.Errorf("database connection is not established")
+	}
+
+	return d.db.PingContext(ctx)
+}
+
 // Close closes the database connection
 func (d *databaseCockroachImpl) Close() error {
 	if d.db != nil {
@@ -209,15 +264,8 @@ func (d *databaseCockroachImpl) GetSchemas() ([]string, error) {
 	return schemas, nil
 }
 
-func newDatabaseCockroachImpl(config cockroachConfig) (Database, error) {
-	result := &databaseCockroachImpl{
+func newDatabaseCockroachImpl(config cockroachConfig) driverDatabase {
+	return &databaseCockroachImpl{
 		config: config,
 	}
-
-	err := result.connect()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create CockroachDB database instance: %w", err)
-	}
-
-	return result, nil
 }
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ConnectionOptions are the TLS, timeout and pool settings of a connection.
+// Zero values fall back to the defaults below.
+type ConnectionOptions struct {
+	SSLMode         string // disable, allow, prefer, require, verify-ca or verify-full
+	SSLRootCert     string // path of the CA certificate used to verify the server
+	SSLCert         string // path of the client certificate
+	SSLKey          string // path of the client key
+	ConnectTimeout  time.Duration
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ApplicationName string
+}
+
+const (
+	defaultSSLMode        = "disable" // Default to disable for local development
+	defaultConnectTimeout = 10 * time.Second
+	defaultMaxOpenConns   = 25
+	defaultMaxIdleConns   = 5
+)
+
+func (o ConnectionOptions) sslMode() string {
+	if o.SSLMode == "" {
+		return defaultSSLMode
+	}
+	return o.SSLMode
+}
+
+func (o ConnectionOptions) connectTimeout() time.Duration {
+	if o.ConnectTimeout <= 0 {
+		return defaultConnectTimeout
+	}
+	return o.ConnectTimeout
+}
+
+// applyPool sets the connection pool settings for thread safety
+func (o ConnectionOptions) applyPool(db *sql.DB) {
+	maxOpenConns := o.MaxOpenConns
+	if maxOpenConns <= 0 {
+		maxOpenConns = defaultMaxOpenConns
+	}
+	maxIdleConns := o.MaxIdleConns
+	if maxIdleConns <= 0 {
+		maxIdleConns = defaultMaxIdleConns
+	}
+
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(min(maxIdleConns, maxOpenConns))
+	if o.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(o.ConnMaxLifetime)
+	}
+}
+
+// postgresSSLModes returns the sslmode values lib/pq is given, in the order they are tried.
+// lib/pq knows no allow and prefer, so those require TLS first and connect without it if the
+// server does not support it.
+func (o ConnectionOptions) postgresSSLModes() []string {
+	switch mode := o.sslMode(); mode {
+	case "allow", "prefer":
+		return []string{"require", "disable"}
+	default:
+		return []string{mode}
+	}
+}
+
+// openPostgres opens and pings a lib/pq connection with the given keyword/value parameters and
+// the ones of the options, trying each of postgresSSLModes in turn. label names the server in errors.
+func (o ConnectionOptions) openPostgres(connParts []string, label string) (*sql.DB, error) {
+	var err error
+	for _, sslMode := range o.postgresSSLModes() {
+		connStr := strings.Join(append(slices.Clip(connParts), o.postgresParams(sslMode)...), " ")
+
+		// Open database connection
+		db, openErr := sql.Open("postgres", connStr)
+		if openErr != nil {
+			return nil, fmt.Errorf("failed to open %s connection: %w", label, openErr)
+		}
+
+		// Verify connection by pinging
+		ctx, cancel := context.WithTimeout(context.Background(), o.connectTimeout())
+		err = db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			o.applyPool(db)
+			return db, nil
+		}
+		db.Close()
+		if !errors.Is(err, pq.ErrSSLNotSupported) {
+			break
+		}
+	}
+
+	return nil, fmt.Errorf("failed to ping %s database: %w", label, err)
+}
+
+// postgresParams returns the keyword/value connection parameters understood by lib/pq,
+// which are shared by PostgreSQL and CockroachDB, with the given sslmode
+func (o ConnectionOptions) postgresParams(sslMode string) []string {
+	params := []string{
+		postgresParam("sslmode", sslMode),
+		postgresParam("connect_timeout", fmt.Sprint(int(math.Ceil(o.connectTimeout().Seconds())))),
+	}
+	if o.SSLRootCert != "" {
+		params = append(params, postgresParam("sslrootcert", o.SSLRootCert))
+	}
+	if o.SSLCert != "" {
+		params = append(params, postgresParam("sslcert", o.SSLCert))
+	}
+	if o.SSLKey != "" {
+		params = append(params, postgresParam("sslkey", o.SSLKey))
+	}
+	if o.ApplicationName != "" {
+		params = append(params, postgresParam("application_name", o.ApplicationName))
+	}
+
+	return params
+}
+
+// postgresParam formats a keyword/value pair, quoting the value so that it may contain
+// spaces and quotes
+func postgresParam(key string, value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return fmt.Sprintf("%s='%s'", key, value)
+}
+
+// mySQLTLS returns the tls parameter of go-sql-driver/mysql and, if the parameter names one,
+// the TLS configuration to register under it. allow and prefer map to preferred, which uses
+// TLS without verifying the server if the server supports it.
+func (o ConnectionOptions) mySQLTLS(name string, serverName string) (string, *tls.Config, error) {
+	switch o.sslMode() {
+	case "disable":
+		return "false", nil, nil
+	case "allow", "prefer":
+		return "preferred", nil, nil
+	}
+
+	config, err := o.tlsConfig(serverName)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, config, nil
+}
+
+// tlsConfig builds the TLS configuration of drivers that don't read certificate files
+// themselves, for the sslmodes that require TLS
+func (o ConnectionOptions) tlsConfig(serverName string) (*tls.Config, error) {
+	mode := o.sslMode()
+	switch mode {
+	case "require", "verify-ca", "verify-full":
+	default:
+		return nil, fmt.Errorf("unknown sslmode %q", mode)
+	}
+
+	config := &tls.Config{ServerName: serverName}
+
+	if o.SSLCert != "" || o.SSLKey != "" {
+		certificate, err := tls.LoadX509KeyPair(o.SSLCert, o.SSLKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	var roots *x509.CertPool
+	if o.SSLRootCert != "" {
+		pem, err := os.ReadFile(o.SSLRootCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", o.SSLRootCert)
+		}
+		config.RootCAs = roots
+	}
+
+	switch mode {
+	case "verify-full":
+		// The default verification checks both the chain and the host name
+	case "verify-ca":
+		// Verify the chain but not the host name, like libpq does
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyCertificateChain(state, roots)
+		}
+	default:
+		// require encrypts the connection without verifying the server
+		config.InsecureSkipVerify = true
+	}
+
+	return config, nil
+}
+
+func verifyCertificateChain(state tls.ConnectionState, roots *x509.CertPool) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("server sent no certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range state.PeerCertificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"slices"
+	"testing"
+)
+
+// PostgreSQL and CockroachDB both connect through lib/pq with these parameters
+func TestConnectionOptions_PostgresSSLModes(t *testing.T) {
+	tests := []struct {
+		sslMode  string
+		expected []string
+	}{
+		{sslMode: "", expected: []string{"disable"}},
+		{sslMode: "disable", expected: []string{"disable"}},
+		{sslMode: "allow", expected: []string{"require", "disable"}},
+		{sslMode: "prefer", expected: []string{"require", "disable"}},
+		{sslMode: "require", expected: []string{"require"}},
+		{sslMode: "verify-ca", expected: []string{"verify-ca"}},
+		{sslMode: "verify-full", expected: []string{"verify-full"}},
+	}
+	for _, test := range tests {
+		options := ConnectionOptions{SSLMode: test.sslMode}
+		modes := options.postgresSSLModes()
+		if !slices.Equal(modes, test.expected) {
+			t.Errorf("sslmode %q: expected %v, got %v", test.sslMode, test.expected, modes)
+		}
+		for _, mode := range modes {
+			if params := options.postgresParams(mode); params[0] != postgresParam("sslmode", mode) {
+				t.Errorf("sslmode %q: unexpected parameters %v", test.sslMode, params)
+			}
+		}
+	}
+}
+
+func TestConnectionOptions_MySQLTLS(t *testing.T) {
+	const name = "talk-to-db-test"
+	tests := []struct {
+		sslMode      string
+		expected     string
+		registered   bool
+		skipVerify   bool
+		verifiesCA   bool
+		expectsError bool
+	}{
+		{sslMode: "", expected: "false"},
+		{sslMode: "disable", expected: "false"},
+		{sslMode: "allow", expected: "preferred"},
+		{sslMode: "prefer", expected: "preferred"},
+		{sslMode: "require", expected: name, registered: true, skipVerify: true},
+		{sslMode: "verify-ca", expected: name, registered: true, skipVerify: true, verifiesCA: true},
+		{sslMode: "verify-full", expected: name, registered: true},
+		{sslMode: "sometimes", expectsError: true},
+	}
+	for _, test := range tests {
+		param, config, err := ConnectionOptions{SSLMode: test.sslMode}.mySQLTLS(name, "db.internal")
+		if test.expectsError {
+			if err == nil {
+				t.Errorf("sslmode %q: expected an error", test.sslMode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("sslmode %q: %v", test.sslMode, err)
+			continue
+		}
+		if param != test.expected || (config != nil) != test.registered {
+			t.Errorf("sslmode %q: expected tls=%s, got tls=%s with config %v", test.sslMode, test.expected, param, config)
+			continue
+		}
+		if config == nil {
+			continue
+		}
+		if config.ServerName != "db.internal" || config.InsecureSkipVerify != test.skipVerify || (config.VerifyConnection != nil) != test.verifiesCA {
+			t.Errorf("sslmode %q: unexpected TLS configuration %+v", test.sslMode, config)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusUp       Status = "up"
+	StatusDegraded Status = "degraded"
+	StatusDown     Status = "down"
+)
+
+const (
+	healthCheckInterval = 30 * time.Second
+	pingTimeout         = 5 * time.Second
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = 2 * time.Minute
+	// degradedLatency is the ping latency above which a reachable server is reported as degraded
+	degradedLatency = time.Second
+	// downAfterFailures consecutive failed checks mark a connected server as down
+	downAfterFailures = 3
+)
+
+var (
+	ErrDatabaseDown   = errors.New("database is down")
+	ErrDatabaseClosed = errors.New("database connection is closed")
+)
+
+// Health is the result of the latest connection attempt or health check
+type Health struct {
+	Status    Status
+	Err       error
+	Latency   time.Duration
+	CheckedAt time.Time
+}
+
+// managedDatabase connects to the underlying driver lazily and tracks its health.
+// Once connected, database/sql re-establishes broken connections by itself, so failed
+// checks only change the reported status and back off the next check.
+type managedDatabase struct {
+	database driverDatabase
+
+	// connectMu serializes connection attempts, mu guards the state below and the connection
+	// pool of database, which is only set or closed while holding it
+	connectMu   sync.Mutex
+	mu          sync.Mutex
+	connected   bool
+	closed      bool
+	failures    int
+	lastErr     error
+	latency     time.Duration
+	checkedAt   time.Time
+	nextAttempt time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newManagedDatabase(database driverDatabase) *managedDatabase {
+	return &managedDatabase{
+		database: database,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (m *managedDatabase) GetTables() (Tables, error) {
+	if err := m.ready(); err != nil {
+		return nil, err
+	}
+
+	return m.database.GetTables()
+}
+
+func (m *managedDatabase) Query(query string, args ...interface{}) (*QueryResult, error) {
+	if err := m.ready(); err != nil {
+		return nil, err
+	}
+
+	return m.database.Query(query, args...)
+}
+
+func (m *managedDatabase) QueryWithSettings(settings map[string]string, query string) (*QueryResult, error) {
+	if err := m.ready(); err != nil {
+		return nil, err
+	}
+
+	return m.database.QueryWithSettings(settings, query)
+}
+
+func (m *managedDatabase) Explain(settings map[string]string, query string) (Estimate, error) {
+	if err := m.ready(); err != nil {
+		return Estimate{}, err
+	}
+
+	return m.database.Explain(settings, query)
+}
+
+func (m *managedDatabase) DryRun(settings map[string]string, statement string) (int64, error) {
+	if err := m.ready(); err != nil {
+		return 0, err
+	}
+
+	return m.database.DryRun(settings, statement)
+}
+
+func (m *managedDatabase) Exec(settings map[string]string, statement string, expected int64) (int64, error) {
+	if err := m.ready(); err != nil {
+		return 0, err
+	}
+
+	return m.database.Exec(settings, statement, expected)
+}
+
+func (m *managedDatabase) SampleRowsQuery(tableName string, columns []string, limit int) string {
+	return m.database.SampleRowsQuery(tableName, columns, limit)
+}
+
+func (m *managedDatabase) Health() Health {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	health := Health{
+		Err:       m.lastErr,
+		Latency:   m.latency,
+		CheckedAt: m.checkedAt,
+	}
+	switch {
+	case !m.connected || m.failures >= downAfterFailures:
+		health.Status = StatusDown
+	case m.failures > 0 || m.latency > degradedLatency:
+		health.Status = StatusDegraded
+	default:
+		health.Status = StatusUp
+	}
+	if !m.connected && health.Err == nil {
+		health.Err = errors.New("not connected yet")
+	}
+
+	return health
+}
+
+func (m *managedDatabase) RunHealthChecks() {
+	for {
+		wait := m.check()
+
+		select {
+		case <-m.stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (m *managedDatabase) Close() error {
+	m.stopOnce.Do(func() { close(m.stop) })
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	if !m.connected {
+		return nil
+	}
+	m.connected = false
+	return m.database.Close()
+}
+
+func (m *managedDatabase) Connect() error {
+	return m.ready()
+}
+
+// ready connects to the database on first use
+func (m *managedDatabase) ready() error {
+	m.mu.Lock()
+	connected := m.connected
+	m.mu.Unlock()
+	if connected {
+		return nil
+	}
+
+	return m.connect()
+}
+
+// connect establishes the connection unless it is already established. While reconnecting
+// is backing off it fails right away instead of making the user wait for another timeout.
+func (m *managedDatabase) connect() error {
+	m.connectMu.Lock()
+	defer m.connectMu.Unlock()
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return ErrDatabaseClosed
+	}
+	if m.connected {
+		m.mu.Unlock()
+		return nil
+	}
+	if wait := time.Until(m.nextAttempt); wait > 0 {
+		err := fmt.Errorf("%w, retrying in %s: %v", ErrDatabaseDown, wait.Round(time.Second), m.lastErr)
+		m.mu.Unlock()
+		return err
+	}
+	m.mu.Unlock()
+
+	// Connect without holding mu so that Health is not blocked by an unreachable server
+	start := time.Now()
+	db, err := m.database.open()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		// Closed while connecting, the new pool would never be closed otherwise
+		if db != nil {
+			db.Close()
+		}
+		return ErrDatabaseClosed
+	}
+	m.record(start, err)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDatabaseDown, err)
+	}
+
+	m.database.setDB(db)
+	m.connected = true
+	return nil
+}
+
+// check connects or pings the database and returns how long to wait before the next check
+func (m *managedDatabase) check() time.Duration {
+	m.mu.Lock()
+	connected := m.connected
+	m.mu.Unlock()
+
+	if !connected {
+		_ = m.connect()
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		start := time.Now()
+		err := m.database.ping(ctx)
+		cancel()
+
+		m.mu.Lock()
+		m.record(start, err)
+		m.mu.Unlock()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.nextCheck()
+}
+
+// record stores the outcome of a connection attempt or ping started at start. mu must be held.
+func (m *managedDatabase) record(start time.Time, err error) {
+	m.checkedAt = time.Now()
+	m.latency = m.checkedAt.Sub(start)
+	m.lastErr = err
+	if err != nil {
+		m.failures++
+		m.nextAttempt = m.checkedAt.Add(reconnectBackoff(m.failures))
+		return
+	}
+
+	m.failures = 0
+	m.nextAttempt = time.Time{}
+}
+
+func (m *managedDatabase) nextCheck() time.Duration {
+	if m.failures == 0 {
+		return healthCheckInterval
+	}
+	if !m.connected {
+		return max(time.Until(m.nextAttempt), 0)
+	}
+
+	return reconnectBackoff(m.failures)
+}
+
+// reconnectBackoff doubles the wait after every consecutive failure, up to maxReconnectBackoff
+func reconnectBackoff(failures int) time.Duration {
+	backoff := minReconnectBackoff
+	for i := 1; i < failures && backoff < maxReconnectBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, maxReconnectBackoff)
+}
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// unusedDriver backs the pools handed out by fakeDriverDatabase, which are never queried
+type unusedDriver struct{}
+
+func (unusedDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("not supported")
+}
+
+func init() {
+	sql.Register("talk-to-db-unused", unusedDriver{})
+}
+
+// fakeDriverDatabase connects when openErr is nil and pings with pingErr. If opening is
+// set, open waits for a value from it.
+type fakeDriverDatabase struct {
+	mu      sync.Mutex
+	openErr error
+	pingErr error
+	opening chan struct{}
+	started chan struct{}
+	db      *sql.DB
+	opened  int
+}
+
+func (f *fakeDriverDatabase) open() (*sql.DB, error) {
+	f.mu.Lock()
+	f.opened++
+	opening, started, err := f.opening, f.started, f.openErr
+	f.mu.Unlock()
+
+	if opening != nil {
+		close(started)
+		<-opening
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sql.Open("talk-to-db-unused", "")
+}
+
+func (f *fakeDriverDatabase) setDB(db *sql.DB) { f.db = db }
+
+func (f *fakeDriverDatabase) ping(context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pingErr
+}
+
+func (f *fakeDriverDatabase) Close() error {
+	if f.db != nil {
+		return f.db.Close()
+	}
+	return nil
+}
+
+func (f *fakeDriverDatabase) set(openErr, pingErr error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.openErr, f.pingErr = openErr, pingErr
+}
+
+func (f *fakeDriverDatabase) GetTables() (Tables, error) { return nil, nil }
+func (f *fakeDriverDatabase) Query(string, ...interface{}) (*QueryResult, error) {
+	return &QueryResult{}, nil
+}
+func (f *fakeDriverDatabase) QueryWithSettings(map[string]string, string) (*QueryResult, error) {
+	return &QueryResult{}, nil
+}
+func (f *fakeDriverDatabase) Explain(map[string]string, string) (Estimate, error) {
+	return Estimate{}, nil
+}
+func (f *fakeDriverDatabase) DryRun(map[string]string, string) (int64, error) { return 0, nil }
+func (f *fakeDriverDatabase) Exec(map[string]string, string, int64) (int64, error) {
+	return 0, nil
+}
+func (f *fakeDriverDatabase) SampleRowsQuery(string, []string, int) string { return "" }
+
+func TestManagedDatabase_Reconnect(t *testing.T) {
+	fake := &fakeDriverDatabase{openErr: errors.New("connection refused")}
+	m := newManagedDatabase(fake)
+
+	if err := m.Connect(); !errors.Is(err, ErrDatabaseDown) {
+		t.Fatalf("expected the database to be down, got %v", err)
+	}
+	if health := m.Health(); health.Status != StatusDown || health.Err == nil {
+		t.Fatalf("unexpected health %+v", health)
+	}
+
+	// While backing off, queries fail without another attempt
+	if _, err := m.Query("SELECT 1"); !errors.Is(err, ErrDatabaseDown) || fake.opened != 1 {
+		t.Fatalf("expected to fail during the backoff without opening, got %v after %d attempts", err, fake.opened)
+	}
+
+	// The server is back once the backoff is over
+	fake.set(nil, nil)
+	m.mu.Lock()
+	m.nextAttempt = time.Now()
+	m.mu.Unlock()
+	if wait := m.check(); wait != healthCheckInterval {
+		t.Fatalf("expected the regular interval after reconnecting, got %s", wait)
+	}
+	if health := m.Health(); health.Status != StatusUp || health.Err != nil {
+		t.Fatalf("unexpected health %+v", health)
+	}
+	if _, err := m.Query("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Failed pings degrade the status and then mark the server down
+	fake.set(nil, errors.New("timeout"))
+	m.check()
+	if status := m.Health().Status; status != StatusDegraded {
+		t.Fatalf("expected degraded after a failed ping, got %s", status)
+	}
+	for range downAfterFailures - 1 {
+		m.check()
+	}
+	if status := m.Health().Status; status != StatusDown {
+		t.Fatalf("expected down after %d failed pings, got %s", downAfterFailures, status)
+	}
+	fake.set(nil, nil)
+	m.check()
+	if status := m.Health().Status; status != StatusUp {
+		t.Fatalf("expected up after a successful ping, got %s", status)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Query("SELECT 1"); !errors.Is(err, ErrDatabaseClosed) {
+		t.Fatalf("expected queries to fail after closing, got %v", err)
+	}
+}
+
+func TestManagedDatabase_CloseWhileConnecting(t *testing.T) {
+	fake := &fakeDriverDatabase{opening: make(chan struct{}), started: make(chan struct{})}
+	m := newManagedDatabase(fake)
+
+	result := make(chan error)
+	go func() { result <- m.Connect() }()
+
+	<-fake.started
+	closed := make(chan error)
+	go func() { closed <- m.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close waited for the connection attempt")
+	}
+
+	close(fake.opening)
+	if err := <-result; !errors.Is(err, ErrDatabaseClosed) {
+		t.Fatalf("expected the attempt to fail after closing, got %v", err)
+	}
+	if fake.db != nil {
+		t.Fatal("the pool opened while closing was handed to the driver")
+	}
+	if m.Health().Status != StatusDown {
+		t.Fatalf("unexpected health %+v", m.Health())
+	}
+}
+
+func TestManagedDatabase_CloseDuringHealthChecks(t *testing.T) {
+	fake := &fakeDriverDatabase{}
+	m := newManagedDatabase(fake)
+
+	done := make(chan struct{})
+	go func() {
+		m.RunHealthChecks()
+		close(done)
+	}()
+	for m.Health().Status != StatusUp {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("health checks kept running after closing")
+	}
+	if err := fake.db.Ping(); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Fatalf("expected the pool to be closed, got %v", err)
+	}
+}
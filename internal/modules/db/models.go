@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -11,14 +12,31 @@ import (
 
 type QueryResult struct {
 	*sql.Rows
+	// tx is the transaction the query runs in, if any. It is rolled back on Close.
+	tx *sql.Tx
 }
 
-func (r QueryResult) Json() (string, error) {
+func (r *QueryResult) Close() error {
+	err := r.Rows.Close()
+	if r.tx != nil {
+		_ = r.tx.Rollback()
+	}
+	return err
+}
+
+// ValueMasker hides personal data in rendered values, see masking.RoleMasker
+type ValueMasker interface {
+	Mask(column string, value string) string
+}
+
+// Json renders the rows as a list of objects and returns the number of rows. Every value goes
+// through the masker, if any, before it is rendered.
+func (r QueryResult) Json(masker ValueMasker) (string, int, error) {
 	// Get column names
 	columns, err := r.Columns()
 	if err != nil {
 		log.Printf("error getting columns: %v", err)
-		return "", err
+		return "", 0, err
 	}
 
 	// Create a slice to hold all results
@@ -57,6 +75,9 @@ func (r QueryResult) Json() (string, error) {
 				toGo := SQLValueToGo(columnTypes[i], val)
 				// Convert to string using fmt.Sprintf to handle all types
 				strVal = fmt.Sprintf("%s", toGo)
+				if masker != nil {
+					strVal = masker.Mask(col, strVal)
+				}
 			}
 			rowMap[col] = strVal
 		}
@@ -66,22 +87,60 @@ func (r QueryResult) Json() (string, error) {
 	// Check for errors from iterating over rows
 	if err = r.Err(); err != nil {
 		log.Printf("error iterating rows: %v", err)
-		return "", err
+		return "", 0, err
 	}
 
 	// Marshal results to JSON
 	jsonData, err := json.MarshalIndent(results, "", "  ")
 	if err != nil {
 		log.Printf("error marshaling to JSON: %v", err)
-		return "", err
+		return "", 0, err
 	}
-	return string(jsonData), nil
+	return string(jsonData), len(results), nil
 }
 
+// Database is a connection to a configured database server. It connects lazily and keeps
+// track of the health of the server, see managedDatabase.
 type Database interface {
-	connect() error
 	GetTables() (Tables, error)
 	Query(query string, args ...interface{}) (*QueryResult, error)
+	// QueryWithSettings runs the query in a read-only transaction with the given settings
+	QueryWithSettings(settings map[string]string, query string) (*QueryResult, error)
+	// Explain asks the planner what the query would cost with the given settings, without running it
+	Explain(settings map[string]string, query string) (Estimate, error)
+	// DryRun runs the statement with the given settings and rolls it back, returning the
+	// number of rows it would affect
+	DryRun(settings map[string]string, statement string) (int64, error)
+	// Exec runs the statement with the given settings and commits it only if it affects the
+	// expected number of rows, otherwise it returns ErrAffectedRowsChanged
+	Exec(settings map[string]string, statement string, expected int64) (int64, error)
+	// SampleRowsQuery returns a query of up to limit rows of the given columns of the table,
+	// to be run like any other query
+	SampleRowsQuery(tableName string, columns []string, limit int) string
+	Health() Health
+	// Connect establishes the connection now instead of on first use
+	Connect() error
+	// RunHealthChecks pings the server periodically until Close is called
+	RunHealthChecks()
+	Close() error
+}
+
+// driverDatabase is implemented by every supported driver
+type driverDatabase interface {
+	// open connects to the server and returns the connection pool, which setDB then hands
+	// to the driver. They are separate so that the pool is swapped under the lock of
+	// managedDatabase while connecting runs without it.
+	open() (*sql.DB, error)
+	setDB(db *sql.DB)
+	ping(ctx context.Context) error
+	Close() error
+	GetTables() (Tables, error)
+	Query(query string, args ...interface{}) (*QueryResult, error)
+	QueryWithSettings(settings map[string]string, query string) (*QueryResult, error)
+	Explain(settings map[string]string, query string) (Estimate, error)
+	DryRun(settings map[string]string, statement string) (int64, error)
+	Exec(settings map[string]string, statement string, expected int64) (int64, error)
+	SampleRowsQuery(tableName string, columns []string, limit int) string
 }
 
 type Column struct {
@@ -131,8 +190,8 @@ type DatabaseConfig struct {
 	User     string
 	Password string
 	Database string
-	SSLMode  string
 	Schema   string // Default to "public" if empty
+	ConnectionOptions
 }
 
 type Driver int8
@@ -156,39 +215,41 @@ func (d Driver) String() string {
 	return "unknown"
 }
 
+// NewDatabase creates the database of the given driver without connecting to it.
+// The connection is established on first use or by the health checks.
 func NewDatabase(cfg DatabaseConfig, driver Driver) (Database, error) {
-	var database Database
-	var err error
+	var database driverDatabase
 	switch driver {
 	case Postgres:
-		database, err = newDatabasePostgresImpl(postgresConfig{
-			Host:     cfg.Host,
-			Port:     cfg.Port,
-			User:     cfg.User,
-			Password: cfg.Password,
-			DBName:   cfg.Database,
+		database = newDatabasePostgresImpl(postgresConfig{
+			Host:              cfg.Host,
+			Port:              cfg.Port,
+			User:              cfg.User,
+			Password:          cfg.Password,
+			DBName:            cfg.Database,
+			ConnectionOptions: cfg.ConnectionOptions,
 		})
 	case MySQL:
-		database, err = newDatabaseMySqlImpl(mySqlConfig{
-			Host:     cfg.Host,
-			Port:     cfg.Port,
-			User:     cfg.User,
-			Password: cfg.Password,
-			Database: cfg.Database,
+		database = newDatabaseMySqlImpl(mySqlConfig{
+			Host:              cfg.Host,
+			Port:              cfg.Port,
+			User:              cfg.User,
+			Password:          cfg.Password,
+			Database:          cfg.Database,
+			ConnectionOptions: cfg.ConnectionOptions,
 		})
 	case Cockroach:
-		database, err = newDatabaseCockroachImpl(cockroachConfig{
-			Host:     cfg.Host,
-			Port:     cfg.Port,
-			User:     cfg.User,
-			Password: cfg.Password,
-			Database: cfg.Database,
+		database = newDatabaseCockroachImpl(cockroachConfig{
+			Host:              cfg.Host,
+			Port:              cfg.Port,
+			User:              cfg.User,
+			Password:          cfg.Password,
+			Database:          cfg.Database,
+			ConnectionOptions: cfg.ConnectionOptions,
 		})
 	default:
-		err = fmt.Errorf("unknown database driver: %s", driver)
+		return nil, fmt.Errorf("unknown database driver: %s", driver)
 	}
-	if err != nil {
-		return nil, err
-	}
-	return database, nil
+
+	return newManagedDatabase(database), nil
 }
@@ -1,10 +1,13 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"net"
+	"strings"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 type mySqlConfig struct {
@@ -13,6 +16,7 @@ type mySqlConfig struct {
 	User     string
 	Password string
 	Database string
+	ConnectionOptions
 }
 
 type databaseMySqlImpl struct {
@@ -20,35 +24,57 @@ type databaseMySqlImpl struct {
 	config mySqlConfig
 }
 
-func (d *databaseMySqlImpl) connect() error {
+// open connects to the server and verifies the connection, leaving d unchanged
+func (d *databaseMySqlImpl) open() (*sql.DB, error) {
 	// Build MySQL connection string
 	// Format: username:password@tcp(host:port)/database?parseTime=true
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&charset=utf8mb4",
-		d.config.User,
-		d.config.Password,
-		d.config.Host,
-		d.config.Port,
-		d.config.Database,
-	)
+	cfg := mysql.NewConfig()
+	cfg.User = d.config.User
+	cfg.Passwd = d.config.Password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(d.config.Host, d.config.Port)
+	cfg.DBName = d.config.Database
+	cfg.ParseTime = true
+	cfg.Params = map[string]string{"charset": "utf8mb4"}
+	cfg.Timeout = d.config.connectTimeout()
+	if d.config.ApplicationName != "" {
+		cfg.ConnectionAttributes = "program_name:" + d.config.ApplicationName
+	}
+
+	// The driver looks TLS configurations up by the name given in the DSN
+	tlsConfigName := fmt.Sprintf("talk-to-db-%s-%s-%s", d.config.Host, d.config.Port, d.config.Database)
+	tlsParam, tlsConfig, err := d.config.mySQLTLS(tlsConfigName, d.config.Host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MySQL TLS configuration: %w", err)
+	}
+	if tlsConfig != nil {
+		if err := mysql.RegisterTLSConfig(tlsConfigName, tlsConfig); err != nil {
+			return nil, fmt.Errorf("failed to register MySQL TLS configuration: %w", err)
+		}
+	}
+	cfg.TLSConfig = tlsParam
 
 	// Open database connection
-	db, err := sql.Open("mysql", dsn)
+	db, err := sql.Open("mysql", cfg.FormatDSN())
 	if err != nil {
-		return fmt.Errorf("failed to open MySQL connection: %w", err)
+		return nil, fmt.Errorf("failed to open MySQL connection: %w", err)
 	}
 
 	// Verify connection by pinging
-	if err := db.Ping(); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), d.config.connectTimeout())
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
 		db.Close()
-		return fmt.Errorf("failed to ping MySQL database: %w", err)
+		return nil, fmt.Errorf("failed to ping MySQL database: %w", err)
 	}
 
-	// Set connection pool settings for thread safety
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
+	d.config.applyPool(db)
 
+	return db, nil
+}
+
+func (d *databaseMySqlImpl) setDB(db *sql.DB) {
 	d.db = db
-	return nil
 }
 
 func (d *databaseMySqlImpl) GetTables() (Tables, error) {
@@ -141,6 +167,82 @@ func (d *databaseMySqlImpl) Query(query string, args ...interface{}) (*QueryResu
 	return &QueryResult{Rows: rows}, nil
 }
 
+// QueryWithSettings runs the query in a read-only transaction. MySQL has no row level
+// security to read settings, so none may be given.
+func (d *databaseMySqlImpl) QueryWithSettings(settings map[string]string, query string) (*QueryResult, error) {
+	if d.db == nil {
+		return nil, fmt.Errorf("database connection is not established")
+	}
+	if len(settings) > 0 {
+		return nil, fmt.Errorf("settings are not supported by MySQL")
+	}
+
+	return queryWithSettings(d.db, nil, query)
+}
+
+// Explain estimates the cost of the query. Older servers run the subqueries of FROM to
+// explain them, so it runs in a read-only transaction too.
+func (d *databaseMySqlImpl) Explain(settings map[string]string, query string) (Estimate, error) {
+	if d.db == nil {
+		return Estimate{}, fmt.Errorf("database connection is not established")
+	}
+	if len(settings) > 0 {
+		return Estimate{}, fmt.Errorf("settings are not supported by MySQL")
+	}
+
+	tx, err := beginWithSettings(d.db, nil)
+	if err != nil {
+		return Estimate{}, err
+	}
+	defer tx.Rollback()
+
+	var plan []byte
+	if err := tx.QueryRow("EXPLAIN FORMAT=JSON " + query).Scan(&plan); err != nil {
+		return Estimate{}, fmt.Errorf("failed to explain query: %w", err)
+	}
+
+	return parseMySQLPlan(plan)
+}
+
+// DryRun returns the number of rows the statement affects and rolls it back. MySQL has no
+// row level security to read settings, so none may be given.
+func (d *databaseMySqlImpl) DryRun(settings map[string]string, statement string) (int64, error) {
+	if d.db == nil {
+		return 0, fmt.Errorf("database connection is not established")
+	}
+	if len(settings) > 0 {
+		return 0, fmt.Errorf("settings are not supported by MySQL")
+	}
+
+	return dryRun(d.db, nil, statement)
+}
+
+// Exec runs the statement if it affects the expected number of rows
+func (d *databaseMySqlImpl) Exec(settings map[string]string, statement string, expected int64) (int64, error) {
+	if d.db == nil {
+		return 0, fmt.Errorf("database connection is not established")
+	}
+	if len(settings) > 0 {
+		return 0, fmt.Errorf("settings are not supported by MySQL")
+	}
+
+	return execExpecting(d.db, nil, statement, expected)
+}
+
+// SampleRowsQuery returns a query of up to limit rows of the given columns of the table
+func (d *databaseMySqlImpl) SampleRowsQuery(tableName string, columns []string, limit int) string {
+	return sampleRowsQuery(quoteMySqlIdentifier(tableName), columns, limit, quoteMySqlIdentifier)
+}
+
+// ping checks that the database is still reachable
+func (d *databaseMySqlImpl) ping(ctx context.Context) error {
+	if d.db == nil {
+		return fmt.Errorf("database connection is not established")
+	}
+
+	return d.db.PingContext(ctx)
+}
+
 // Close closes the database connection
 func (d *databaseMySqlImpl) Close() error {
 	if d.db != nil {
@@ -149,15 +251,13 @@ func (d *databaseMySqlImpl) Close() error {
 	return nil
 }
 
-func newDatabaseMySqlImpl(config mySqlConfig) (Database, error) {
-	result := &databaseMySqlImpl{
+func newDatabaseMySqlImpl(config mySqlConfig) driverDatabase {
+	return &databaseMySqlImpl{
 		config: config,
 	}
+}
 
-	err := result.connect()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create MySQL database instance: %w", err)
-	}
-
-	return result, nil
+// quoteMySqlIdentifier quotes an identifier for MySQL
+func quoteMySqlIdentifier(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
 }
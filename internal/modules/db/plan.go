@@ -0,0 +1,149 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Estimate is what the planner expects a query to cost, as reported by EXPLAIN
+type Estimate struct {
+	// Rows is the largest number of rows a step of the plan is expected to produce. A scan
+	// with a selective filter produces few rows, so large scans show in Cost and FullScans.
+	Rows float64
+	// Cost is the total cost of the plan in the units of the planner, 0 if the driver reports none
+	Cost float64
+	// FullScans are the tables the plan reads completely
+	FullScans []string
+}
+
+func (e *Estimate) addRows(rows float64) {
+	e.Rows = max(e.Rows, rows)
+}
+
+func (e *Estimate) addFullScan(table string) {
+	if table != "" && !slices.Contains(e.FullScans, table) {
+		e.FullScans = append(e.FullScans, table)
+	}
+}
+
+// postgresPlanNode is a node of the output of EXPLAIN (FORMAT JSON)
+type postgresPlanNode struct {
+	NodeType     string             `json:"Node Type"`
+	RelationName string             `json:"Relation Name"`
+	TotalCost    float64            `json:"Total Cost"`
+	PlanRows     float64            `json:"Plan Rows"`
+	Plans        []postgresPlanNode `json:"Plans"`
+}
+
+// parsePostgresPlan reads the output of EXPLAIN (FORMAT JSON)
+func parsePostgresPlan(plan []byte) (Estimate, error) {
+	var statements []struct {
+		Plan postgresPlanNode `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &statements); err != nil {
+		return Estimate{}, fmt.Errorf("failed to parse PostgreSQL plan: %w", err)
+	}
+
+	var estimate Estimate
+	for _, statement := range statements {
+		estimate.Cost += statement.Plan.TotalCost
+		statement.Plan.walk(&estimate)
+	}
+	return estimate, nil
+}
+
+func (n postgresPlanNode) walk(estimate *Estimate) {
+	estimate.addRows(n.PlanRows)
+	if n.NodeType == "Seq Scan" {
+		estimate.addFullScan(n.RelationName)
+	}
+	for _, child := range n.Plans {
+		child.walk(estimate)
+	}
+}
+
+// parseMySQLPlan reads the output of EXPLAIN FORMAT=JSON. Steps of the plan are nested in
+// many different keys, such as nested_loop and ordering_operation, so every object is visited.
+func parseMySQLPlan(plan []byte) (Estimate, error) {
+	var root map[string]any
+	if err := json.Unmarshal(plan, &root); err != nil {
+		return Estimate{}, fmt.Errorf("failed to parse MySQL plan: %w", err)
+	}
+
+	var estimate Estimate
+	if block, ok := root["query_block"].(map[string]any); ok {
+		if costInfo, ok := block["cost_info"].(map[string]any); ok {
+			estimate.Cost, _ = jsonNumber(costInfo["query_cost"])
+		}
+	}
+	walkMySQLPlan(root, &estimate)
+
+	return estimate, nil
+}
+
+func walkMySQLPlan(value any, estimate *Estimate) {
+	switch value := value.(type) {
+	case map[string]any:
+		for _, key := range []string{"rows_examined_per_scan", "rows_produced_per_join", "rows"} {
+			if rows, ok := jsonNumber(value[key]); ok {
+				estimate.addRows(rows)
+			}
+		}
+		if value["access_type"] == "ALL" {
+			table, _ := value["table_name"].(string)
+			estimate.addFullScan(table)
+		}
+		for _, child := range value {
+			walkMySQLPlan(child, estimate)
+		}
+	case []any:
+		for _, child := range value {
+			walkMySQLPlan(child, estimate)
+		}
+	}
+}
+
+// jsonNumber reads a number that MySQL may report as a string, as it does for costs
+func jsonNumber(value any) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case string:
+		number, err := strconv.ParseFloat(value, 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// parseCockroachPlan reads the lines of EXPLAIN. CockroachDB reports no cost, only an
+// "estimated row count" and, for scans of whole tables, "spans: FULL SCAN" under each node.
+func parseCockroachPlan(lines []string) (Estimate, error) {
+	var estimate Estimate
+	var table string
+	for _, line := range lines {
+		// Child nodes are drawn as a tree
+		line = strings.TrimLeft(line, " \t│├└─")
+		switch {
+		case strings.HasPrefix(line, "•"):
+			table = ""
+		case strings.HasPrefix(line, "estimated row count:"):
+			fields := strings.Fields(strings.TrimPrefix(line, "estimated row count:"))
+			if len(fields) == 0 {
+				continue
+			}
+			rows, err := strconv.ParseFloat(strings.ReplaceAll(fields[0], ",", ""), 64)
+			if err != nil {
+				return Estimate{}, fmt.Errorf("failed to parse CockroachDB row count %q: %w", line, err)
+			}
+			estimate.addRows(rows)
+		case strings.HasPrefix(line, "table:"):
+			table, _, _ = strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, "table:")), "@")
+		case strings.HasPrefix(line, "spans:") && strings.Contains(line, "FULL SCAN"):
+			estimate.addFullScan(table)
+		}
+	}
+	return estimate, nil
+}
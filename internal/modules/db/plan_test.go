@@ -0,0 +1,69 @@
+package db
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParsePostgresPlan(t *testing.T) {
+	plan := `[{"Plan": {
+		"Node Type": "Aggregate", "Total Cost": 18334.01, "Plan Rows": 1,
+		"Plans": [{"Node Type": "Hash Join", "Total Cost": 18000, "Plan Rows": 20,
+			"Plans": [
+				{"Node Type": "Seq Scan", "Relation Name": "transfers", "Total Cost": 15406, "Plan Rows": 1000000},
+				{"Node Type": "Index Scan", "Relation Name": "user_account", "Total Cost": 8.3, "Plan Rows": 1}
+			]}]
+	}}]`
+
+	estimate, err := parsePostgresPlan([]byte(plan))
+	if err != nil {
+		t.Fatalf("parsePostgresPlan: %v", err)
+	}
+	if estimate.Cost != 18334.01 || estimate.Rows != 1000000 || !slices.Equal(estimate.FullScans, []string{"transfers"}) {
+		t.Fatalf("unexpected estimate: %+v", estimate)
+	}
+}
+
+func TestParseMySQLPlan(t *testing.T) {
+	plan := `{"query_block": {
+		"select_id": 1,
+		"cost_info": {"query_cost": "102340.50"},
+		"ordering_operation": {"nested_loop": [
+			{"table": {"table_name": "transfers", "access_type": "ALL", "rows_examined_per_scan": 998001, "rows_produced_per_join": 99800}},
+			{"table": {"table_name": "user_account", "access_type": "eq_ref", "rows_examined_per_scan": 1, "rows_produced_per_join": 99800}}
+		]}
+	}}`
+
+	estimate, err := parseMySQLPlan([]byte(plan))
+	if err != nil {
+		t.Fatalf("parseMySQLPlan: %v", err)
+	}
+	if estimate.Cost != 102340.50 || estimate.Rows != 998001 || !slices.Equal(estimate.FullScans, []string{"transfers"}) {
+		t.Fatalf("unexpected estimate: %+v", estimate)
+	}
+}
+
+func TestParseCockroachPlan(t *testing.T) {
+	lines := []string{
+		"distribution: full",
+		"vectorized: true",
+		"",
+		"• lookup join",
+		"│ estimated row count: 12",
+		"│ table: user_account@user_account_pkey",
+		"│ spans: FULL SCAN",
+		"│",
+		"└── • scan",
+		"      estimated row count: 1,250,000 (100% of the table; stats collected 2 days ago)",
+		"      table: transfers@transfers_pkey",
+		"      spans: FULL SCAN",
+	}
+
+	estimate, err := parseCockroachPlan(lines)
+	if err != nil {
+		t.Fatalf("parseCockroachPlan: %v", err)
+	}
+	if estimate.Rows != 1250000 || estimate.Cost != 0 || !slices.Equal(estimate.FullScans, []string{"user_account", "transfers"}) {
+		t.Fatalf("unexpected estimate: %+v", estimate)
+	}
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -14,8 +15,8 @@ type postgresConfig struct {
 	User     string
 	Password string
 	DBName   string
-	SSLMode  string
 	Schema   string // Default to "public" if empty
+	ConnectionOptions
 }
 
 type databasePostgresImpl struct {
@@ -23,49 +24,22 @@ type databasePostgresImpl struct {
 	config postgresConfig
 }
 
-func (d *databasePostgresImpl) connect() error {
-	// Set default schema if not provided
-	schema := d.config.Schema
-	if schema == "" {
-		schema = "public"
-	}
-
-	// Set default SSL mode if not provided
-	sslMode := d.config.SSLMode
-	if sslMode == "" {
-		sslMode = "disable" // Default to disable for local development
-	}
-
+// open connects to the server and verifies the connection, leaving d unchanged
+func (d *databasePostgresImpl) open() (*sql.DB, error) {
 	// Build PostgreSQL connection string
 	// Format: host=... port=... user=... password=... dbname=... sslmode=...
 	connParts := []string{
-		fmt.Sprintf("host=%s", d.config.Host),
-		fmt.Sprintf("port=%s", d.config.Port),
-		fmt.Sprintf("user=%s", d.config.User),
-		fmt.Sprintf("password=%s", d.config.Password),
-		fmt.Sprintf("dbname=%s", d.config.DBName),
-		fmt.Sprintf("sslmode=%s", sslMode),
+		postgresParam("host", d.config.Host),
+		postgresParam("port", d.config.Port),
+		postgresParam("user", d.config.User),
+		postgresParam("password", d.config.Password),
+		postgresParam("dbname", d.config.DBName),
 	}
-	connStr := strings.Join(connParts, " ")
-
-	// Open database connection
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return fmt.Errorf("failed to open PostgreSQL connection: %w", err)
-	}
-
-	// Verify connection by pinging
-	if err := db.Ping(); err != nil {
-		db.Close()
-		return fmt.Errorf("failed to ping PostgreSQL database: %w", err)
-	}
-
-	// Set connection pool settings for thread safety
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
+	return d.config.openPostgres(connParts, "PostgreSQL")
+}
 
+func (d *databasePostgresImpl) setDB(db *sql.DB) {
 	d.db = db
-	return nil
 }
 
 func (d *databasePostgresImpl) GetTables() (Tables, error) {
@@ -164,6 +138,74 @@ func (d *databasePostgresImpl) Query(query string, args ...interface{}) (*QueryR
 	return &QueryResult{Rows: rows}, nil
 }
 
+func (d *databasePostgresImpl) QueryWithSettings(settings map[string]string, query string) (*QueryResult, error) {
+	if d.db == nil {
+		return nil, fmt.Errorf("database connection is not established")
+	}
+
+	return queryWithSettings(d.db, settings, query)
+}
+
+// Explain estimates the cost of the query with the settings applied, see queryWithSettings
+func (d *databasePostgresImpl) Explain(settings map[string]string, query string) (Estimate, error) {
+	if d.db == nil {
+		return Estimate{}, fmt.Errorf("database connection is not established")
+	}
+
+	tx, err := beginWithSettings(d.db, settings)
+	if err != nil {
+		return Estimate{}, err
+	}
+	defer tx.Rollback()
+
+	var plan []byte
+	if err := tx.QueryRow("EXPLAIN (FORMAT JSON) " + query).Scan(&plan); err != nil {
+		return Estimate{}, fmt.Errorf("failed to explain query: %w", err)
+	}
+
+	return parsePostgresPlan(plan)
+}
+
+// DryRun returns the number of rows the statement affects with the settings applied and
+// rolls it back
+func (d *databasePostgresImpl) DryRun(settings map[string]string, statement string) (int64, error) {
+	if d.db == nil {
+		return 0, fmt.Errorf("database connection is not established")
+	}
+
+	return dryRun(d.db, settings, statement)
+}
+
+// Exec runs the statement with the settings applied if it affects the expected number of rows
+func (d *databasePostgresImpl) Exec(settings map[string]string, statement string, expected int64) (int64, error) {
+	if d.db == nil {
+		return 0, fmt.Errorf("database connection is not established")
+	}
+
+	return execExpecting(d.db, settings, statement, expected)
+}
+
+// SampleRowsQuery returns a query of up to limit rows of the given columns of the table
+func (d *databasePostgresImpl) SampleRowsQuery(tableName string, columns []string, limit int) string {
+	// Determine schema to use
+	schema := d.config.Schema
+	if schema == "" {
+		schema = "public"
+	}
+
+	table := quotePostgresIdentifier(schema) + "." + quotePostgresIdentifier(tableName)
+	return sampleRowsQuery(table, columns, limit, quotePostgresIdentifier)
+}
+
+// ping checks that the database is still reachable
+func (d *databasePostgresImpl) ping(ctx context.Context) error {
+	if d.db == nil {
+		return fmt.Errorf("database connection is not established")
+	}
+
+	return d.db.PingContext(ctx)
+}
+
 // Close closes the database connection
 func (d *databasePostgresImpl) Close() error {
 	if d.db != nil {
@@ -207,15 +249,13 @@ func (d *databasePostgresImpl) GetSchemas() ([]string, error) {
 	return schemas, nil
 }
 
-func newDatabasePostgresImpl(config postgresConfig) (Database, error) {
-	result := &databasePostgresImpl{
+func newDatabasePostgresImpl(config postgresConfig) driverDatabase {
+	return &databasePostgresImpl{
 		config: config,
 	}
+}
 
-	err := result.connect()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create PostgreSQL database instance: %w", err)
-	}
-
-	return result, nil
+// quotePostgresIdentifier quotes an identifier for PostgreSQL compatible databases
+func quotePostgresIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
 }
@@ -0,0 +1,16 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sampleRowsQuery selects up to limit rows of the given columns of the table. Identifiers
+// can't be passed as parameters, so they are quoted instead.
+func sampleRowsQuery(table string, columns []string, limit int, quote func(string) string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quote(column)
+	}
+	return fmt.Sprintf("SELECT %s FROM %s LIMIT %d", strings.Join(quoted, ", "), table, limit)
+}
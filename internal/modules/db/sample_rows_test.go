@@ -0,0 +1,29 @@
+package db
+
+import "testing"
+
+func TestSampleRowsQuery(t *testing.T) {
+	columns := []string{"id", `odd"name`, "back`tick"}
+	tests := []struct {
+		database driverDatabase
+		expected string
+	}{
+		{
+			database: newDatabasePostgresImpl(postgresConfig{}),
+			expected: `SELECT "id", "odd""name", "back` + "`" + `tick" FROM "public"."users" LIMIT 5`,
+		},
+		{
+			database: newDatabaseCockroachImpl(cockroachConfig{Schema: "sales"}),
+			expected: `SELECT "id", "odd""name", "back` + "`" + `tick" FROM "sales"."users" LIMIT 5`,
+		},
+		{
+			database: newDatabaseMySqlImpl(mySqlConfig{}),
+			expected: "SELECT `id`, `odd\"name`, `back``tick` FROM `users` LIMIT 5",
+		},
+	}
+	for _, test := range tests {
+		if query := test.database.SampleRowsQuery("users", columns, 5); query != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, query)
+		}
+	}
+}
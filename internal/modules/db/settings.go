@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// queryWithSettings runs the query in a read-only transaction after setting the settings
+// for the transaction only, so that PostgreSQL row level security policies can read them
+// with current_setting. The transaction is rolled back when the result is closed.
+func queryWithSettings(db *sql.DB, settings map[string]string, query string) (*QueryResult, error) {
+	tx, err := beginWithSettings(db, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(query)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return &QueryResult{Rows: rows, tx: tx}, nil
+}
+
+// beginWithSettings begins a read-only transaction with the settings applied
+func beginWithSettings(db *sql.DB, settings map[string]string) (*sql.Tx, error) {
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(settings)) {
+		if _, err := tx.Exec("SELECT set_config($1, $2, true)", name, settings[name]); err != nil {
+			_ = tx.Rollback()
+			return nil, fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+
+	return tx, nil
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+var ErrAffectedRowsChanged = errors.New("the statement affects a different number of rows than its dry run, the data changed in between")
+
+// execWithSettings runs the statement in a transaction with the settings applied, see
+// queryWithSettings. The transaction is committed only if commit accepts the number of
+// affected rows, otherwise it is rolled back.
+func execWithSettings(db *sql.DB, settings map[string]string, statement string, commit func(affected int64) bool) (int64, error) {
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, name := range slices.Sorted(maps.Keys(settings)) {
+		if _, err := tx.Exec("SELECT set_config($1, $2, true)", name, settings[name]); err != nil {
+			return 0, fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+
+	result, err := tx.Exec(statement)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute statement: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read affected rows: %w", err)
+	}
+
+	if !commit(affected) {
+		return affected, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return affected, nil
+}
+
+// dryRun returns the number of rows the statement affects without keeping its changes
+func dryRun(db *sql.DB, settings map[string]string, statement string) (int64, error) {
+	return execWithSettings(db, settings, statement, func(int64) bool { return false })
+}
+
+// execExpecting commits the statement only if it affects the expected number of rows
+func execExpecting(db *sql.DB, settings map[string]string, statement string, expected int64) (int64, error) {
+	committed := false
+	affected, err := execWithSettings(db, settings, statement, func(affected int64) bool {
+		committed = affected == expected
+		return committed
+	})
+	if err == nil && !committed {
+		return affected, fmt.Errorf("%w: %d rows instead of %d", ErrAffectedRowsChanged, affected, expected)
+	}
+	return affected, err
+}
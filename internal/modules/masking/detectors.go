@@ -0,0 +1,95 @@
+package masking
+
+import (
+	"regexp"
+)
+
+// detector finds values of a kind inside free text. Candidates matched by the pattern are
+// confirmed by valid, when set, to keep ordinary numbers such as amounts readable.
+type detector struct {
+	kind    Kind
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+}
+
+var detectors = map[Kind]detector{
+	KindCard: {
+		kind:    KindCard,
+		pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		valid:   func(match string) bool { return luhnValid(digitsOf(match)) },
+	},
+	KindPhone: {
+		kind: KindPhone,
+		// International numbers and numbers with a leading trunk 0, as in 09121234567
+		pattern: regexp.MustCompile(`(?:\+|\b00)\d{1,3}[ -]?\d{2,4}[ -]?\d{3,4}[ -]?\d{3,4}\b|\b0\d{2,3}[ -]?\d{3,4}[ -]?\d{4}\b`),
+	},
+	KindEmail: {
+		kind:    KindEmail,
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	KindNationalID: {
+		kind:    KindNationalID,
+		pattern: regexp.MustCompile(`\b\d{10}\b`),
+		valid:   func(match string) bool { return nationalIDValid(match) },
+	},
+}
+
+// replace masks every detected value in the text
+func (d detector) replace(text string) string {
+	return d.pattern.ReplaceAllStringFunc(text, func(match string) string {
+		if d.valid != nil && !d.valid(match) {
+			return match
+		}
+		return maskValue(d.kind, match)
+	})
+}
+
+func digitsOf(value string) []int {
+	var digits []int
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	return digits
+}
+
+// luhnValid checks the check digit of card numbers
+func luhnValid(digits []int) bool {
+	sum := 0
+	for i := range digits {
+		digit := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return len(digits) > 0 && sum%10 == 0
+}
+
+// nationalIDValid checks the check digit of Iranian national codes
+func nationalIDValid(value string) bool {
+	digits := digitsOf(value)
+	if len(digits) != 10 {
+		return false
+	}
+
+	allSame := true
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += digits[i] * (10 - i)
+		allSame = allSame && digits[i] == digits[9]
+	}
+	if allSame {
+		return false
+	}
+
+	remainder := sum % 11
+	if remainder < 2 {
+		return digits[9] == remainder
+	}
+	return digits[9] == 11-remainder
+}
@@ -0,0 +1,160 @@
+package masking
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Kind is a type of personal data. It decides how values are masked and, for detector
+// rules, which values are detected.
+type Kind string
+
+const (
+	KindCard       Kind = "card"        // 6037998210432286 -> 6037********2286
+	KindPhone      Kind = "phone"       // 09121234567 -> 0912*****67
+	KindEmail      Kind = "email"       // ali@example.com -> a**@example.com
+	KindNationalID Kind = "national_id" // 0012345679 -> ******5679
+	KindFull       Kind = "full"        // anything -> ****, for column rules only
+)
+
+// DetectableKinds can be found in values of any column
+var DetectableKinds = []Kind{KindCard, KindPhone, KindEmail, KindNationalID}
+
+var Kinds = append(slices.Clone(DetectableKinds), KindFull)
+
+// Rule masks the values of a result column or, without a column, the values of the kind
+// detected in every column
+type Rule struct {
+	Column        string
+	Kind          Kind
+	UnmaskedRoles []string
+}
+
+// Masker applies the masking rules to rendered query results. Rules apply to every role but
+// their UnmaskedRoles.
+type Masker struct {
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+func NewMasker(rules []Rule) *Masker {
+	masker := &Masker{}
+	masker.Update(rules)
+	return masker
+}
+
+// Update replaces the rules, e.g. when the config is reloaded
+func (m *Masker) Update(rules []Rule) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = rules
+}
+
+// ForRole returns the masking of values shown to users of the role. An empty role applies
+// every rule, which suits values that leave the bot, like sample rows sent to the model.
+func (m *Masker) ForRole(role string) RoleMasker {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var masker RoleMasker
+	for _, rule := range m.rules {
+		if role != "" && slices.Contains(rule.UnmaskedRoles, role) {
+			continue
+		}
+		if rule.Column == "" {
+			masker.detectors = append(masker.detectors, rule.Kind)
+		} else {
+			masker.columns = append(masker.columns, rule)
+		}
+	}
+	return masker
+}
+
+// RoleMasker masks values according to the rules that apply to one role
+type RoleMasker struct {
+	columns   []Rule
+	detectors []Kind
+}
+
+// Columns returns the names of the result columns masked by column rules
+func (m RoleMasker) Columns() []string {
+	columns := make([]string, 0, len(m.columns))
+	for _, rule := range m.columns {
+		columns = append(columns, rule.Column)
+	}
+	return columns
+}
+
+// Mask returns the value of the result column as it may be shown
+func (m RoleMasker) Mask(column string, value string) string {
+	for _, rule := range m.columns {
+		if strings.EqualFold(rule.Column, column) {
+			value = maskValue(rule.Kind, value)
+			break
+		}
+	}
+	for _, kind := range m.detectors {
+		value = detectors[kind].replace(value)
+	}
+
+	return value
+}
+
+func maskValue(kind Kind, value string) string {
+	switch kind {
+	case KindCard:
+		return maskDigits(value, 4, 4)
+	case KindPhone:
+		return maskDigits(value, 4, 2)
+	case KindNationalID:
+		return maskDigits(value, 0, 4)
+	case KindEmail:
+		return maskEmail(value)
+	default:
+		return fullMask
+	}
+}
+
+const (
+	maskCharacter = '*'
+	fullMask      = "****"
+)
+
+// maskDigits replaces the digits of the value but the first keepStart and last keepEnd,
+// leaving separators such as dashes in place. Values with too few digits are masked fully.
+func maskDigits(value string, keepStart int, keepEnd int) string {
+	digits := 0
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	if digits <= keepStart+keepEnd {
+		return fullMask
+	}
+
+	var masked strings.Builder
+	index := 0
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			if index >= keepStart && index < digits-keepEnd {
+				r = maskCharacter
+			}
+			index++
+		}
+		masked.WriteRune(r)
+	}
+	return masked.String()
+}
+
+// maskEmail keeps the first character of the local part and the domain
+func maskEmail(value string) string {
+	local, domain, found := strings.Cut(value, "@")
+	if !found || local == "" {
+		return fullMask
+	}
+
+	first := []rune(local)[0]
+	return string(first) + strings.Repeat(string(maskCharacter), len([]rune(local))-1) + "@" + domain
+}
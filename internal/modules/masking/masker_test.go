@@ -0,0 +1,47 @@
+package masking
+
+import "testing"
+
+func TestMasker(t *testing.T) {
+	masker := NewMasker([]Rule{
+		{Column: "card_number", Kind: KindCard, UnmaskedRoles: []string{"admin"}},
+		{Column: "salary", Kind: KindFull},
+		{Kind: KindEmail},
+		{Kind: KindCard},
+		{Kind: KindNationalID},
+		{Kind: KindPhone},
+	})
+
+	viewer := masker.ForRole("viewer")
+	tests := []struct {
+		column   string
+		value    string
+		expected string
+	}{
+		{"card_number", "6037998210432286", "6037********2286"},
+		{"CARD_NUMBER", "6037-9982-1043-2286", "6037-****-****-2286"},
+		{"salary", "1500000", "****"},
+		{"note", "paid with 6037998210432281 by ali@example.com", "paid with 6037********2281 by a**@example.com"},
+		{"note", "call 09121234567", "call 0912*****67"},
+		{"code", "0012345679", "******5679"},
+		// Numbers failing the check digits are left alone
+		{"amount", "1234567890123456", "1234567890123456"},
+		{"code", "1234567890", "1234567890"},
+	}
+	for _, test := range tests {
+		if masked := viewer.Mask(test.column, test.value); masked != test.expected {
+			t.Errorf("Mask(%q, %q) = %q, expected %q", test.column, test.value, masked, test.expected)
+		}
+	}
+
+	// The admin sees the card column, the card detector still applies to other columns
+	admin := masker.ForRole("admin")
+	if masked := admin.Mask("card_number", "6037998210432281"); masked != "6037********2281" {
+		t.Errorf("detector rules without exemption should apply to admins, got %q", masked)
+	}
+
+	masker.Update([]Rule{{Column: "card_number", Kind: KindCard, UnmaskedRoles: []string{"admin"}}})
+	if masked := masker.ForRole("admin").Mask("card_number", "6037998210432286"); masked != "6037998210432286" {
+		t.Errorf("admin should see the card number, got %q", masked)
+	}
+}
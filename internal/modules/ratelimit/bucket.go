@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// bucket is a token bucket refilled at a rate per minute up to its burst
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take removes a token from the bucket, or returns how long to wait until one is available.
+// A zero rate never limits.
+func (b *bucket) take(perMinute float64, burst int, now time.Time) time.Duration {
+	if perMinute <= 0 {
+		return 0
+	}
+	capacity := float64(burst)
+	if burst <= 0 {
+		capacity = math.Max(1, math.Ceil(perMinute))
+	}
+
+	perSecond := perMinute / 60
+	if b.updated.IsZero() {
+		b.tokens = capacity
+	} else {
+		b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*perSecond)
+	}
+	b.updated = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
@@ -0,0 +1,250 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+)
+
+var (
+	ErrTooManyMessages = errors.New("you are sending messages too quickly")
+	ErrTooManyQueries  = errors.New("you are asking questions too quickly")
+	ErrDailyBudget     = errors.New("you have used up today's question budget, it renews tomorrow")
+	ErrMonthlyBudget   = errors.New("you have used up this month's question budget, it renews next month")
+	// ErrMessageDropped is returned for further messages of a user who was already told about
+	// the message rate limit, so that a flood of messages is not answered with a flood of replies
+	ErrMessageDropped = errors.New("message dropped, the user was told about the message rate limit")
+)
+
+// Limits are the limits of every user. Zero values disable a limit, a zero burst allows as
+// many requests at once as the rate allows per minute.
+type Limits struct {
+	MessagesPerMinute float64
+	MessageBurst      int
+	QueriesPerMinute  float64
+	QueryBurst        int
+	// DailyTokens and MonthlyTokens limit the model tokens spent on behalf of a user
+	DailyTokens   int64
+	MonthlyTokens int64
+}
+
+// Usage is what a user spent in the current day and month
+type Usage struct {
+	UserID        int64
+	DailyTokens   int64
+	MonthlyTokens int64
+	Queries       int
+}
+
+type userState struct {
+	messages bucket
+	// messagesThrottled is set once the user was told about the message rate limit, until a
+	// message is allowed again
+	messagesThrottled bool
+	queries           bucket
+	day               string
+	month             string
+	usage             Usage
+}
+
+// Store is the part of the repository that keeps the usage, so that the budgets survive a restart
+type Store interface {
+	GetTokenUsages() ([]repo.TokenUsage, error)
+	SetTokenUsage(usage repo.TokenUsage) error
+	DeleteTokenUsage(userID int64) error
+	ClearTokenUsages() error
+}
+
+// Limiter keeps every user within the limits. The usage is saved to the store whenever it
+// changes, the rate limits are kept in memory only.
+type Limiter struct {
+	mu     sync.Mutex
+	limits Limits
+	users  map[int64]*userState
+	store  Store
+	now    func() time.Time
+}
+
+// NewLimiter creates a limiter that continues with the usage kept in the store
+func NewLimiter(limits Limits, store Store) (*Limiter, error) {
+	usages, err := store.GetTokenUsages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load token usage: %w", err)
+	}
+
+	users := make(map[int64]*userState, len(usages))
+	for _, usage := range usages {
+		users[usage.UserID] = &userState{
+			day:   usage.Day,
+			month: usage.Month,
+			usage: Usage{
+				UserID:        usage.UserID,
+				DailyTokens:   usage.DailyTokens,
+				MonthlyTokens: usage.MonthlyTokens,
+				Queries:       usage.Queries,
+			},
+		}
+	}
+
+	return &Limiter{limits: limits, users: users, store: store, now: time.Now}, nil
+}
+
+// Update replaces the limits, e.g. when the config is reloaded. Usage is kept.
+func (l *Limiter) Update(limits Limits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits = limits
+}
+
+// user returns the state of the user, starting the usage over when a new day or month began
+func (l *Limiter) user(userID int64, now time.Time) *userState {
+	state, ok := l.users[userID]
+	if !ok {
+		state = &userState{usage: Usage{UserID: userID}}
+		l.users[userID] = state
+	}
+
+	if day := now.Format(time.DateOnly); state.day != day {
+		state.day = day
+		state.usage.DailyTokens = 0
+		state.usage.Queries = 0
+	}
+	if month := now.Format("2006-01"); state.month != month {
+		state.month = month
+		state.usage.MonthlyTokens = 0
+	}
+
+	return state
+}
+
+// AllowMessage takes a message of the user from the message rate limit. The first message
+// over the limit gets ErrTooManyMessages, the following ones ErrMessageDropped until a message
+// is allowed again.
+func (l *Limiter) AllowMessage(userID int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	state := l.user(userID, now)
+	if wait := state.messages.take(l.limits.MessagesPerMinute, l.limits.MessageBurst, now); wait > 0 {
+		if state.messagesThrottled {
+			return ErrMessageDropped
+		}
+		state.messagesThrottled = true
+		return fmt.Errorf("%w, please try again in %s", ErrTooManyMessages, roundUp(wait))
+	}
+	state.messagesThrottled = false
+	return nil
+}
+
+// AllowQuery checks the token budgets of the user and takes a question from the query rate limit
+func (l *Limiter) AllowQuery(userID int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	state := l.user(userID, now)
+	if err := l.checkBudget(state); err != nil {
+		return err
+	}
+	if wait := state.queries.take(l.limits.QueriesPerMinute, l.limits.QueryBurst, now); wait > 0 {
+		return fmt.Errorf("%w, please try again in %s", ErrTooManyQueries, roundUp(wait))
+	}
+
+	state.usage.Queries++
+	l.save(state)
+	return nil
+}
+
+// CheckBudget reports whether the user has tokens left for other requests to the model
+func (l *Limiter) CheckBudget(userID int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.checkBudget(l.user(userID, l.now()))
+}
+
+func (l *Limiter) checkBudget(state *userState) error {
+	if l.limits.MonthlyTokens > 0 && state.usage.MonthlyTokens >= l.limits.MonthlyTokens {
+		return ErrMonthlyBudget
+	}
+	if l.limits.DailyTokens > 0 && state.usage.DailyTokens >= l.limits.DailyTokens {
+		return ErrDailyBudget
+	}
+	return nil
+}
+
+// RecordTokens adds the tokens of a completion to the usage of the user
+func (l *Limiter) RecordTokens(userID int64, tokens int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state := l.user(userID, l.now())
+	state.usage.DailyTokens += tokens
+	state.usage.MonthlyTokens += tokens
+	l.save(state)
+}
+
+// save stores the usage of the user. A failure is logged rather than failing the request,
+// the usage is still counted in memory.
+func (l *Limiter) save(state *userState) {
+	err := l.store.SetTokenUsage(repo.TokenUsage{
+		UserID:        state.usage.UserID,
+		Day:           state.day,
+		Month:         state.month,
+		DailyTokens:   state.usage.DailyTokens,
+		MonthlyTokens: state.usage.MonthlyTokens,
+		Queries:       state.usage.Queries,
+	})
+	if err != nil {
+		log.Printf("failed to save the token usage of user %d: %v", state.usage.UserID, err)
+	}
+}
+
+// Limits returns the current limits
+func (l *Limiter) Limits() Limits {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limits
+}
+
+// Usage returns the usage of every user who used the bot this month, by user ID
+func (l *Limiter) Usage() []Usage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	result := make([]Usage, 0, len(l.users))
+	for _, userID := range slices.Sorted(maps.Keys(l.users)) {
+		usage := l.user(userID, now).usage
+		if usage.MonthlyTokens > 0 || usage.Queries > 0 {
+			result = append(result, usage)
+		}
+	}
+	return result
+}
+
+// Reset clears the usage and rate limits of the user
+func (l *Limiter) Reset(userID int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.users, userID)
+	return l.store.DeleteTokenUsage(userID)
+}
+
+// ResetAll clears the usage and rate limits of every user
+func (l *Limiter) ResetAll() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	clear(l.users)
+	return l.store.ClearTokenUsages()
+}
+
+func roundUp(wait time.Duration) time.Duration {
+	return wait.Truncate(time.Second) + time.Second
+}
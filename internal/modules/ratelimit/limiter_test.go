@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+)
+
+func newTestStore(t *testing.T) Store {
+	store, err := repo.NewDatabaseRepoMapImpl(filepath.Join(t.TempDir(), "data.json"), repo.MapRepoOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func newTestLimiter(t *testing.T, limits Limits, store Store, now *time.Time) *Limiter {
+	limiter, err := NewLimiter(limits, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter.now = func() time.Time { return *now }
+	return limiter
+}
+
+func TestLimiter_AllowMessage(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	limiter := newTestLimiter(t, Limits{MessagesPerMinute: 6, MessageBurst: 2}, newTestStore(t), &now)
+
+	for i := range 2 {
+		if err := limiter.AllowMessage(1); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+	if err := limiter.AllowMessage(1); !errors.Is(err, ErrTooManyMessages) {
+		t.Fatalf("expected the burst to be used up, got %v", err)
+	}
+	if err := limiter.AllowMessage(1); !errors.Is(err, ErrMessageDropped) {
+		t.Fatalf("expected the user to be told only once, got %v", err)
+	}
+	if err := limiter.AllowMessage(2); err != nil {
+		t.Fatalf("other users are limited separately: %v", err)
+	}
+
+	now = now.Add(10 * time.Second)
+	if err := limiter.AllowMessage(1); err != nil {
+		t.Fatalf("expected a token after 10 seconds: %v", err)
+	}
+	if err := limiter.AllowMessage(1); !errors.Is(err, ErrTooManyMessages) {
+		t.Fatalf("expected the user to be told again after an allowed message, got %v", err)
+	}
+}
+
+func TestLimiter_Budgets(t *testing.T) {
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	limiter := newTestLimiter(t, Limits{DailyTokens: 1000, MonthlyTokens: 1500}, newTestStore(t), &now)
+
+	limiter.RecordTokens(1, 1200)
+	if err := limiter.AllowQuery(1); !errors.Is(err, ErrDailyBudget) {
+		t.Fatalf("expected the daily budget to be used up, got %v", err)
+	}
+
+	// A new day and month renew both budgets
+	now = now.Add(24 * time.Hour)
+	if err := limiter.AllowQuery(1); err != nil {
+		t.Fatalf("expected the budgets to renew: %v", err)
+	}
+
+	limiter.RecordTokens(1, 900)
+	now = now.Add(24 * time.Hour)
+	limiter.RecordTokens(1, 700)
+	if err := limiter.CheckBudget(1); !errors.Is(err, ErrMonthlyBudget) {
+		t.Fatalf("expected the monthly budget to be used up, got %v", err)
+	}
+
+	usage := limiter.Usage()
+	if len(usage) != 1 || usage[0].DailyTokens != 700 || usage[0].MonthlyTokens != 1600 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+
+	if err := limiter.Reset(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := limiter.AllowQuery(1); err != nil {
+		t.Fatalf("expected the reset to clear the usage: %v", err)
+	}
+}
+
+func TestLimiter_UsageSurvivesRestart(t *testing.T) {
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	store := newTestStore(t)
+	limits := Limits{DailyTokens: 1000, MonthlyTokens: 1500}
+
+	limiter := newTestLimiter(t, limits, store, &now)
+	if err := limiter.AllowQuery(1); err != nil {
+		t.Fatal(err)
+	}
+	limiter.RecordTokens(1, 1200)
+
+	restarted := newTestLimiter(t, limits, store, &now)
+	if err := restarted.CheckBudget(1); !errors.Is(err, ErrDailyBudget) {
+		t.Fatalf("expected the daily budget to stay used up after a restart, got %v", err)
+	}
+	usage := restarted.Usage()
+	if len(usage) != 1 || usage[0].Queries != 1 || usage[0].DailyTokens != 1200 || usage[0].MonthlyTokens != 1200 {
+		t.Fatalf("unexpected usage after a restart: %+v", usage)
+	}
+
+	// The saved usage of a past day does not count against the next one
+	now = now.Add(24 * time.Hour)
+	if err := newTestLimiter(t, limits, store, &now).CheckBudget(1); err != nil {
+		t.Fatalf("expected the budgets to renew: %v", err)
+	}
+
+	if err := restarted.ResetAll(); err != nil {
+		t.Fatal(err)
+	}
+	if usage := newTestLimiter(t, limits, store, &now).Usage(); len(usage) != 0 {
+		t.Fatalf("expected the reset to be saved, got %+v", usage)
+	}
+}
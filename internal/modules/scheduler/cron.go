@@ -0,0 +1,196 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed cron expression of five fields: minute, hour, day of month, month and day
+// of week. Fields are *, numbers, ranges such as 1-5, lists such as 1,15 and steps such as
+// */15, months and days of week may be given by their first three letters. Like in cron, a
+// day matches either field if both day fields are restricted. The descriptors @hourly,
+// @daily, @weekly, @monthly and @yearly are shorthands.
+type Cron struct {
+	minutes, hours, days, months, weekdays uint64
+	// daysRestricted and weekdaysRestricted are set if the field is not *
+	daysRestricted, weekdaysRestricted bool
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames   = []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+	weekdayNames = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+)
+
+// cronField describes the values a field may take
+type cronField struct {
+	name     string
+	min, max int
+	// names are the names of the values from min on
+	names []string
+}
+
+var (
+	minuteField  = cronField{name: "minute", min: 0, max: 59}
+	hourField    = cronField{name: "hour", min: 0, max: 23}
+	dayField     = cronField{name: "day of month", min: 1, max: 31}
+	monthField   = cronField{name: "month", min: 1, max: 12, names: monthNames}
+	weekdayField = cronField{name: "day of week", min: 0, max: 7, names: weekdayNames}
+)
+
+// ParseCron parses a cron expression, see Cron
+func ParseCron(expression string) (Cron, error) {
+	expression = strings.TrimSpace(expression)
+	if descriptor, ok := cronDescriptors[strings.ToLower(expression)]; ok {
+		expression = descriptor
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return Cron{}, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expression, len(fields))
+	}
+
+	var cron Cron
+	var err error
+	for i, target := range []struct {
+		field cronField
+		bits  *uint64
+	}{
+		{minuteField, &cron.minutes},
+		{hourField, &cron.hours},
+		{dayField, &cron.days},
+		{monthField, &cron.months},
+		{weekdayField, &cron.weekdays},
+	} {
+		if *target.bits, err = target.field.parse(fields[i]); err != nil {
+			return Cron{}, fmt.Errorf("invalid cron expression %q: %w", expression, err)
+		}
+	}
+
+	// 7 is Sunday too
+	if cron.weekdays&(1<<7) != 0 {
+		cron.weekdays |= 1
+	}
+	cron.daysRestricted = !strings.HasPrefix(fields[2], "*")
+	cron.weekdaysRestricted = !strings.HasPrefix(fields[4], "*")
+
+	return cron, nil
+}
+
+func (f cronField) parse(text string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(text, ",") {
+		rangeText, stepText, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepText)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepText, f.name)
+			}
+		}
+
+		var low, high int
+		switch {
+		case rangeText == "*":
+			low, high = f.min, f.max
+		case strings.Contains(rangeText, "-"):
+			lowText, highText, _ := strings.Cut(rangeText, "-")
+			var err error
+			if low, err = f.value(lowText); err != nil {
+				return 0, err
+			}
+			if high, err = f.value(highText); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q in %s", rangeText, f.name)
+			}
+		default:
+			var err error
+			if low, err = f.value(rangeText); err != nil {
+				return 0, err
+			}
+			high = low
+			// As in cron, 5/15 means from 5 on every 15
+			if hasStep {
+				high = f.max
+			}
+		}
+
+		for value := low; value <= high; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(text string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(text, name) {
+			return f.min + i, nil
+		}
+	}
+
+	value, err := strconv.Atoi(text)
+	if err != nil || value < f.min || value > f.max {
+		return 0, fmt.Errorf("invalid %s %q, expected %d-%d", f.name, text, f.min, f.max)
+	}
+	return value, nil
+}
+
+// maxCronSearch bounds the search for the next run, expressions such as 0 0 30 2 * never match
+const maxCronSearch = 5 * 366 * 24 * time.Hour
+
+// Next returns the first minute after the given time that matches the expression, in the
+// location of the given time, or the zero time if there is none
+func (c Cron) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxCronSearch)
+	location := t.Location()
+
+	for t.Before(limit) {
+		year, month, day := t.Date()
+		hour, minute := t.Hour(), t.Minute()
+		var next time.Time
+		switch {
+		case c.months&(1<<uint(month)) == 0:
+			next = time.Date(year, month+1, 1, 0, 0, 0, 0, location)
+		case !c.dayMatches(t):
+			next = time.Date(year, month, day+1, 0, 0, 0, 0, location)
+		case c.hours&(1<<uint(hour)) == 0:
+			next = time.Date(year, month, day, hour+1, 0, 0, 0, location)
+		case c.minutes&(1<<uint(minute)) == 0:
+			next = time.Date(year, month, day, hour, minute+1, 0, 0, location)
+		default:
+			return t
+		}
+
+		// Around daylight saving time changes a wall clock time may resolve to an earlier instant
+		if !next.After(t) {
+			next = t.Add(time.Minute)
+		}
+		t = next
+	}
+	return time.Time{}
+}
+
+func (c Cron) dayMatches(t time.Time) bool {
+	dayMatches := c.days&(1<<uint(t.Day())) != 0
+	weekdayMatches := c.weekdays&(1<<uint(t.Weekday())) != 0
+	if c.daysRestricted && c.weekdaysRestricted {
+		return dayMatches || weekdayMatches
+	}
+	return dayMatches && weekdayMatches
+}
@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+)
+
+// checkInterval is how often the scheduler looks for due schedules, well within a minute so
+// that runs are not late by much
+const checkInterval = 15 * time.Second
+
+// Store is the part of the repository that keeps the schedules
+type Store interface {
+	GetSchedules() ([]repo.Schedule, error)
+	SetScheduleLastRun(ID int, lastRun time.Time) error
+}
+
+// Runner answers a due schedule and sends the result to its user
+type Runner func(schedule repo.Schedule)
+
+// Scheduler runs the schedules kept in the repository when their cron expression says so, in
+// the local time of the server. A schedule that was due while the bot was down runs once when
+// it is back.
+type Scheduler struct {
+	store Store
+	run   Runner
+	now   func() time.Time
+}
+
+func NewScheduler(store Store, run Runner) *Scheduler {
+	return &Scheduler{store: store, run: run, now: time.Now}
+}
+
+// Run checks for due schedules until the process exits
+func (s *Scheduler) Run() {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.runDue()
+	}
+}
+
+// runDue starts every schedule that is due. The run is recorded before it starts, so that a
+// slow or crashing run is not repeated.
+func (s *Scheduler) runDue() {
+	schedules, err := s.store.GetSchedules()
+	if err != nil {
+		log.Printf("failed to load schedules: %v", err)
+		return
+	}
+
+	now := s.now()
+	for _, schedule := range schedules {
+		if schedule.Paused {
+			continue
+		}
+
+		next, err := NextRun(schedule)
+		if err != nil {
+			log.Printf("schedule %d: %v", schedule.ID, err)
+			continue
+		}
+		if next.IsZero() || next.After(now) {
+			continue
+		}
+
+		if err := s.store.SetScheduleLastRun(schedule.ID, now); err != nil {
+			log.Printf("schedule %d: failed to record run: %v", schedule.ID, err)
+			continue
+		}
+		go s.run(schedule)
+	}
+}
+
+// NextRun returns when the schedule is due next, counting from its last run or resume or,
+// before the first one, from when it was created
+func NextRun(schedule repo.Schedule) (time.Time, error) {
+	cron, err := ParseCron(schedule.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	since := schedule.LastRunAt
+	if since.IsZero() {
+		since = schedule.CreatedAt
+	}
+	return cron.Next(since.Local()), nil
+}
@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+)
+
+func TestCron_Next(t *testing.T) {
+	// Saturday
+	after := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"0 9 * * 1", time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 17, 9, 45, 0, 0, time.UTC)},
+		{"5/20 10 * * *", time.Date(2026, 10, 17, 10, 5, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 8 20 * 6", time.Date(2026, 10, 20, 8, 0, 0, 0, time.UTC)},
+		{"0 8 1,31 * 2", time.Date(2026, 10, 20, 8, 0, 0, 0, time.UTC)},
+	} {
+		cron, err := ParseCron(test.expression)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", test.expression, err)
+		}
+		if next := cron.Next(after); !next.Equal(test.expected) {
+			t.Fatalf("%q: expected %v, got %v", test.expression, test.expected, next)
+		}
+	}
+
+	never, _ := ParseCron("0 0 30 2 *")
+	if next := never.Next(after); !next.IsZero() {
+		t.Fatalf("expected February 30 never to match, got %v", next)
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expression := range []string{"", "0 9 * *", "60 9 * * *", "0 9 * * 8", "0 9-7 * * *", "*/0 * * * *", "0 9 * foo *"} {
+		if _, err := ParseCron(expression); err == nil {
+			t.Fatalf("expected %q to be rejected", expression)
+		}
+	}
+}
+
+type fakeStore struct {
+	schedules []repo.Schedule
+}
+
+func (s *fakeStore) GetSchedules() ([]repo.Schedule, error) {
+	return s.schedules, nil
+}
+
+func (s *fakeStore) SetScheduleLastRun(ID int, lastRun time.Time) error {
+	for i := range s.schedules {
+		if s.schedules[i].ID == ID {
+			s.schedules[i].LastRunAt = lastRun
+		}
+	}
+	return nil
+}
+
+func TestScheduler_RunDue(t *testing.T) {
+	created := time.Date(2026, 10, 17, 8, 0, 0, 0, time.Local)
+	store := &fakeStore{schedules: []repo.Schedule{
+		{ID: 1, Cron: "0 9 * * *", CreatedAt: created},
+		{ID: 2, Cron: "0 9 * * *", CreatedAt: created, Paused: true},
+		{ID: 3, Cron: "0 10 * * *", CreatedAt: created},
+	}}
+
+	ran := make(chan int, 3)
+	scheduler := NewScheduler(store, func(schedule repo.Schedule) { ran <- schedule.ID })
+	scheduler.now = func() time.Time { return created.Add(time.Hour + 10*time.Second) }
+
+	scheduler.runDue()
+	if id := <-ran; id != 1 {
+		t.Fatalf("expected schedule 1 to run, got %d", id)
+	}
+	if store.schedules[0].LastRunAt.IsZero() {
+		t.Fatal("expected the run to be recorded")
+	}
+
+	// The run is not repeated within the same minute
+	scheduler.runDue()
+	select {
+	case id := <-ran:
+		t.Fatalf("expected no run, got schedule %d", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
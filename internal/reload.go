@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/database_handler"
+)
+
+const (
+	configPollInterval = 5 * time.Second
+	// removedConnectionGracePeriod lets queries running on a removed connection finish before it is closed
+	removedConnectionGracePeriod = time.Minute
+)
+
+// watchConfig reloads the config on SIGHUP and, when it is read from a file, whenever the
+// file changes. Updates keep being handled while the config is reloaded.
+func (s *Service) watchConfig() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	lastModTime := s.configModTime()
+	for {
+		select {
+		case <-signals:
+			log.Println("config - reloading on SIGHUP")
+			s.reloadConfig()
+		case <-ticker.C:
+			modTime := s.configModTime()
+			if modTime.Equal(lastModTime) {
+				continue
+			}
+			lastModTime = modTime
+			log.Println("config - reloading changed config file")
+			s.reloadConfig()
+		}
+	}
+}
+
+// configModTime returns the modification time of the config file, or the zero time if the
+// config is not read from a file
+func (s *Service) configModTime() time.Time {
+	path := s.configPath
+	if path == "" {
+		path = os.Getenv("config_file")
+	}
+	if path == "" {
+		return time.Time{}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// reloadConfig applies the differences between the new and the running config.
+// An invalid config is reported and ignored, so the bot keeps running with the previous one.
+func (s *Service) reloadConfig() {
+	newConfig, err := config.LoadConfig(s.configPath)
+	if err != nil {
+		log.Println("config - reload failed, keeping the running config:", err)
+		return
+	}
+	oldConfig := s.config
+
+	newConfig.Databases = s.reloadConnections(oldConfig.Databases, newConfig.Databases)
+
+	roles, users := newConfig.AccessControl()
+	s.authorizer.Update(roles, users)
+	s.dbHandler.SetAccessPolicies(accessPolicies(newConfig))
+	s.masker.Update(newConfig.MaskingRules())
+	s.dbHandler.ForgetCachedResults()
+	s.limiter.Update(newConfig.UsageLimits())
+	log.Printf("config - %d users with access", len(users))
+
+	if oldConfig.AvalAi.ApiKey != newConfig.AvalAi.ApiKey {
+		s.aiModule.SetApiKey(newConfig.AvalAi.ApiKey)
+		log.Println("config - rotated the AvalAI API key")
+	}
+
+	// The bot, the repository and the audit log are created once, keep their running config so that the
+	// warning is repeated until the service is restarted
+	if oldConfig.CliBot != newConfig.CliBot || oldConfig.DebugMode != newConfig.DebugMode || oldConfig.Repo != newConfig.Repo ||
+		oldConfig.Audit != newConfig.Audit {
+		log.Println("config - changes of cliBot, debugMode, repo and audit are applied after a restart")
+		newConfig.CliBot = oldConfig.CliBot
+		newConfig.DebugMode = oldConfig.DebugMode
+		newConfig.Repo = oldConfig.Repo
+		newConfig.Audit = oldConfig.Audit
+	}
+
+	s.config = newConfig
+}
+
+// reloadConnections creates the added and changed connections and removes the deleted ones.
+// Replaced and removed connections are closed after a grace period. It returns the config of
+// the connections now in use: a connection that could not be created keeps its old config,
+// or is left out if it was added, so that the next reload tries to create it again.
+func (s *Service) reloadConnections(oldDatabases []config.Database, newDatabases []config.Database) []config.Database {
+	oldByName := make(map[string]config.Database, len(oldDatabases))
+	for _, database := range oldDatabases {
+		oldByName[database.Name] = database
+	}
+
+	var applied []config.Database
+	newNames := make(map[string]bool, len(newDatabases))
+	for _, database := range newDatabases {
+		newNames[database.Name] = true
+		oldDatabase, existed := oldByName[database.Name]
+		if existed && oldDatabase == database {
+			applied = append(applied, database)
+			continue
+		}
+
+		connection, err := s.newConnection(database)
+		if err != nil {
+			log.Println("config - failed to create connection:", err)
+			if existed {
+				applied = append(applied, oldDatabase)
+			}
+			continue
+		}
+		applied = append(applied, database)
+
+		previous, replaced := s.dbHandler.SetConnection(connection)
+		if replaced {
+			closeLater(previous)
+			log.Printf("config - updated connection %s", database.Name)
+		} else {
+			log.Printf("config - added connection %s", database.Name)
+		}
+	}
+
+	for name := range oldByName {
+		if newNames[name] {
+			continue
+		}
+		if connection, ok := s.dbHandler.RemoveConnection(name); ok {
+			closeLater(connection)
+			log.Printf("config - removed connection %s", name)
+		}
+	}
+
+	return applied
+}
+
+func closeLater(connection database_handler.Connection) {
+	time.AfterFunc(removedConnectionGracePeriod, func() {
+		if err := connection.Database.Close(); err != nil {
+			log.Printf("config - failed to close connection %s: %v", connection.Name, err)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/database_handler"
+)
+
+func TestService_ReloadConnections(t *testing.T) {
+	oldDatabases := []config.Database{
+		{Name: "kept", Driver: config.Postgres, Host: "kept"},
+		{Name: "changed", Driver: config.Postgres, Host: "changed"},
+		{Name: "failing", Driver: config.Postgres, Host: "failing"},
+		{Name: "removed", Driver: config.Postgres, Host: "removed"},
+	}
+	newDatabases := []config.Database{
+		{Name: "kept", Driver: config.Postgres, Host: "kept"},
+		{Name: "changed", Driver: config.MySQL, Host: "changed"},
+		{Name: "failing", Driver: config.MySQL, Host: "unreachable"},
+		{Name: "added", Driver: config.MySQL, Host: "added"},
+		{Name: "added_failing", Driver: config.MySQL, Host: "unreachable"},
+	}
+
+	var oldConnections []database_handler.Connection
+	for _, database := range oldDatabases {
+		oldConnections = append(oldConnections, database_handler.Connection{Name: database.Name, Driver: database.Driver})
+	}
+	var created []string
+	s := &Service{
+		dbHandler: database_handler.NewDatabaseHandler(oldConnections, nil, nil, nil, nil, nil),
+		newConnection: func(database config.Database) (database_handler.Connection, error) {
+			if database.Host == "unreachable" {
+				return database_handler.Connection{}, errors.New("unreachable")
+			}
+			created = append(created, database.Name)
+			return database_handler.Connection{Name: database.Name, Driver: database.Driver}, nil
+		},
+	}
+
+	applied := s.reloadConnections(oldDatabases, newDatabases)
+
+	if !slices.Equal(created, []string{"changed", "added"}) {
+		t.Errorf("expected only the changed and added connections to be created, got %v", created)
+	}
+	expected := []config.Database{newDatabases[0], newDatabases[1], oldDatabases[2], newDatabases[3]}
+	if !slices.Equal(applied, expected) {
+		t.Errorf("expected the config in use\n%+v\ngot\n%+v", expected, applied)
+	}
+
+	for name, driver := range map[string]config.Driver{
+		"kept":    config.Postgres,
+		"changed": config.MySQL,
+		"failing": config.Postgres,
+		"added":   config.MySQL,
+	} {
+		connection, ok := s.dbHandler.RemoveConnection(name)
+		if !ok || connection.Driver != driver {
+			t.Errorf("expected connection %s on %s, got %+v, %v", name, driver, connection, ok)
+		}
+	}
+	for _, name := range []string{"removed", "added_failing"} {
+		if _, ok := s.dbHandler.RemoveConnection(name); ok {
+			t.Errorf("expected connection %s to be gone", name)
+		}
+	}
+}
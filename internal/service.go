@@ -8,51 +8,230 @@ import (
 
 	"github.com/AliTaghipour1/Talk-to_DB/internal/config"
 	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/ai"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/audit"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/authorization"
 	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/bot"
 	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/database_handler"
 	db2 "github.com/AliTaghipour1/Talk-to_DB/internal/modules/db"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/masking"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/ratelimit"
+	"github.com/AliTaghipour1/Talk-to_DB/internal/modules/scheduler"
 	"github.com/AliTaghipour1/Talk-to_DB/pkg/bot_api"
 	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
 	tgbotapi "github.com/ghiac/bale-bot-api"
 )
 
 type Service struct {
-	databases map[config.Driver]db2.Database //maybe it is better to use name instead of driver
+	configPath    string
+	config        *config.TalkToDBConfig
+	aiModule      *ai.AIModule
+	authorizer    *authorization.Authorizer
+	masker        *masking.Masker
+	limiter       *ratelimit.Limiter
+	dbHandler     *database_handler.DatabaseHandler
+	updateHandler *bot.UpdateHandler
+	// newConnection creates the connections added or changed when the config is reloaded
+	newConnection func(db config.Database) (database_handler.Connection, error)
 }
 
-func NewService() *Service {
+// NewService creates the service with the config file at configPath, see config.LoadConfig
+func NewService(configPath string) *Service {
 	return &Service{
-		databases: make(map[config.Driver]db2.Database),
+		configPath:    configPath,
+		newConnection: createConnection,
 	}
 }
 
 func (s *Service) Run() {
-	serviceConfig := config.NewTalkToDbConfig()
-	configJsonText, _ := json.MarshalIndent(serviceConfig, "", "    ")
+	serviceConfig := config.NewTalkToDbConfig(s.configPath)
+	configJsonText, _ := json.MarshalIndent(serviceConfig.Redacted(), "", "    ")
 	log.Println("config:", string(configJsonText))
 
-	s.createDatabases(serviceConfig.Databases)
-	databaseRepo := repo.NewDatabaseRepoMapImpl("pkg/repo/data.json")
-	s.runBot(serviceConfig, databaseRepo)
+	s.config = serviceConfig
+	connections := createConnections(serviceConfig.Databases)
+	databaseRepo := createDatabaseRepo(serviceConfig.Repo)
+	auditLog := createAuditLog(serviceConfig.Audit)
+	s.runBot(serviceConfig, connections, databaseRepo, auditLog)
 }
 
-func (s *Service) createDatabases(dbs []config.Database) {
+// CheckConfig validates the config and connects to every configured database without
+// starting the bot. It reports every problem and returns false if any was found.
+func (s *Service) CheckConfig() bool {
+	serviceConfig, err := config.LoadConfig(s.configPath)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	fmt.Println("config is valid")
+
+	ok := true
+	for _, database := range serviceConfig.Databases {
+		cfg, driver := convertDatabaseConfigModel(database)
+		connection, err := db2.NewDatabase(cfg, driver)
+		if err == nil {
+			err = connection.Connect()
+			connection.Close()
+		}
+
+		if err != nil {
+			ok = false
+			fmt.Printf("database %s: FAILED: %v\n", database.Name, err)
+			continue
+		}
+		fmt.Printf("database %s: ok\n", database.Name)
+	}
+
+	return ok
+}
+
+const (
+	defaultJsonRepoPath    = "pkg/repo/data.json"
+	defaultSqliteRepoPath  = "pkg/repo/data.db"
+	defaultJsonRepoBackups = 3
+
+	defaultJsonlAuditPath  = "audit.jsonl"
+	defaultSqliteAuditPath = "audit.db"
+	defaultAuditMaxSizeMB  = 100
+	defaultAuditMaxFiles   = 10
+)
+
+func createDatabaseRepo(repoConfig config.Repo) repo.DatabaseRepo {
+	switch repoConfig.Type {
+	case "", config.JsonRepo:
+		path := repoConfig.Path
+		if path == "" {
+			path = defaultJsonRepoPath
+		}
+		backups := repoConfig.Backups
+		if backups == 0 {
+			backups = defaultJsonRepoBackups
+		}
+		databaseRepo, err := repo.NewDatabaseRepoMapImpl(path, repo.MapRepoOptions{
+			Backups:           backups,
+			RestoreFromBackup: repoConfig.RestoreFromBackup,
+		})
+		if err != nil {
+			panic(fmt.Errorf("failed to create json repository: %w", err))
+		}
+		return databaseRepo
+	case config.SqliteRepo:
+		path := repoConfig.Path
+		if path == "" {
+			path = defaultSqliteRepoPath
+		}
+		databaseRepo, err := repo.NewDatabaseRepoSqliteImpl(path)
+		if err != nil {
+			panic(fmt.Errorf("failed to create sqlite repository: %w", err))
+		}
+		return databaseRepo
+	default:
+		panic(fmt.Errorf("unknown repository type: %s", repoConfig.Type))
+	}
+}
+
+func createAuditLog(auditConfig config.Audit) audit.Log {
+	switch auditConfig.Type {
+	case "", config.JsonlAudit:
+		path := auditConfig.Path
+		if path == "" {
+			path = defaultJsonlAuditPath
+		}
+		maxSizeMB := auditConfig.MaxSizeMB
+		if maxSizeMB == 0 {
+			maxSizeMB = defaultAuditMaxSizeMB
+		}
+		maxFiles := auditConfig.MaxFiles
+		if maxFiles == 0 {
+			maxFiles = defaultAuditMaxFiles
+		}
+		auditLog, err := audit.NewFileLog(path, int64(maxSizeMB)*1024*1024, maxFiles)
+		if err != nil {
+			panic(fmt.Errorf("failed to create audit log: %w", err))
+		}
+		return auditLog
+	case config.SqliteAudit:
+		path := auditConfig.Path
+		if path == "" {
+			path = defaultSqliteAuditPath
+		}
+		auditLog, err := audit.NewSqliteLog(path)
+		if err != nil {
+			panic(fmt.Errorf("failed to create audit log: %w", err))
+		}
+		return auditLog
+	default:
+		panic(fmt.Errorf("unknown audit log type: %s", auditConfig.Type))
+	}
+}
+
+// createConnections creates the configured connections without connecting to them, so that an
+// unreachable server does not keep the bot from starting. Health checks connect in the background.
+func createConnections(dbs []config.Database) []database_handler.Connection {
+	var connections []database_handler.Connection
 	for _, db := range dbs {
-		cfg, driver := convertDatabaseConfigModel(db)
-		database, err := db2.NewDatabase(cfg, driver)
+		connection, err := createConnection(db)
 		if err != nil {
-			panic(fmt.Errorf("failed to create [%s] database: %v", db.Driver, err))
+			panic(err)
 		}
-		s.databases[db.Driver] = database
+		connections = append(connections, connection)
+	}
+
+	return connections
+}
+
+// createConnection creates the connection to a configured database and starts its health checks
+func createConnection(db config.Database) (database_handler.Connection, error) {
+	cfg, driver := convertDatabaseConfigModel(db)
+	database, err := db2.NewDatabase(cfg, driver)
+	if err != nil {
+		return database_handler.Connection{}, fmt.Errorf("failed to create [%s] database: %v", db.Name, err)
 	}
+	go database.RunHealthChecks()
+
+	return database_handler.Connection{
+		Name:     db.Name,
+		Driver:   db.Driver,
+		Database: database,
+		CostLimit: database_handler.CostLimit{
+			MaxRows: float64(db.MaxEstimatedRows),
+			MaxCost: db.MaxEstimatedCost,
+			Confirm: db.ExpensiveQueries == config.ConfirmExpensiveQueries,
+		},
+		ResultCacheTTL: time.Duration(db.ResultCacheTTLSeconds) * time.Second,
+	}, nil
 }
 
-func (s *Service) runBot(serviceConfig *config.TalkToDBConfig, databaseRepo repo.DatabaseRepo) {
+func (s *Service) runBot(serviceConfig *config.TalkToDBConfig, connections []database_handler.Connection, databaseRepo repo.DatabaseRepo, auditLog audit.Log) {
 	botApi := getBotApi(serviceConfig.CliBot.Token, serviceConfig.DebugMode)
 	sender := bot_api.NewSenderBot(botApi)
 
-	dbHandler := database_handler.NewDatabaseHandler(serviceConfig.AllowedUserIds, s.databases, databaseRepo, ai.NewAIModule(serviceConfig.AvalAi.ApiKey))
-	bot.NewBotUpdateHandler(dbHandler, sender, botApi, serviceConfig).Start()
+	limiter, err := ratelimit.NewLimiter(serviceConfig.UsageLimits(), databaseRepo)
+	if err != nil {
+		panic(err)
+	}
+	s.limiter = limiter
+	s.aiModule = ai.NewAIModule(serviceConfig.AvalAi.ApiKey, s.limiter)
+	s.authorizer = authorization.NewAuthorizer(serviceConfig.AccessControl())
+	s.masker = masking.NewMasker(serviceConfig.MaskingRules())
+	s.dbHandler = database_handler.NewDatabaseHandler(connections, accessPolicies(serviceConfig), s.masker, databaseRepo, s.aiModule, auditLog)
+	s.updateHandler = bot.NewBotUpdateHandler(s.dbHandler, sender, botApi, s.authorizer, s.limiter)
+
+	go s.watchConfig()
+	go scheduler.NewScheduler(databaseRepo, func(schedule repo.Schedule) {
+		s.updateHandler.RunSchedule(schedule.ID)
+	}).Run()
+	s.updateHandler.Start()
+}
+
+// accessPolicies returns the table and column policies of the roles that deny any
+func accessPolicies(serviceConfig *config.TalkToDBConfig) map[string]database_handler.AccessPolicy {
+	policies := make(map[string]database_handler.AccessPolicy)
+	for name, role := range serviceConfig.Roles {
+		if len(role.Deny) > 0 || len(role.RowFilters) > 0 || len(role.Settings) > 0 {
+			policies[name] = database_handler.NewAccessPolicy(role.Deny, role.RowFilters, role.Settings)
+		}
+	}
+	return policies
 }
 
 func getBotApi(token string, debugMode bool) *tgbotapi.BotAPI {
@@ -85,8 +264,18 @@ func convertDatabaseConfigModel(database config.Database) (db2.DatabaseConfig, d
 		Port:     database.Port,
 		User:     database.User,
 		Password: database.Pass,
-		Database: database.Name,
-		SSLMode:  "disable",
+		Database: database.DBName,
 		Schema:   "public",
+		ConnectionOptions: db2.ConnectionOptions{
+			SSLMode:         database.SSLMode,
+			SSLRootCert:     database.SSLRootCert,
+			SSLCert:         database.SSLCert,
+			SSLKey:          database.SSLKey,
+			ConnectTimeout:  time.Duration(database.ConnectTimeoutSeconds) * time.Second,
+			MaxOpenConns:    database.MaxOpenConns,
+			MaxIdleConns:    database.MaxIdleConns,
+			ConnMaxLifetime: time.Duration(database.ConnMaxLifetimeSeconds) * time.Second,
+			ApplicationName: database.ApplicationName,
+		},
 	}, driver
 }
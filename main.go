@@ -1,9 +1,24 @@
 package main
 
 import (
+	"flag"
+	"os"
+
 	"github.com/AliTaghipour1/Talk-to_DB/internal"
 )
 
 func main() {
-	internal.NewService().Run()
+	configPath := flag.String("config", "", "path of a YAML, JSON or TOML config file, the config environment variable is used if empty")
+	checkConfig := flag.Bool("check-config", false, "validate the config and test the database connections without starting the bot")
+	flag.Parse()
+
+	service := internal.NewService(*configPath)
+	if *checkConfig {
+		if !service.CheckConfig() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	service.Run()
 }
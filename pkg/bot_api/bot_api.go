@@ -1,7 +1,10 @@
 package bot_api
 
 import (
+	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"time"
 
 	"github.com/AliTaghipour1/Talk-to_DB/pkg/tracer"
@@ -13,6 +16,7 @@ type BotApi interface {
 	EditMessage(text string, chatID int64, messageID int, replyMarkup tgbotapi.InlineKeyboardMarkup) error
 	SendCallbackAlert(callbackQueryId string, text string) error
 	IsMember(userId int64, channelID int64) (bool, error)
+	DownloadFile(fileID string) ([]byte, error)
 }
 
 type SenderBaleBotImpl struct {
@@ -97,6 +101,35 @@ func (s *SenderBaleBotImpl) SendMessage(message Message) (int, error) {
 	return send.MessageID, nil
 }
 
+func (s *SenderBaleBotImpl) DownloadFile(fileID string) ([]byte, error) {
+	t := time.Now()
+	defer func() {
+		s.tracer.SendEvent(&tracer.Event{
+			Key:   getTracerKey("DownloadFile"),
+			Value: time.Since(t),
+		})
+	}()
+
+	fileURL, err := s.bot.GetFileDirectURL(fileID)
+	if err != nil {
+		log.Println("error getting file url: ", err)
+		return nil, err
+	}
+
+	response, err := s.bot.Client.Get(fileURL)
+	if err != nil {
+		log.Println("error downloading file: ", err)
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download file: status %s", response.Status)
+	}
+
+	return io.ReadAll(response.Body)
+}
+
 func NewSenderBot(bot *tgbotapi.BotAPI) BotApi {
 	return &SenderBaleBotImpl{
 		bot:    bot,
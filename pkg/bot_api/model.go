@@ -13,6 +13,7 @@ const (
 	Photo
 	Video
 	Gif
+	Document
 )
 
 type FileBytes struct {
@@ -97,6 +98,20 @@ func (m *Message) toChattable() tgbotapi.Chattable {
 				},
 				Caption: m.Text,
 			}
+		case Document:
+			return tgbotapi.DocumentConfig{
+				BaseFile: tgbotapi.BaseFile{
+					BaseChat: tgbotapi.BaseChat{
+						ChatID:           m.ChatId,
+						ReplyMarkup:      m.ReplyMarkup,
+						ReplyToMessageID: m.ReplyToMessageId,
+					},
+					FileID:      fileId,
+					File:        fileBytes,
+					UseExisting: fileId != "",
+				},
+				Caption: m.Text,
+			}
 		default:
 			return tgbotapi.DocumentConfig{
 				BaseFile: tgbotapi.BaseFile{
@@ -1,11 +1,15 @@
 package repo
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 )
 
 type Table struct {
@@ -25,8 +29,29 @@ type Column struct {
 type Database struct {
 	ID          int
 	Name        string
+	Driver      string
+	Connection  string // the configured connection the snapshot was taken from
 	Description string
 	Tables      []Table
+	Glossary    []GlossaryTerm
+	Examples    []Example
+}
+
+// GlossaryTerm is a business term defined for a database, e.g. "revenue = amount - fee"
+type GlossaryTerm struct {
+	ID         int
+	Term       string
+	Definition string
+	SQL        string
+}
+
+// Example is a question and the SQL query a user verified as its correct answer
+type Example struct {
+	ID         int
+	Question   string
+	SQL        string
+	VerifiedBy int64
+	CreatedAt  time.Time
 }
 
 func (s Table) GetColumnByName(name string) (Column, bool) {
@@ -47,6 +72,54 @@ func (s Database) GetTableByName(name string) (Table, bool) {
 	return Table{}, false
 }
 
+func (s Database) GetGlossaryTerm(term string) (GlossaryTerm, bool) {
+	for _, glossaryTerm := range s.Glossary {
+		if strings.EqualFold(glossaryTerm.Term, term) {
+			return glossaryTerm, true
+		}
+	}
+	return GlossaryTerm{}, false
+}
+
+// Feedback is a user's judgement of an answer generated for a question
+type Feedback struct {
+	ID         int
+	DatabaseID int
+	UserID     int64
+	Question   string
+	SQL        string
+	Model      string
+	Positive   bool
+	Correction string
+	CreatedAt  time.Time
+}
+
+// Schedule answers a question, or runs a pinned SQL query, on a cron schedule and sends the
+// result to a user
+type Schedule struct {
+	ID         int
+	UserID     int64 // the recipient, whose role the query runs for
+	DatabaseID int
+	Connection string // the connection the database belongs to
+	Cron       string
+	Question   string
+	SQL        string // pinned SQL, run instead of SQL generated for the question if set
+	Paused     bool
+	LastRunAt  time.Time // zero until the first run, and set to the resume time when resumed
+	CreatedAt  time.Time
+}
+
+// TokenUsage is what a user spent on the model in a day and in a month, kept so that the
+// budgets survive a restart
+type TokenUsage struct {
+	UserID        int64
+	Day           string // the day of DailyTokens and Queries, as 2006-01-02
+	Month         string // the month of MonthlyTokens, as 2006-01
+	DailyTokens   int64
+	MonthlyTokens int64
+	Queries       int
+}
+
 type fieldType int8
 
 const (
@@ -60,65 +133,143 @@ type DatabaseRepo interface {
 	GetDatabase(ID int) (Database, error)
 	GetAllDatabases() ([]Database, error)
 	SetDescription(dbID int, desc string, fieldID int, fieldType fieldType) error
+	SetDescriptions(dbID int, changes []DescriptionChange) error
+	SetGlossaryTerm(dbID int, term GlossaryTerm) (int, error)
+	DeleteGlossaryTerm(dbID int, term string) error
+	AddExample(dbID int, example Example) (int, error)
+	AddFeedback(feedback Feedback) (int, error)
+	SetFeedbackCorrection(feedbackID int, correction string) error
+	GetFeedbacks(since time.Time) ([]Feedback, error)
+	AddSchedule(schedule Schedule) (int, error)
+	GetSchedules() ([]Schedule, error)
+	SetSchedulePaused(ID int, paused bool) error
+	SetScheduleLastRun(ID int, lastRun time.Time) error
+	DeleteSchedule(ID int) error
+	GetTokenUsages() ([]TokenUsage, error)
+	SetTokenUsage(usage TokenUsage) error
+	DeleteTokenUsage(userID int64) error
+	ClearTokenUsages() error
+	DeleteDatabase(ID int) error
+	RenameDatabase(ID int, name string) error
+	UpdateTables(ID int, tables []Table) error
+	ListByDriver(driver string) ([]Database, error)
+}
+
+// DescriptionChange is a single description update applied by SetDescriptions
+type DescriptionChange struct {
+	FieldID     int
+	FieldType   fieldType
+	Description string
 }
 
 // persistenceData represents the structure saved to JSON file
 type persistenceData struct {
-	Databases      map[int]*Database `json:"databases"`
-	NextDatabaseID int               `json:"next_database_id"`
-	NextTableID    int               `json:"next_table_id"`
-	NextColumnID   int               `json:"next_column_id"`
+	Version            int               `json:"version"`
+	Databases          map[int]*Database `json:"databases"`
+	NextDatabaseID     int               `json:"next_database_id"`
+	NextTableID        int               `json:"next_table_id"`
+	NextColumnID       int               `json:"next_column_id"`
+	NextGlossaryTermID int               `json:"next_glossary_term_id"`
+	NextExampleID      int               `json:"next_example_id"`
+	Feedbacks          []Feedback        `json:"feedbacks"`
+	NextFeedbackID     int               `json:"next_feedback_id"`
+	Schedules          []Schedule        `json:"schedules"`
+	NextScheduleID     int               `json:"next_schedule_id"`
+	TokenUsages        []TokenUsage      `json:"token_usages"`
 }
 
 type DatabaseRepoMapImpl struct {
-	databaseMap    map[int]*Database
-	nextDatabaseID int
-	nextTableID    int
-	nextColumnID   int
-	filePath       string
-	mu             sync.RWMutex
+	databaseMap        map[int]*Database
+	nextDatabaseID     int
+	nextTableID        int
+	nextColumnID       int
+	nextGlossaryTermID int
+	nextExampleID      int
+	feedbacks          []Feedback
+	nextFeedbackID     int
+	schedules          []Schedule
+	nextScheduleID     int
+	tokenUsages        []TokenUsage
+	filePath           string
+	backups            int
+	mu                 sync.RWMutex
+}
+
+// MapRepoOptions configures the file persistence of DatabaseRepoMapImpl
+type MapRepoOptions struct {
+	// Backups is the number of previous versions of the file kept next to it
+	Backups int
+	// RestoreFromBackup loads the newest readable backup when the file is corrupt, or
+	// missing while backups exist, instead of refusing to start
+	RestoreFromBackup bool
 }
 
 // NewDatabaseRepoMapImpl creates a new repository instance with file persistence
-func NewDatabaseRepoMapImpl(filePath string) DatabaseRepo {
+func NewDatabaseRepoMapImpl(filePath string, options MapRepoOptions) (DatabaseRepo, error) {
 	repo := &DatabaseRepoMapImpl{
-		databaseMap:    make(map[int]*Database),
-		nextDatabaseID: 1,
-		nextTableID:    1,
-		nextColumnID:   1,
-		filePath:       filePath,
+		databaseMap:        make(map[int]*Database),
+		nextDatabaseID:     1,
+		nextTableID:        1,
+		nextColumnID:       1,
+		nextGlossaryTermID: 1,
+		nextExampleID:      1,
+		nextFeedbackID:     1,
+		nextScheduleID:     1,
+		filePath:           filePath,
+		backups:            options.Backups,
 	}
 
 	// Try to load existing data from file
-	if err := repo.loadFromFile(); err != nil {
+	err := repo.loadFromFile(filePath)
+	if err == nil {
+		return repo, nil
+	}
+	if os.IsNotExist(err) && !repo.hasBackup() {
 		// If file doesn't exist, start with empty data (this is OK)
-		if !os.IsNotExist(err) {
-			log.Println("Error loading database:", err)
+		return repo, nil
+	}
+
+	if !options.RestoreFromBackup {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("repository file %s is missing but backups exist, refusing to start with an empty repository (enable restoring from a backup to recover)", filePath)
 		}
+		return nil, fmt.Errorf("repository file %s is unreadable, refusing to start (enable restoring from a backup to recover): %w", filePath, err)
 	}
 
-	return repo
+	log.Println("Error loading database, restoring from backup:", err)
+	if err := repo.restoreFromBackup(); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
 }
 
 // loadFromFile loads repository data from JSON file
-func (r *DatabaseRepoMapImpl) loadFromFile() error {
+func (r *DatabaseRepoMapImpl) loadFromFile(filePath string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	data, err := os.ReadFile(r.filePath)
+	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return err
 	}
 
-	var persistData persistenceData
-	if err := json.Unmarshal(data, &persistData); err != nil {
-		return fmt.Errorf("failed to unmarshal repository data: %w", err)
+	persistData, err := decodePersistenceData(data)
+	if err != nil {
+		return err
 	}
 
 	r.databaseMap = persistData.Databases
 	r.nextDatabaseID = persistData.NextDatabaseID
 	r.nextTableID = persistData.NextTableID
 	r.nextColumnID = persistData.NextColumnID
+	r.nextGlossaryTermID = persistData.NextGlossaryTermID
+	r.nextExampleID = persistData.NextExampleID
+	r.feedbacks = persistData.Feedbacks
+	r.nextFeedbackID = persistData.NextFeedbackID
+	r.schedules = persistData.Schedules
+	r.nextScheduleID = persistData.NextScheduleID
+	r.tokenUsages = persistData.TokenUsages
 
 	// Initialize maps if they're nil (for backward compatibility)
 	if r.databaseMap == nil {
@@ -128,14 +279,67 @@ func (r *DatabaseRepoMapImpl) loadFromFile() error {
 	return nil
 }
 
-// saveToFile saves repository data to JSON file
+// hasBackup reports whether any backup of the repository file exists
+func (r *DatabaseRepoMapImpl) hasBackup() bool {
+	for i := 1; i <= r.backups; i++ {
+		if _, err := os.Stat(backupFilePath(r.filePath, i)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreFromBackup loads the newest readable backup, keeps the unreadable file aside if
+// there is one and writes the restored data back to the repository file
+func (r *DatabaseRepoMapImpl) restoreFromBackup() error {
+	for i := 1; i <= r.backups; i++ {
+		backupPath := backupFilePath(r.filePath, i)
+		if err := r.loadFromFile(backupPath); err != nil {
+			log.Printf("Error loading backup %s: %v", backupPath, err)
+			continue
+		}
+
+		corruptPath := fmt.Sprintf("%s.corrupt-%d", r.filePath, time.Now().Unix())
+		err := os.Rename(r.filePath, corruptPath)
+		if os.IsNotExist(err) {
+			corruptPath = ""
+		} else if err != nil {
+			return fmt.Errorf("failed to move aside corrupt repository file: %w", err)
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if err := r.saveToFile(); err != nil {
+			return fmt.Errorf("failed to save restored repository data: %w", err)
+		}
+
+		if corruptPath == "" {
+			log.Printf("Restored missing repository file from backup %s", backupPath)
+		} else {
+			log.Printf("Restored repository from backup %s, corrupt file moved to %s", backupPath, corruptPath)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("no readable backup of repository file %s found", r.filePath)
+}
+
+// saveToFile saves repository data to JSON file, keeping the previous content as a backup
 // Note: Caller must hold the write lock (mu.Lock())
 func (r *DatabaseRepoMapImpl) saveToFile() error {
 	persistData := persistenceData{
-		Databases:      r.databaseMap,
-		NextDatabaseID: r.nextDatabaseID,
-		NextTableID:    r.nextTableID,
-		NextColumnID:   r.nextColumnID,
+		Version:            persistenceVersion,
+		Databases:          r.databaseMap,
+		NextDatabaseID:     r.nextDatabaseID,
+		NextTableID:        r.nextTableID,
+		NextColumnID:       r.nextColumnID,
+		NextGlossaryTermID: r.nextGlossaryTermID,
+		NextExampleID:      r.nextExampleID,
+		Feedbacks:          r.feedbacks,
+		NextFeedbackID:     r.nextFeedbackID,
+		Schedules:          r.schedules,
+		NextScheduleID:     r.nextScheduleID,
+		TokenUsages:        r.tokenUsages,
 	}
 
 	data, err := json.MarshalIndent(persistData, "", "  ")
@@ -143,7 +347,11 @@ func (r *DatabaseRepoMapImpl) saveToFile() error {
 		return fmt.Errorf("failed to marshal repository data: %w", err)
 	}
 
-	if err := os.WriteFile(r.filePath, data, 0644); err != nil {
+	if err := rotateBackups(r.filePath, r.backups); err != nil {
+		return fmt.Errorf("failed to rotate repository backups: %w", err)
+	}
+
+	if err := writeFileAtomic(r.filePath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write repository data to file: %w", err)
 	}
 
@@ -257,6 +465,10 @@ func (r *DatabaseRepoMapImpl) CreateNewDatabase(database *Database) (int, error)
 		dbCopy.Tables[i].Columns = make([]Column, len(table.Columns))
 		copy(dbCopy.Tables[i].Columns, table.Columns)
 	}
+	dbCopy.Glossary = make([]GlossaryTerm, len(database.Glossary))
+	copy(dbCopy.Glossary, database.Glossary)
+	dbCopy.Examples = make([]Example, len(database.Examples))
+	copy(dbCopy.Examples, database.Examples)
 
 	// Assign IDs if not provided
 	r.assignIDs(&dbCopy)
@@ -289,15 +501,7 @@ func (r *DatabaseRepoMapImpl) GetDatabase(ID int) (Database, error) {
 	}
 
 	// Return a copy to avoid external modifications
-	dbCopy := *db
-	dbCopy.Tables = make([]Table, len(db.Tables))
-	for i, table := range db.Tables {
-		dbCopy.Tables[i] = table
-		dbCopy.Tables[i].Columns = make([]Column, len(table.Columns))
-		copy(dbCopy.Tables[i].Columns, table.Columns)
-	}
-
-	return dbCopy, nil
+	return cloneDatabase(db), nil
 }
 
 func (r *DatabaseRepoMapImpl) GetAllDatabases() ([]Database, error) {
@@ -307,14 +511,7 @@ func (r *DatabaseRepoMapImpl) GetAllDatabases() ([]Database, error) {
 	databases := make([]Database, 0, len(r.databaseMap))
 	for _, db := range r.databaseMap {
 		// Return a copy to avoid external modifications
-		dbCopy := *db
-		dbCopy.Tables = make([]Table, len(db.Tables))
-		for i, table := range db.Tables {
-			dbCopy.Tables[i] = table
-			dbCopy.Tables[i].Columns = make([]Column, len(table.Columns))
-			copy(dbCopy.Tables[i].Columns, table.Columns)
-		}
-		databases = append(databases, dbCopy)
+		databases = append(databases, cloneDatabase(db))
 	}
 
 	return databases, nil
@@ -329,6 +526,529 @@ func (r *DatabaseRepoMapImpl) SetDescription(dbID int, desc string, fieldID int,
 		return fmt.Errorf("database with ID %d not found", dbID)
 	}
 
+	if err := applyDescription(db, desc, fieldID, fieldType); err != nil {
+		return err
+	}
+
+	// Save to file
+	if err := r.saveToFile(); err != nil {
+		return fmt.Errorf("failed to save description: %w", err)
+	}
+
+	return nil
+}
+
+// SetDescriptions applies all changes to a database atomically: either every change
+// is applied and persisted, or none of them is
+func (r *DatabaseRepoMapImpl) SetDescriptions(dbID int, changes []DescriptionChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	db, exists := r.databaseMap[dbID]
+	if !exists {
+		return fmt.Errorf("database with ID %d not found", dbID)
+	}
+
+	// Apply changes on a copy so a failing change leaves the stored database untouched
+	dbCopy := cloneDatabase(db)
+	for _, change := range changes {
+		if err := applyDescription(&dbCopy, change.Description, change.FieldID, change.FieldType); err != nil {
+			return err
+		}
+	}
+
+	r.databaseMap[dbID] = &dbCopy
+
+	// Save to file
+	if err := r.saveToFile(); err != nil {
+		// Rollback on save failure
+		r.databaseMap[dbID] = db
+		return fmt.Errorf("failed to save descriptions: %w", err)
+	}
+
+	return nil
+}
+
+// SetGlossaryTerm adds a term to the glossary of a database, or updates the definition
+// of the term if it already exists. It returns the ID of the term.
+func (r *DatabaseRepoMapImpl) SetGlossaryTerm(dbID int, term GlossaryTerm) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	db, exists := r.databaseMap[dbID]
+	if !exists {
+		return 0, fmt.Errorf("database with ID %d not found", dbID)
+	}
+
+	if strings.TrimSpace(term.Term) == "" {
+		return 0, fmt.Errorf("glossary term must not be empty")
+	}
+
+	dbCopy := cloneDatabase(db)
+	index := slices.IndexFunc(dbCopy.Glossary, func(existing GlossaryTerm) bool {
+		return strings.EqualFold(existing.Term, term.Term)
+	})
+	if index >= 0 {
+		term.ID = dbCopy.Glossary[index].ID
+		dbCopy.Glossary[index] = term
+	} else {
+		term.ID = r.nextGlossaryTermID
+		r.nextGlossaryTermID++
+		dbCopy.Glossary = append(dbCopy.Glossary, term)
+	}
+
+	r.databaseMap[dbID] = &dbCopy
+
+	// Save to file
+	if err := r.saveToFile(); err != nil {
+		// Rollback on save failure
+		r.databaseMap[dbID] = db
+		if index < 0 {
+			r.nextGlossaryTermID--
+		}
+		return 0, fmt.Errorf("failed to save glossary term: %w", err)
+	}
+
+	return term.ID, nil
+}
+
+func (r *DatabaseRepoMapImpl) DeleteGlossaryTerm(dbID int, term string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	db, exists := r.databaseMap[dbID]
+	if !exists {
+		return fmt.Errorf("database with ID %d not found", dbID)
+	}
+
+	dbCopy := cloneDatabase(db)
+	index := slices.IndexFunc(dbCopy.Glossary, func(existing GlossaryTerm) bool {
+		return strings.EqualFold(existing.Term, term)
+	})
+	if index < 0 {
+		return fmt.Errorf("glossary term %q not found in database %d", term, dbID)
+	}
+	dbCopy.Glossary = slices.Delete(dbCopy.Glossary, index, index+1)
+
+	r.databaseMap[dbID] = &dbCopy
+
+	// Save to file
+	if err := r.saveToFile(); err != nil {
+		// Rollback on save failure
+		r.databaseMap[dbID] = db
+		return fmt.Errorf("failed to delete glossary term: %w", err)
+	}
+
+	return nil
+}
+
+// AddExample stores a verified question and SQL pair for a database. A previous example
+// with the same question is replaced by the new one. It returns the ID of the example.
+func (r *DatabaseRepoMapImpl) AddExample(dbID int, example Example) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	db, exists := r.databaseMap[dbID]
+	if !exists {
+		return 0, fmt.Errorf("database with ID %d not found", dbID)
+	}
+
+	if strings.TrimSpace(example.Question) == "" || strings.TrimSpace(example.SQL) == "" {
+		return 0, fmt.Errorf("example question and SQL must not be empty")
+	}
+
+	if example.CreatedAt.IsZero() {
+		example.CreatedAt = time.Now()
+	}
+
+	dbCopy := cloneDatabase(db)
+	index := slices.IndexFunc(dbCopy.Examples, func(existing Example) bool {
+		return strings.EqualFold(strings.TrimSpace(existing.Question), strings.TrimSpace(example.Question))
+	})
+	if index >= 0 {
+		example.ID = dbCopy.Examples[index].ID
+		dbCopy.Examples[index] = example
+	} else {
+		example.ID = r.nextExampleID
+		r.nextExampleID++
+		dbCopy.Examples = append(dbCopy.Examples, example)
+	}
+
+	r.databaseMap[dbID] = &dbCopy
+
+	// Save to file
+	if err := r.saveToFile(); err != nil {
+		// Rollback on save failure
+		r.databaseMap[dbID] = db
+		if index < 0 {
+			r.nextExampleID--
+		}
+		return 0, fmt.Errorf("failed to save example: %w", err)
+	}
+
+	return example.ID, nil
+}
+
+// AddFeedback stores the feedback and returns its ID
+func (r *DatabaseRepoMapImpl) AddFeedback(feedback Feedback) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.databaseMap[feedback.DatabaseID]; !exists {
+		return 0, fmt.Errorf("database with ID %d not found", feedback.DatabaseID)
+	}
+
+	if feedback.CreatedAt.IsZero() {
+		feedback.CreatedAt = time.Now()
+	}
+	feedback.ID = r.nextFeedbackID
+	r.nextFeedbackID++
+	r.feedbacks = append(r.feedbacks, feedback)
+
+	// Save to file
+	if err := r.saveToFile(); err != nil {
+		// Rollback on save failure
+		r.feedbacks = r.feedbacks[:len(r.feedbacks)-1]
+		r.nextFeedbackID--
+		return 0, fmt.Errorf("failed to save feedback: %w", err)
+	}
+
+	return feedback.ID, nil
+}
+
+func (r *DatabaseRepoMapImpl) SetFeedbackCorrection(feedbackID int, correction string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	index := slices.IndexFunc(r.feedbacks, func(feedback Feedback) bool {
+		return feedback.ID == feedbackID
+	})
+	if index < 0 {
+		return fmt.Errorf("feedback with ID %d not found", feedbackID)
+	}
+
+	previous := r.feedbacks[index].Correction
+	r.feedbacks[index].Correction = correction
+
+	// Save to file
+	if err := r.saveToFile(); err != nil {
+		// Rollback on save failure
+		r.feedbacks[index].Correction = previous
+		return fmt.Errorf("failed to save feedback correction: %w", err)
+	}
+
+	return nil
+}
+
+// GetFeedbacks returns the feedbacks created at or after since
+func (r *DatabaseRepoMapImpl) GetFeedbacks(since time.Time) ([]Feedback, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []Feedback
+	for _, feedback := range r.feedbacks {
+		if !feedback.CreatedAt.Before(since) {
+			result = append(result, feedback)
+		}
+	}
+
+	return result, nil
+}
+
+// AddSchedule stores the schedule and returns its ID
+func (r *DatabaseRepoMapImpl) AddSchedule(schedule Schedule) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.databaseMap[schedule.DatabaseID]; !exists {
+		return 0, fmt.Errorf("database with ID %d not found", schedule.DatabaseID)
+	}
+
+	if schedule.CreatedAt.IsZero() {
+		schedule.CreatedAt = time.Now()
+	}
+	schedule.ID = r.nextScheduleID
+	r.nextScheduleID++
+	r.schedules = append(r.schedules, schedule)
+
+	// Save to file
+	if err := r.saveToFile(); err != nil {
+		// Rollback on save failure
+		r.schedules = r.schedules[:len(r.schedules)-1]
+		r.nextScheduleID--
+		return 0, fmt.Errorf("failed to save schedule: %w", err)
+	}
+
+	return schedule.ID, nil
+}
+
+// GetSchedules returns every schedule in the order they were added
+func (r *DatabaseRepoMapImpl) GetSchedules() ([]Schedule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return slices.Clone(r.schedules), nil
+}
+
+// SetSchedulePaused pauses or resumes the schedule. Resuming counts the next run from now,
+// so that the runs missed while paused do not fire at once.
+func (r *DatabaseRepoMapImpl) SetSchedulePaused(ID int, paused bool) error {
+	return r.updateSchedule(ID, func(schedule *Schedule) {
+		schedule.Paused = paused
+		if !paused {
+			schedule.LastRunAt = time.Now()
+		}
+	})
+}
+
+func (r *DatabaseRepoMapImpl) SetScheduleLastRun(ID int, lastRun time.Time) error {
+	return r.updateSchedule(ID, func(schedule *Schedule) { schedule.LastRunAt = lastRun })
+}
+
+func (r *DatabaseRepoMapImpl) updateSchedule(ID int, update func(schedule *Schedule)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	index := slices.IndexFunc(r.schedules, func(schedule Schedule) bool {
+		return schedule.ID == ID
+	})
+	if index < 0 {
+		return fmt.Errorf("schedule with ID %d not found", ID)
+	}
+
+	previous := r.schedules[index]
+	update(&r.schedules[index])
+
+	// Save to file
+	if err := r.saveToFile(); err != nil {
+		// Rollback on save failure
+		r.schedules[index] = previous
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DatabaseRepoMapImpl) DeleteSchedule(ID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	index := slices.IndexFunc(r.schedules, func(schedule Schedule) bool {
+		return schedule.ID == ID
+	})
+	if index < 0 {
+		return fmt.Errorf("schedule with ID %d not found", ID)
+	}
+
+	schedules := r.schedules
+	r.schedules = slices.Delete(slices.Clone(schedules), index, index+1)
+
+	// Save to file
+	if err := r.saveToFile(); err != nil {
+		// Rollback on save failure
+		r.schedules = schedules
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+
+	return nil
+}
+
+// GetTokenUsages returns the usage of every user, by user ID
+func (r *DatabaseRepoMapImpl) GetTokenUsages() ([]TokenUsage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := slices.Clone(r.tokenUsages)
+	slices.SortFunc(result, func(a, b TokenUsage) int { return cmp.Compare(a.UserID, b.UserID) })
+	return result, nil
+}
+
+// SetTokenUsage stores the usage of the user, replacing the previous one
+func (r *DatabaseRepoMapImpl) SetTokenUsage(usage TokenUsage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tokenUsages := r.tokenUsages
+	r.tokenUsages = slices.Clone(tokenUsages)
+	index := slices.IndexFunc(r.tokenUsages, func(stored TokenUsage) bool {
+		return stored.UserID == usage.UserID
+	})
+	if index < 0 {
+		r.tokenUsages = append(r.tokenUsages, usage)
+	} else {
+		r.tokenUsages[index] = usage
+	}
+
+	// Save to file
+	if err := r.saveToFile(); err != nil {
+		// Rollback on save failure
+		r.tokenUsages = tokenUsages
+		return fmt.Errorf("failed to save token usage: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTokenUsage forgets the usage of the user
+func (r *DatabaseRepoMapImpl) DeleteTokenUsage(userID int64) error {
+	return r.deleteTokenUsages(func(usage TokenUsage) bool { return usage.UserID == userID })
+}
+
+// ClearTokenUsages forgets the usage of every user
+func (r *DatabaseRepoMapImpl) ClearTokenUsages() error {
+	return r.deleteTokenUsages(func(TokenUsage) bool { return true })
+}
+
+func (r *DatabaseRepoMapImpl) deleteTokenUsages(match func(usage TokenUsage) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tokenUsages := r.tokenUsages
+	r.tokenUsages = slices.DeleteFunc(slices.Clone(tokenUsages), match)
+
+	// Save to file
+	if err := r.saveToFile(); err != nil {
+		// Rollback on save failure
+		r.tokenUsages = tokenUsages
+		return fmt.Errorf("failed to delete token usage: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DatabaseRepoMapImpl) DeleteDatabase(ID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	db, exists := r.databaseMap[ID]
+	if !exists {
+		return fmt.Errorf("database with ID %d not found", ID)
+	}
+
+	delete(r.databaseMap, ID)
+	// Schedules of the database have nothing left to run on
+	schedules := r.schedules
+	r.schedules = slices.DeleteFunc(slices.Clone(schedules), func(schedule Schedule) bool {
+		return schedule.DatabaseID == ID
+	})
+
+	// Save to file
+	if err := r.saveToFile(); err != nil {
+		// Rollback on save failure
+		r.databaseMap[ID] = db
+		r.schedules = schedules
+		return fmt.Errorf("failed to delete database: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DatabaseRepoMapImpl) RenameDatabase(ID int, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	db, exists := r.databaseMap[ID]
+	if !exists {
+		return fmt.Errorf("database with ID %d not found", ID)
+	}
+
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("database name must not be empty")
+	}
+
+	dbCopy := cloneDatabase(db)
+	dbCopy.Name = name
+	r.databaseMap[ID] = &dbCopy
+
+	// Save to file
+	if err := r.saveToFile(); err != nil {
+		// Rollback on save failure
+		r.databaseMap[ID] = db
+		return fmt.Errorf("failed to rename database: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateTables replaces the tables of a database with a fresh snapshot. Tables and
+// columns that still exist keep their IDs and descriptions, new ones get new IDs and
+// the ones missing from the snapshot are removed.
+func (r *DatabaseRepoMapImpl) UpdateTables(ID int, tables []Table) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	db, exists := r.databaseMap[ID]
+	if !exists {
+		return fmt.Errorf("database with ID %d not found", ID)
+	}
+
+	nextTableID, nextColumnID := r.nextTableID, r.nextColumnID
+
+	dbCopy := cloneDatabase(db)
+	dbCopy.Tables = mergeTables(db.Tables, tables)
+	r.assignIDs(&dbCopy)
+	r.databaseMap[ID] = &dbCopy
+
+	// Save to file
+	if err := r.saveToFile(); err != nil {
+		// Rollback on save failure
+		r.databaseMap[ID] = db
+		r.nextTableID, r.nextColumnID = nextTableID, nextColumnID
+		return fmt.Errorf("failed to update tables: %w", err)
+	}
+
+	return nil
+}
+
+// ListByDriver returns the databases whose snapshot was taken from the given driver
+func (r *DatabaseRepoMapImpl) ListByDriver(driver string) ([]Database, error) {
+	databases, err := r.GetAllDatabases()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Database
+	for _, database := range databases {
+		if database.Driver == driver {
+			result = append(result, database)
+		}
+	}
+
+	return result, nil
+}
+
+// mergeTables returns the new tables carrying the IDs and descriptions of the existing
+// tables and columns with the same names. IDs of new tables and columns are left zero.
+func mergeTables(existing []Table, tables []Table) []Table {
+	existingTables := Database{Tables: existing}
+
+	result := make([]Table, len(tables))
+	for i, table := range tables {
+		result[i] = Table{Name: table.Name, Description: table.Description}
+
+		existingTable, found := existingTables.GetTableByName(table.Name)
+		if found {
+			result[i].ID = existingTable.ID
+			result[i].Description = existingTable.Description
+		}
+
+		result[i].Columns = make([]Column, len(table.Columns))
+		for j, column := range table.Columns {
+			result[i].Columns[j] = Column{Name: column.Name, DataType: column.DataType, Description: column.Description}
+
+			existingColumn, columnFound := existingTable.GetColumnByName(column.Name)
+			if found && columnFound {
+				result[i].Columns[j].ID = existingColumn.ID
+				result[i].Columns[j].Description = existingColumn.Description
+			}
+		}
+	}
+
+	return result
+}
+
+// applyDescription sets the description of a single field of the given database
+func applyDescription(db *Database, desc string, fieldID int, fieldType fieldType) error {
 	switch fieldType {
 	case DatabaseFieldType:
 		if db.ID != fieldID {
@@ -346,7 +1066,7 @@ func (r *DatabaseRepoMapImpl) SetDescription(dbID int, desc string, fieldID int,
 			}
 		}
 		if !found {
-			return fmt.Errorf("table with ID %d not found in database %d", fieldID, dbID)
+			return fmt.Errorf("table with ID %d not found in database %d", fieldID, db.ID)
 		}
 
 	case ColumnFieldType:
@@ -364,17 +1084,28 @@ func (r *DatabaseRepoMapImpl) SetDescription(dbID int, desc string, fieldID int,
 			}
 		}
 		if !found {
-			return fmt.Errorf("column with ID %d not found in database %d", fieldID, dbID)
+			return fmt.Errorf("column with ID %d not found in database %d", fieldID, db.ID)
 		}
 
 	default:
 		return fmt.Errorf("unknown field type: %d", fieldType)
 	}
 
-	// Save to file
-	if err := r.saveToFile(); err != nil {
-		return fmt.Errorf("failed to save description: %w", err)
-	}
-
 	return nil
 }
+
+// cloneDatabase returns a deep copy of the given database
+func cloneDatabase(db *Database) Database {
+	dbCopy := *db
+	dbCopy.Tables = make([]Table, len(db.Tables))
+	for i, table := range db.Tables {
+		dbCopy.Tables[i] = table
+		dbCopy.Tables[i].Columns = make([]Column, len(table.Columns))
+		copy(dbCopy.Tables[i].Columns, table.Columns)
+	}
+	dbCopy.Glossary = make([]GlossaryTerm, len(db.Glossary))
+	copy(dbCopy.Glossary, db.Glossary)
+	dbCopy.Examples = make([]Example, len(db.Examples))
+	copy(dbCopy.Examples, db.Examples)
+	return dbCopy
+}
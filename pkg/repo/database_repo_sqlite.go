@@ -0,0 +1,1023 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// DatabaseRepoSqliteImpl is a DatabaseRepo backed by an embedded SQLite database
+type DatabaseRepoSqliteImpl struct {
+	db *sql.DB
+}
+
+// NewDatabaseRepoSqliteImpl opens (or creates) the SQLite database at filePath and
+// applies the pending schema migrations
+func NewDatabaseRepoSqliteImpl(filePath string) (*DatabaseRepoSqliteImpl, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)", filePath)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository database: %w", err)
+	}
+
+	// SQLite allows a single writer, serializing connections avoids busy errors
+	db.SetMaxOpenConns(1)
+
+	if err := migrateSqlite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &DatabaseRepoSqliteImpl{db: db}, nil
+}
+
+// Close closes the underlying SQLite database
+func (r *DatabaseRepoSqliteImpl) Close() error {
+	return r.db.Close()
+}
+
+// inTx runs fn in a transaction which is committed only if fn succeeds
+func (r *DatabaseRepoSqliteImpl) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *DatabaseRepoSqliteImpl) CreateNewDatabase(database *Database) (int, error) {
+	var databaseID int
+	err := r.inTx(func(tx *sql.Tx) error {
+		var err error
+		databaseID, err = insertDatabase(tx, database)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return databaseID, nil
+}
+
+// insertDatabase inserts the database with all of its tables, columns, descriptions,
+// glossary and examples. Non-zero IDs are kept, zero IDs are assigned by SQLite.
+func insertDatabase(tx *sql.Tx, database *Database) (int, error) {
+	databaseID, err := insertWithOptionalID(tx, database.ID,
+		`INSERT INTO databases (name, driver, connection) VALUES (?, ?, ?)`,
+		`INSERT INTO databases (id, name, driver, connection) VALUES (?, ?, ?, ?)`,
+		database.Name, database.Driver, database.Connection)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert database: %w", err)
+	}
+
+	if err := upsertDescription(tx, databaseID, DatabaseFieldType, databaseID, database.Description); err != nil {
+		return 0, err
+	}
+
+	for i, table := range database.Tables {
+		if err := insertTable(tx, databaseID, table, i); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, term := range database.Glossary {
+		_, err := insertWithOptionalID(tx, term.ID,
+			`INSERT INTO glossary_terms (database_id, term, definition, sql) VALUES (?, ?, ?, ?)`,
+			`INSERT INTO glossary_terms (id, database_id, term, definition, sql) VALUES (?, ?, ?, ?, ?)`,
+			databaseID, term.Term, term.Definition, term.SQL)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert glossary term %s: %w", term.Term, err)
+		}
+	}
+
+	for _, example := range database.Examples {
+		_, err := insertWithOptionalID(tx, example.ID,
+			`INSERT INTO examples (database_id, question, sql, verified_by, created_at) VALUES (?, ?, ?, ?, ?)`,
+			`INSERT INTO examples (id, database_id, question, sql, verified_by, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			databaseID, example.Question, example.SQL, example.VerifiedBy, example.CreatedAt.UnixMicro())
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert example: %w", err)
+		}
+	}
+
+	return databaseID, nil
+}
+
+// insertTable inserts a table of a database with its columns and their descriptions
+func insertTable(tx *sql.Tx, databaseID int, table Table, position int) error {
+	tableID, err := insertWithOptionalID(tx, table.ID,
+		`INSERT INTO tables (database_id, name, position) VALUES (?, ?, ?)`,
+		`INSERT INTO tables (id, database_id, name, position) VALUES (?, ?, ?, ?)`,
+		databaseID, table.Name, position)
+	if err != nil {
+		return fmt.Errorf("failed to insert table %s: %w", table.Name, err)
+	}
+
+	if err := upsertDescription(tx, databaseID, TableFieldType, tableID, table.Description); err != nil {
+		return err
+	}
+
+	for j, column := range table.Columns {
+		if err := insertColumn(tx, databaseID, tableID, column, j); err != nil {
+			return fmt.Errorf("failed to insert column %s of table %s: %w", column.Name, table.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func insertColumn(tx *sql.Tx, databaseID int, tableID int, column Column, position int) error {
+	columnID, err := insertWithOptionalID(tx, column.ID,
+		`INSERT INTO columns (table_id, name, data_type, position) VALUES (?, ?, ?, ?)`,
+		`INSERT INTO columns (id, table_id, name, data_type, position) VALUES (?, ?, ?, ?, ?)`,
+		tableID, column.Name, column.DataType, position)
+	if err != nil {
+		return err
+	}
+
+	return upsertDescription(tx, databaseID, ColumnFieldType, columnID, column.Description)
+}
+
+// insertWithOptionalID runs insertWithID with the ID prepended to args when ID is set,
+// otherwise it runs insert and returns the ID assigned by SQLite
+func insertWithOptionalID(q querier, ID int, insert string, insertWithID string, args ...any) (int, error) {
+	if ID != 0 {
+		_, err := q.Exec(insertWithID, append([]any{ID}, args...)...)
+		return ID, err
+	}
+
+	return insertReturningID(q, insert, args...)
+}
+
+// insertReturningID runs the insert and returns the ID assigned by SQLite
+func insertReturningID(q querier, insert string, args ...any) (int, error) {
+	result, err := q.Exec(insert, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	lastID, err := result.LastInsertId()
+	return int(lastID), err
+}
+
+func (r *DatabaseRepoSqliteImpl) GetDatabase(ID int) (Database, error) {
+	return loadDatabase(r.db, ID)
+}
+
+func (r *DatabaseRepoSqliteImpl) GetAllDatabases() ([]Database, error) {
+	return r.listDatabases(`SELECT id FROM databases ORDER BY id`)
+}
+
+// ListByDriver returns the databases whose snapshot was taken from the given driver
+func (r *DatabaseRepoSqliteImpl) ListByDriver(driver string) ([]Database, error) {
+	return r.listDatabases(`SELECT id FROM databases WHERE driver = ? ORDER BY id`, driver)
+}
+
+// listDatabases loads the databases whose IDs are selected by the query
+func (r *DatabaseRepoSqliteImpl) listDatabases(query string, args ...any) ([]Database, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query databases: %w", err)
+	}
+
+	var IDs []int
+	for rows.Next() {
+		var ID int
+		if err := rows.Scan(&ID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan database ID: %w", err)
+		}
+		IDs = append(IDs, ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating database rows: %w", err)
+	}
+
+	databases := make([]Database, 0, len(IDs))
+	for _, ID := range IDs {
+		database, err := loadDatabase(r.db, ID)
+		if err != nil {
+			return nil, err
+		}
+		databases = append(databases, database)
+	}
+
+	return databases, nil
+}
+
+// loadDatabase reads a database with all of its tables, columns, descriptions, glossary and examples
+func loadDatabase(q querier, ID int) (Database, error) {
+	database := Database{ID: ID}
+	err := q.QueryRow(`SELECT name, driver, connection FROM databases WHERE id = ?`, ID).
+		Scan(&database.Name, &database.Driver, &database.Connection)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Database{}, fmt.Errorf("database with ID %d not found", ID)
+	}
+	if err != nil {
+		return Database{}, fmt.Errorf("failed to query database: %w", err)
+	}
+
+	descriptions, err := loadDescriptions(q, ID)
+	if err != nil {
+		return Database{}, err
+	}
+	database.Description = descriptions[descriptionKey{DatabaseFieldType, ID}]
+
+	tables, err := loadTables(q, ID, descriptions)
+	if err != nil {
+		return Database{}, err
+	}
+	database.Tables = tables
+
+	glossary, err := loadGlossary(q, ID)
+	if err != nil {
+		return Database{}, err
+	}
+	database.Glossary = glossary
+
+	examples, err := loadExamples(q, ID)
+	if err != nil {
+		return Database{}, err
+	}
+	database.Examples = examples
+
+	return database, nil
+}
+
+type descriptionKey struct {
+	fieldType fieldType
+	fieldID   int
+}
+
+func loadDescriptions(q querier, databaseID int) (map[descriptionKey]string, error) {
+	rows, err := q.Query(`SELECT field_type, field_id, description FROM descriptions WHERE database_id = ?`, databaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query descriptions: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[descriptionKey]string)
+	for rows.Next() {
+		var key descriptionKey
+		var description string
+		if err := rows.Scan(&key.fieldType, &key.fieldID, &description); err != nil {
+			return nil, fmt.Errorf("failed to scan description: %w", err)
+		}
+		result[key] = description
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating description rows: %w", err)
+	}
+
+	return result, nil
+}
+
+func loadTables(q querier, databaseID int, descriptions map[descriptionKey]string) ([]Table, error) {
+	rows, err := q.Query(`
+		SELECT t.id, t.name, c.id, c.name, c.data_type
+		FROM tables t
+		LEFT JOIN columns c ON c.table_id = t.id
+		WHERE t.database_id = ?
+		ORDER BY t.position, c.position
+	`, databaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []Table
+	for rows.Next() {
+		var table Table
+		var columnID sql.NullInt64
+		var columnName, dataType sql.NullString
+		if err := rows.Scan(&table.ID, &table.Name, &columnID, &columnName, &dataType); err != nil {
+			return nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+
+		if len(tables) == 0 || tables[len(tables)-1].ID != table.ID {
+			table.Description = descriptions[descriptionKey{TableFieldType, table.ID}]
+			tables = append(tables, table)
+		}
+
+		if columnID.Valid {
+			current := &tables[len(tables)-1]
+			current.Columns = append(current.Columns, Column{
+				ID:          int(columnID.Int64),
+				Name:        columnName.String,
+				DataType:    dataType.String,
+				Description: descriptions[descriptionKey{ColumnFieldType, int(columnID.Int64)}],
+			})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating table rows: %w", err)
+	}
+
+	return tables, nil
+}
+
+func loadGlossary(q querier, databaseID int) ([]GlossaryTerm, error) {
+	rows, err := q.Query(`SELECT id, term, definition, sql FROM glossary_terms WHERE database_id = ? ORDER BY id`, databaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query glossary: %w", err)
+	}
+	defer rows.Close()
+
+	var result []GlossaryTerm
+	for rows.Next() {
+		var term GlossaryTerm
+		if err := rows.Scan(&term.ID, &term.Term, &term.Definition, &term.SQL); err != nil {
+			return nil, fmt.Errorf("failed to scan glossary term: %w", err)
+		}
+		result = append(result, term)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating glossary rows: %w", err)
+	}
+
+	return result, nil
+}
+
+func loadExamples(q querier, databaseID int) ([]Example, error) {
+	rows, err := q.Query(`SELECT id, question, sql, verified_by, created_at FROM examples WHERE database_id = ? ORDER BY id`, databaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query examples: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Example
+	for rows.Next() {
+		var example Example
+		var createdAt int64
+		if err := rows.Scan(&example.ID, &example.Question, &example.SQL, &example.VerifiedBy, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan example: %w", err)
+		}
+		example.CreatedAt = time.UnixMicro(createdAt)
+		result = append(result, example)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating example rows: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *DatabaseRepoSqliteImpl) SetDescription(dbID int, desc string, fieldID int, fieldType fieldType) error {
+	return r.SetDescriptions(dbID, []DescriptionChange{{
+		FieldID:     fieldID,
+		FieldType:   fieldType,
+		Description: desc,
+	}})
+}
+
+// SetDescriptions applies all changes to a database in a single transaction
+func (r *DatabaseRepoSqliteImpl) SetDescriptions(dbID int, changes []DescriptionChange) error {
+	return r.inTx(func(tx *sql.Tx) error {
+		if err := checkDatabaseExists(tx, dbID); err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			if err := checkFieldBelongsToDatabase(tx, dbID, change.FieldID, change.FieldType); err != nil {
+				return err
+			}
+
+			if err := upsertDescription(tx, dbID, change.FieldType, change.FieldID, change.Description); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func checkDatabaseExists(q querier, dbID int) error {
+	var exists bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM databases WHERE id = ?)`, dbID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to query database: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("database with ID %d not found", dbID)
+	}
+	return nil
+}
+
+func checkFieldBelongsToDatabase(q querier, dbID int, fieldID int, fieldType fieldType) error {
+	var query string
+	switch fieldType {
+	case DatabaseFieldType:
+		if dbID != fieldID {
+			return fmt.Errorf("database ID mismatch: expected %d, got %d", dbID, fieldID)
+		}
+		return nil
+	case TableFieldType:
+		query = `SELECT EXISTS (SELECT 1 FROM tables WHERE id = ? AND database_id = ?)`
+	case ColumnFieldType:
+		query = `SELECT EXISTS (SELECT 1 FROM columns c JOIN tables t ON t.id = c.table_id WHERE c.id = ? AND t.database_id = ?)`
+	default:
+		return fmt.Errorf("unknown field type: %d", fieldType)
+	}
+
+	var exists bool
+	if err := q.QueryRow(query, fieldID, dbID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to query field: %w", err)
+	}
+	if !exists {
+		if fieldType == TableFieldType {
+			return fmt.Errorf("table with ID %d not found in database %d", fieldID, dbID)
+		}
+		return fmt.Errorf("column with ID %d not found in database %d", fieldID, dbID)
+	}
+
+	return nil
+}
+
+// upsertDescription stores a description; empty descriptions are removed
+func upsertDescription(q querier, dbID int, fieldType fieldType, fieldID int, description string) error {
+	var err error
+	if description == "" {
+		_, err = q.Exec(`DELETE FROM descriptions WHERE field_type = ? AND field_id = ?`, fieldType, fieldID)
+	} else {
+		_, err = q.Exec(`
+			INSERT INTO descriptions (database_id, field_type, field_id, description) VALUES (?, ?, ?, ?)
+			ON CONFLICT (field_type, field_id) DO UPDATE SET description = excluded.description
+		`, dbID, fieldType, fieldID, description)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save description: %w", err)
+	}
+	return nil
+}
+
+// SetGlossaryTerm adds a term to the glossary of a database, or updates the definition
+// of the term if it already exists. It returns the ID of the term.
+func (r *DatabaseRepoSqliteImpl) SetGlossaryTerm(dbID int, term GlossaryTerm) (int, error) {
+	if strings.TrimSpace(term.Term) == "" {
+		return 0, fmt.Errorf("glossary term must not be empty")
+	}
+
+	var termID int
+	err := r.inTx(func(tx *sql.Tx) error {
+		if err := checkDatabaseExists(tx, dbID); err != nil {
+			return err
+		}
+
+		glossary, err := loadGlossary(tx, dbID)
+		if err != nil {
+			return err
+		}
+
+		existing, found := Database{Glossary: glossary}.GetGlossaryTerm(term.Term)
+		if found {
+			termID = existing.ID
+			_, err = tx.Exec(`UPDATE glossary_terms SET term = ?, definition = ?, sql = ? WHERE id = ?`,
+				term.Term, term.Definition, term.SQL, termID)
+		} else {
+			termID, err = insertReturningID(tx,
+				`INSERT INTO glossary_terms (database_id, term, definition, sql) VALUES (?, ?, ?, ?)`,
+				dbID, term.Term, term.Definition, term.SQL)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save glossary term: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return termID, nil
+}
+
+func (r *DatabaseRepoSqliteImpl) DeleteGlossaryTerm(dbID int, term string) error {
+	return r.inTx(func(tx *sql.Tx) error {
+		if err := checkDatabaseExists(tx, dbID); err != nil {
+			return err
+		}
+
+		glossary, err := loadGlossary(tx, dbID)
+		if err != nil {
+			return err
+		}
+
+		existing, found := Database{Glossary: glossary}.GetGlossaryTerm(term)
+		if !found {
+			return fmt.Errorf("glossary term %q not found in database %d", term, dbID)
+		}
+
+		if _, err := tx.Exec(`DELETE FROM glossary_terms WHERE id = ?`, existing.ID); err != nil {
+			return fmt.Errorf("failed to delete glossary term: %w", err)
+		}
+		return nil
+	})
+}
+
+// AddExample stores a verified question and SQL pair for a database. A previous example
+// with the same question is replaced by the new one. It returns the ID of the example.
+func (r *DatabaseRepoSqliteImpl) AddExample(dbID int, example Example) (int, error) {
+	if strings.TrimSpace(example.Question) == "" || strings.TrimSpace(example.SQL) == "" {
+		return 0, fmt.Errorf("example question and SQL must not be empty")
+	}
+
+	if example.CreatedAt.IsZero() {
+		example.CreatedAt = time.Now()
+	}
+
+	var exampleID int
+	err := r.inTx(func(tx *sql.Tx) error {
+		if err := checkDatabaseExists(tx, dbID); err != nil {
+			return err
+		}
+
+		examples, err := loadExamples(tx, dbID)
+		if err != nil {
+			return err
+		}
+
+		for _, existing := range examples {
+			if strings.EqualFold(strings.TrimSpace(existing.Question), strings.TrimSpace(example.Question)) {
+				exampleID = existing.ID
+				break
+			}
+		}
+
+		if exampleID != 0 {
+			_, err = tx.Exec(`UPDATE examples SET question = ?, sql = ?, verified_by = ?, created_at = ? WHERE id = ?`,
+				example.Question, example.SQL, example.VerifiedBy, example.CreatedAt.UnixMicro(), exampleID)
+		} else {
+			exampleID, err = insertReturningID(tx,
+				`INSERT INTO examples (database_id, question, sql, verified_by, created_at) VALUES (?, ?, ?, ?, ?)`,
+				dbID, example.Question, example.SQL, example.VerifiedBy, example.CreatedAt.UnixMicro())
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save example: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return exampleID, nil
+}
+
+// AddFeedback stores the feedback and returns its ID
+func (r *DatabaseRepoSqliteImpl) AddFeedback(feedback Feedback) (int, error) {
+	if feedback.CreatedAt.IsZero() {
+		feedback.CreatedAt = time.Now()
+	}
+
+	var feedbackID int
+	err := r.inTx(func(tx *sql.Tx) error {
+		if err := checkDatabaseExists(tx, feedback.DatabaseID); err != nil {
+			return err
+		}
+
+		var err error
+		feedbackID, err = insertFeedback(tx, feedback)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return feedbackID, nil
+}
+
+func insertFeedback(q querier, feedback Feedback) (int, error) {
+	feedbackID, err := insertWithOptionalID(q, feedback.ID,
+		`INSERT INTO feedbacks (database_id, user_id, question, sql, model, positive, correction, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO feedbacks (id, database_id, user_id, question, sql, model, positive, correction, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		feedback.DatabaseID, feedback.UserID, feedback.Question, feedback.SQL, feedback.Model, feedback.Positive,
+		feedback.Correction, feedback.CreatedAt.UnixMicro())
+	if err != nil {
+		return 0, fmt.Errorf("failed to save feedback: %w", err)
+	}
+	return feedbackID, nil
+}
+
+func (r *DatabaseRepoSqliteImpl) SetFeedbackCorrection(feedbackID int, correction string) error {
+	result, err := r.db.Exec(`UPDATE feedbacks SET correction = ? WHERE id = ?`, correction, feedbackID)
+	if err != nil {
+		return fmt.Errorf("failed to save feedback correction: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to save feedback correction: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("feedback with ID %d not found", feedbackID)
+	}
+
+	return nil
+}
+
+// GetFeedbacks returns the feedbacks created at or after since
+func (r *DatabaseRepoSqliteImpl) GetFeedbacks(since time.Time) ([]Feedback, error) {
+	rows, err := r.db.Query(`
+		SELECT id, database_id, user_id, question, sql, model, positive, correction, created_at
+		FROM feedbacks
+		WHERE created_at >= ?
+		ORDER BY id
+	`, since.UnixMicro())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feedbacks: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Feedback
+	for rows.Next() {
+		var feedback Feedback
+		var createdAt int64
+		err := rows.Scan(&feedback.ID, &feedback.DatabaseID, &feedback.UserID, &feedback.Question, &feedback.SQL,
+			&feedback.Model, &feedback.Positive, &feedback.Correction, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan feedback: %w", err)
+		}
+		feedback.CreatedAt = time.UnixMicro(createdAt)
+		result = append(result, feedback)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating feedback rows: %w", err)
+	}
+
+	return result, nil
+}
+
+// AddSchedule stores the schedule and returns its ID
+func (r *DatabaseRepoSqliteImpl) AddSchedule(schedule Schedule) (int, error) {
+	if schedule.CreatedAt.IsZero() {
+		schedule.CreatedAt = time.Now()
+	}
+
+	var scheduleID int
+	err := r.inTx(func(tx *sql.Tx) error {
+		if err := checkDatabaseExists(tx, schedule.DatabaseID); err != nil {
+			return err
+		}
+
+		var err error
+		scheduleID, err = insertSchedule(tx, schedule)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return scheduleID, nil
+}
+
+func insertSchedule(q querier, schedule Schedule) (int, error) {
+	scheduleID, err := insertWithOptionalID(q, schedule.ID,
+		`INSERT INTO schedules (user_id, database_id, connection, cron, question, sql, paused, last_run_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO schedules (id, user_id, database_id, connection, cron, question, sql, paused, last_run_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		schedule.UserID, schedule.DatabaseID, schedule.Connection, schedule.Cron, schedule.Question, schedule.SQL,
+		schedule.Paused, unixMicroOrZero(schedule.LastRunAt), schedule.CreatedAt.UnixMicro())
+	if err != nil {
+		return 0, fmt.Errorf("failed to save schedule: %w", err)
+	}
+	return scheduleID, nil
+}
+
+// GetSchedules returns every schedule in the order they were added
+func (r *DatabaseRepoSqliteImpl) GetSchedules() ([]Schedule, error) {
+	rows, err := r.db.Query(`
+		SELECT id, user_id, database_id, connection, cron, question, sql, paused, last_run_at, created_at
+		FROM schedules
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Schedule
+	for rows.Next() {
+		var schedule Schedule
+		var lastRunAt, createdAt int64
+		err := rows.Scan(&schedule.ID, &schedule.UserID, &schedule.DatabaseID, &schedule.Connection, &schedule.Cron,
+			&schedule.Question, &schedule.SQL, &schedule.Paused, &lastRunAt, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		if lastRunAt != 0 {
+			schedule.LastRunAt = time.UnixMicro(lastRunAt)
+		}
+		schedule.CreatedAt = time.UnixMicro(createdAt)
+		result = append(result, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating schedule rows: %w", err)
+	}
+
+	return result, nil
+}
+
+// SetSchedulePaused pauses or resumes the schedule. Resuming counts the next run from now,
+// so that the runs missed while paused do not fire at once.
+func (r *DatabaseRepoSqliteImpl) SetSchedulePaused(ID int, paused bool) error {
+	if !paused {
+		return r.updateSchedule(ID, `UPDATE schedules SET paused = ?, last_run_at = ? WHERE id = ?`, paused, time.Now().UnixMicro())
+	}
+	return r.updateSchedule(ID, `UPDATE schedules SET paused = ? WHERE id = ?`, paused)
+}
+
+func (r *DatabaseRepoSqliteImpl) SetScheduleLastRun(ID int, lastRun time.Time) error {
+	return r.updateSchedule(ID, `UPDATE schedules SET last_run_at = ? WHERE id = ?`, unixMicroOrZero(lastRun))
+}
+
+func (r *DatabaseRepoSqliteImpl) DeleteSchedule(ID int) error {
+	return r.updateSchedule(ID, `DELETE FROM schedules WHERE id = ?`)
+}
+
+// GetTokenUsages returns the usage of every user, by user ID
+func (r *DatabaseRepoSqliteImpl) GetTokenUsages() ([]TokenUsage, error) {
+	rows, err := r.db.Query(`
+		SELECT user_id, day, month, daily_tokens, monthly_tokens, queries
+		FROM token_usages
+		ORDER BY user_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query token usages: %w", err)
+	}
+	defer rows.Close()
+
+	var result []TokenUsage
+	for rows.Next() {
+		var usage TokenUsage
+		err := rows.Scan(&usage.UserID, &usage.Day, &usage.Month, &usage.DailyTokens, &usage.MonthlyTokens, &usage.Queries)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan token usage: %w", err)
+		}
+		result = append(result, usage)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating token usage rows: %w", err)
+	}
+
+	return result, nil
+}
+
+// SetTokenUsage stores the usage of the user, replacing the previous one
+func (r *DatabaseRepoSqliteImpl) SetTokenUsage(usage TokenUsage) error {
+	if err := insertTokenUsage(r.db, usage); err != nil {
+		return fmt.Errorf("failed to save token usage: %w", err)
+	}
+	return nil
+}
+
+func insertTokenUsage(q querier, usage TokenUsage) error {
+	_, err := q.Exec(`
+		INSERT INTO token_usages (user_id, day, month, daily_tokens, monthly_tokens, queries) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET day = excluded.day, month = excluded.month,
+			daily_tokens = excluded.daily_tokens, monthly_tokens = excluded.monthly_tokens, queries = excluded.queries
+	`, usage.UserID, usage.Day, usage.Month, usage.DailyTokens, usage.MonthlyTokens, usage.Queries)
+	return err
+}
+
+// DeleteTokenUsage forgets the usage of the user
+func (r *DatabaseRepoSqliteImpl) DeleteTokenUsage(userID int64) error {
+	if _, err := r.db.Exec(`DELETE FROM token_usages WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete token usage: %w", err)
+	}
+	return nil
+}
+
+// ClearTokenUsages forgets the usage of every user
+func (r *DatabaseRepoSqliteImpl) ClearTokenUsages() error {
+	if _, err := r.db.Exec(`DELETE FROM token_usages`); err != nil {
+		return fmt.Errorf("failed to delete token usages: %w", err)
+	}
+	return nil
+}
+
+// updateSchedule runs the statement, which takes the schedule ID as its last argument
+func (r *DatabaseRepoSqliteImpl) updateSchedule(ID int, statement string, args ...any) error {
+	result, err := r.db.Exec(statement, append(args, ID)...)
+	if err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("schedule with ID %d not found", ID)
+	}
+
+	return nil
+}
+
+// unixMicroOrZero stores the zero time as 0 rather than as a large negative number
+func unixMicroOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMicro()
+}
+
+func (r *DatabaseRepoSqliteImpl) DeleteDatabase(ID int) error {
+	return r.inTx(func(tx *sql.Tx) error {
+		if err := checkDatabaseExists(tx, ID); err != nil {
+			return err
+		}
+
+		// Tables, columns, descriptions, glossary, examples and schedules are removed by cascade
+		if _, err := tx.Exec(`DELETE FROM databases WHERE id = ?`, ID); err != nil {
+			return fmt.Errorf("failed to delete database: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *DatabaseRepoSqliteImpl) RenameDatabase(ID int, name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("database name must not be empty")
+	}
+
+	return r.inTx(func(tx *sql.Tx) error {
+		if err := checkDatabaseExists(tx, ID); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`UPDATE databases SET name = ? WHERE id = ?`, name, ID); err != nil {
+			return fmt.Errorf("failed to rename database: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateTables replaces the tables of a database with a fresh snapshot. Tables and
+// columns that still exist keep their IDs and descriptions, new ones get new IDs and
+// the ones missing from the snapshot are removed.
+func (r *DatabaseRepoSqliteImpl) UpdateTables(ID int, tables []Table) error {
+	return r.inTx(func(tx *sql.Tx) error {
+		database, err := loadDatabase(tx, ID)
+		if err != nil {
+			return err
+		}
+
+		merged := mergeTables(database.Tables, tables)
+		if err := deleteRemovedTables(tx, database.Tables, merged); err != nil {
+			return err
+		}
+
+		for i, table := range merged {
+			if table.ID == 0 {
+				if err := insertTable(tx, ID, table, i); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if _, err := tx.Exec(`UPDATE tables SET position = ? WHERE id = ?`, i, table.ID); err != nil {
+				return fmt.Errorf("failed to update table %s: %w", table.Name, err)
+			}
+
+			for j, column := range table.Columns {
+				if column.ID == 0 {
+					if err := insertColumn(tx, ID, table.ID, column, j); err != nil {
+						return fmt.Errorf("failed to insert column %s of table %s: %w", column.Name, table.Name, err)
+					}
+					continue
+				}
+
+				_, err := tx.Exec(`UPDATE columns SET data_type = ?, position = ? WHERE id = ?`, column.DataType, j, column.ID)
+				if err != nil {
+					return fmt.Errorf("failed to update column %s of table %s: %w", column.Name, table.Name, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// deleteRemovedTables deletes the tables and columns of existing that are not kept in
+// merged, together with their descriptions
+func deleteRemovedTables(tx *sql.Tx, existing []Table, merged []Table) error {
+	keptTables := make(map[int]bool)
+	keptColumns := make(map[int]bool)
+	for _, table := range merged {
+		keptTables[table.ID] = true
+		for _, column := range table.Columns {
+			keptColumns[column.ID] = true
+		}
+	}
+
+	for _, table := range existing {
+		for _, column := range table.Columns {
+			if keptTables[table.ID] && keptColumns[column.ID] {
+				continue
+			}
+
+			if _, err := tx.Exec(`DELETE FROM columns WHERE id = ?`, column.ID); err != nil {
+				return fmt.Errorf("failed to delete column %s of table %s: %w", column.Name, table.Name, err)
+			}
+			if err := upsertDescription(tx, 0, ColumnFieldType, column.ID, ""); err != nil {
+				return err
+			}
+		}
+
+		if keptTables[table.ID] {
+			continue
+		}
+
+		if _, err := tx.Exec(`DELETE FROM tables WHERE id = ?`, table.ID); err != nil {
+			return fmt.Errorf("failed to delete table %s: %w", table.Name, err)
+		}
+		if err := upsertDescription(tx, 0, TableFieldType, table.ID, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Import copies every database, feedback, schedule and token usage of another repository into
+// this one, keeping their IDs. The repository must be empty.
+func (r *DatabaseRepoSqliteImpl) Import(source DatabaseRepo) error {
+	databases, err := source.GetAllDatabases()
+	if err != nil {
+		return fmt.Errorf("failed to read source databases: %w", err)
+	}
+
+	feedbacks, err := source.GetFeedbacks(time.Time{})
+	if err != nil {
+		return fmt.Errorf("failed to read source feedbacks: %w", err)
+	}
+
+	schedules, err := source.GetSchedules()
+	if err != nil {
+		return fmt.Errorf("failed to read source schedules: %w", err)
+	}
+
+	tokenUsages, err := source.GetTokenUsages()
+	if err != nil {
+		return fmt.Errorf("failed to read source token usages: %w", err)
+	}
+
+	return r.inTx(func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM databases`).Scan(&count); err != nil {
+			return fmt.Errorf("failed to count databases: %w", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("target repository is not empty")
+		}
+
+		for i := range databases {
+			if _, err := insertDatabase(tx, &databases[i]); err != nil {
+				return err
+			}
+		}
+
+		for _, feedback := range feedbacks {
+			if _, err := insertFeedback(tx, feedback); err != nil {
+				return err
+			}
+		}
+
+		for _, schedule := range schedules {
+			if _, err := insertSchedule(tx, schedule); err != nil {
+				return err
+			}
+		}
+
+		for _, usage := range tokenUsages {
+			if err := insertTokenUsage(tx, usage); err != nil {
+				return fmt.Errorf("failed to save token usage: %w", err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,515 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestRepos(t *testing.T) map[string]DatabaseRepo {
+	dir := t.TempDir()
+	sqliteRepo, err := NewDatabaseRepoSqliteImpl(filepath.Join(dir, "data.db"))
+	if err != nil {
+		t.Fatalf("failed to create sqlite repo: %v", err)
+	}
+	t.Cleanup(func() { sqliteRepo.Close() })
+
+	mapRepo, err := NewDatabaseRepoMapImpl(filepath.Join(dir, "data.json"), MapRepoOptions{Backups: 2})
+	if err != nil {
+		t.Fatalf("failed to create map repo: %v", err)
+	}
+
+	return map[string]DatabaseRepo{
+		"map":    mapRepo,
+		"sqlite": sqliteRepo,
+	}
+}
+
+func newTestDatabase() *Database {
+	return &Database{
+		Name:       "postgres",
+		Driver:     "postgres",
+		Connection: "postgres",
+		Tables: []Table{
+			{Name: "users", Columns: []Column{{Name: "id", DataType: "integer"}, {Name: "nickname", DataType: "text"}}},
+			{Name: "user_payments", Columns: []Column{{Name: "amount", DataType: "bigint"}, {Name: "fee", DataType: "bigint"}}},
+		},
+	}
+}
+
+func TestDatabaseRepo(t *testing.T) {
+	for name, databaseRepo := range newTestRepos(t) {
+		t.Run(name, func(t *testing.T) {
+			databaseID, err := databaseRepo.CreateNewDatabase(newTestDatabase())
+			if err != nil {
+				t.Fatalf("CreateNewDatabase: %v", err)
+			}
+
+			database, err := databaseRepo.GetDatabase(databaseID)
+			if err != nil {
+				t.Fatalf("GetDatabase: %v", err)
+			}
+			if database.Driver != "postgres" || database.Connection != "postgres" {
+				t.Fatalf("unexpected driver or connection: %q, %q", database.Driver, database.Connection)
+			}
+			users, _ := database.GetTableByName("users")
+			nickname, _ := users.GetColumnByName("nickname")
+			if users.ID == 0 || nickname.ID == 0 {
+				t.Fatalf("IDs are not assigned: table %d, column %d", users.ID, nickname.ID)
+			}
+
+			err = databaseRepo.SetDescriptions(databaseID, []DescriptionChange{
+				{FieldID: users.ID, FieldType: TableFieldType, Description: "registered users"},
+				{FieldID: nickname.ID, FieldType: ColumnFieldType, Description: "display name"},
+			})
+			if err != nil {
+				t.Fatalf("SetDescriptions: %v", err)
+			}
+
+			// A single invalid change must leave every description untouched
+			err = databaseRepo.SetDescriptions(databaseID, []DescriptionChange{
+				{FieldID: users.ID, FieldType: TableFieldType, Description: "changed"},
+				{FieldID: 1000, FieldType: ColumnFieldType, Description: "missing"},
+			})
+			if err == nil {
+				t.Fatalf("SetDescriptions with unknown column should fail")
+			}
+
+			database, _ = databaseRepo.GetDatabase(databaseID)
+			users, _ = database.GetTableByName("users")
+			nickname, _ = users.GetColumnByName("nickname")
+			if users.Description != "registered users" || nickname.Description != "display name" {
+				t.Fatalf("unexpected descriptions: %q, %q", users.Description, nickname.Description)
+			}
+
+			firstTermID, err := databaseRepo.SetGlossaryTerm(databaseID, GlossaryTerm{Term: "Revenue", Definition: "amount"})
+			if err != nil {
+				t.Fatalf("SetGlossaryTerm: %v", err)
+			}
+			secondTermID, err := databaseRepo.SetGlossaryTerm(databaseID, GlossaryTerm{Term: "revenue", Definition: "amount - fee"})
+			if err != nil {
+				t.Fatalf("SetGlossaryTerm: %v", err)
+			}
+			if firstTermID != secondTermID {
+				t.Fatalf("glossary term should be updated in place, got IDs %d and %d", firstTermID, secondTermID)
+			}
+
+			_, err = databaseRepo.AddExample(databaseID, Example{Question: "how many users?", SQL: "SELECT COUNT(*) FROM users", VerifiedBy: 1})
+			if err != nil {
+				t.Fatalf("AddExample: %v", err)
+			}
+
+			database, _ = databaseRepo.GetDatabase(databaseID)
+			term, _ := database.GetGlossaryTerm("REVENUE")
+			if len(database.Glossary) != 1 || term.Definition != "amount - fee" {
+				t.Fatalf("unexpected glossary: %+v", database.Glossary)
+			}
+			if len(database.Examples) != 1 {
+				t.Fatalf("unexpected examples: %+v", database.Examples)
+			}
+
+			if err := databaseRepo.DeleteGlossaryTerm(databaseID, "revenue"); err != nil {
+				t.Fatalf("DeleteGlossaryTerm: %v", err)
+			}
+
+			feedbackID, err := databaseRepo.AddFeedback(Feedback{DatabaseID: databaseID, UserID: 1, Question: "q", SQL: "s", Model: "m"})
+			if err != nil {
+				t.Fatalf("AddFeedback: %v", err)
+			}
+			if err := databaseRepo.SetFeedbackCorrection(feedbackID, "fixed"); err != nil {
+				t.Fatalf("SetFeedbackCorrection: %v", err)
+			}
+
+			feedbacks, err := databaseRepo.GetFeedbacks(time.Now().Add(-time.Hour))
+			if err != nil {
+				t.Fatalf("GetFeedbacks: %v", err)
+			}
+			if len(feedbacks) != 1 || feedbacks[0].Correction != "fixed" {
+				t.Fatalf("unexpected feedbacks: %+v", feedbacks)
+			}
+		})
+	}
+}
+
+func TestDatabaseRepo_Snapshots(t *testing.T) {
+	for name, databaseRepo := range newTestRepos(t) {
+		t.Run(name, func(t *testing.T) {
+			databaseID, err := databaseRepo.CreateNewDatabase(newTestDatabase())
+			if err != nil {
+				t.Fatalf("CreateNewDatabase: %v", err)
+			}
+
+			database, _ := databaseRepo.GetDatabase(databaseID)
+			users, _ := database.GetTableByName("users")
+			nickname, _ := users.GetColumnByName("nickname")
+			if err := databaseRepo.SetDescription(databaseID, "display name", nickname.ID, ColumnFieldType); err != nil {
+				t.Fatalf("SetDescription: %v", err)
+			}
+
+			// users loses id and gains email, user_payments is dropped and accounts is new
+			err = databaseRepo.UpdateTables(databaseID, []Table{
+				{Name: "users", Columns: []Column{{Name: "nickname", DataType: "varchar"}, {Name: "email", DataType: "text"}}},
+				{Name: "accounts", Columns: []Column{{Name: "id", DataType: "integer"}}},
+			})
+			if err != nil {
+				t.Fatalf("UpdateTables: %v", err)
+			}
+
+			database, _ = databaseRepo.GetDatabase(databaseID)
+			if len(database.Tables) != 2 {
+				t.Fatalf("unexpected tables: %+v", database.Tables)
+			}
+			updatedUsers, _ := database.GetTableByName("users")
+			updatedNickname, _ := updatedUsers.GetColumnByName("nickname")
+			email, _ := updatedUsers.GetColumnByName("email")
+			if updatedUsers.ID != users.ID || updatedNickname.ID != nickname.ID || updatedNickname.Description != "display name" {
+				t.Fatalf("kept column lost its ID or description: %+v", updatedNickname)
+			}
+			if updatedNickname.DataType != "varchar" || email.ID == 0 || len(updatedUsers.Columns) != 2 {
+				t.Fatalf("unexpected columns: %+v", updatedUsers.Columns)
+			}
+			if _, found := database.GetTableByName("user_payments"); found {
+				t.Fatalf("dropped table is still in the snapshot")
+			}
+
+			if err := databaseRepo.RenameDatabase(databaseID, "payments"); err != nil {
+				t.Fatalf("RenameDatabase: %v", err)
+			}
+			databases, err := databaseRepo.ListByDriver("postgres")
+			if err != nil {
+				t.Fatalf("ListByDriver: %v", err)
+			}
+			if len(databases) != 1 || databases[0].Name != "payments" {
+				t.Fatalf("unexpected databases: %+v", databases)
+			}
+			if databases, _ := databaseRepo.ListByDriver("mysql"); len(databases) != 0 {
+				t.Fatalf("unexpected mysql databases: %+v", databases)
+			}
+
+			if err := databaseRepo.DeleteDatabase(databaseID); err != nil {
+				t.Fatalf("DeleteDatabase: %v", err)
+			}
+			if _, err := databaseRepo.GetDatabase(databaseID); err == nil {
+				t.Fatalf("deleted database is still stored")
+			}
+		})
+	}
+}
+
+func TestDatabaseRepoSqliteImpl_Import(t *testing.T) {
+	dir := t.TempDir()
+	source, err := NewDatabaseRepoMapImpl(filepath.Join(dir, "data.json"), MapRepoOptions{})
+	if err != nil {
+		t.Fatalf("failed to create map repo: %v", err)
+	}
+	databaseID, err := source.CreateNewDatabase(newTestDatabase())
+	if err != nil {
+		t.Fatalf("CreateNewDatabase: %v", err)
+	}
+	if _, err := source.SetGlossaryTerm(databaseID, GlossaryTerm{Term: "revenue", Definition: "amount - fee"}); err != nil {
+		t.Fatalf("SetGlossaryTerm: %v", err)
+	}
+	usage := TokenUsage{UserID: 7, Day: "2026-10-18", Month: "2026-10", DailyTokens: 120, MonthlyTokens: 900, Queries: 2}
+	if err := source.SetTokenUsage(usage); err != nil {
+		t.Fatalf("SetTokenUsage: %v", err)
+	}
+
+	target, err := NewDatabaseRepoSqliteImpl(filepath.Join(dir, "data.db"))
+	if err != nil {
+		t.Fatalf("failed to create sqlite repo: %v", err)
+	}
+	defer target.Close()
+
+	if err := target.Import(source); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	expected, _ := source.GetDatabase(databaseID)
+	imported, err := target.GetDatabase(databaseID)
+	if err != nil {
+		t.Fatalf("GetDatabase: %v", err)
+	}
+	if len(imported.Tables) != len(expected.Tables) || len(imported.Glossary) != 1 {
+		t.Fatalf("unexpected imported database: %+v", imported)
+	}
+	for i, table := range expected.Tables {
+		if imported.Tables[i].ID != table.ID || imported.Tables[i].Columns[0].ID != table.Columns[0].ID {
+			t.Fatalf("IDs are not preserved for table %s", table.Name)
+		}
+	}
+	if usages, err := target.GetTokenUsages(); err != nil || len(usages) != 1 || usages[0] != usage {
+		t.Fatalf("unexpected imported token usages: %+v, %v", usages, err)
+	}
+
+	if err := target.Import(source); err == nil {
+		t.Fatalf("importing into a non-empty repository should fail")
+	}
+}
+
+func TestDatabaseRepoMapImpl_RestoreFromBackup(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "data.json")
+	databaseRepo, err := NewDatabaseRepoMapImpl(filePath, MapRepoOptions{Backups: 2})
+	if err != nil {
+		t.Fatalf("failed to create map repo: %v", err)
+	}
+
+	databaseID, err := databaseRepo.CreateNewDatabase(newTestDatabase())
+	if err != nil {
+		t.Fatalf("CreateNewDatabase: %v", err)
+	}
+	if err := databaseRepo.SetDescription(databaseID, "main database", databaseID, DatabaseFieldType); err != nil {
+		t.Fatalf("SetDescription: %v", err)
+	}
+
+	// Simulate a write interrupted halfway
+	if err := os.WriteFile(filePath, []byte(`{"databases": {"1": {"ID"`), 0644); err != nil {
+		t.Fatalf("failed to corrupt file: %v", err)
+	}
+
+	if _, err := NewDatabaseRepoMapImpl(filePath, MapRepoOptions{Backups: 2}); err == nil {
+		t.Fatalf("loading a corrupt file without restoring should fail")
+	}
+
+	restored, err := NewDatabaseRepoMapImpl(filePath, MapRepoOptions{Backups: 2, RestoreFromBackup: true})
+	if err != nil {
+		t.Fatalf("failed to restore from backup: %v", err)
+	}
+
+	// The newest backup holds the content before the last write
+	database, err := restored.GetDatabase(databaseID)
+	if err != nil {
+		t.Fatalf("GetDatabase: %v", err)
+	}
+	if len(database.Tables) != 2 {
+		t.Fatalf("unexpected restored database: %+v", database)
+	}
+}
+
+func TestDatabaseRepoMapImpl_RefusesCorruptFile(t *testing.T) {
+	for name, content := range map[string]string{
+		"truncated":        `{"databases": {"1": {"ID"`,
+		"negative version": `{"version": -1}`,
+		"future version":   `{"version": 99}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "data.json")
+			if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			if _, err := NewDatabaseRepoMapImpl(filePath, MapRepoOptions{Backups: 2}); err == nil {
+				t.Fatalf("loading a corrupt file should fail")
+			}
+		})
+	}
+}
+
+func TestDatabaseRepoMapImpl_RestoreMissingFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "data.json")
+	databaseRepo, err := NewDatabaseRepoMapImpl(filePath, MapRepoOptions{Backups: 2})
+	if err != nil {
+		t.Fatalf("failed to create map repo: %v", err)
+	}
+
+	databaseID, err := databaseRepo.CreateNewDatabase(newTestDatabase())
+	if err != nil {
+		t.Fatalf("CreateNewDatabase: %v", err)
+	}
+	if err := databaseRepo.SetDescription(databaseID, "main database", databaseID, DatabaseFieldType); err != nil {
+		t.Fatalf("SetDescription: %v", err)
+	}
+
+	if err := os.Remove(filePath); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+
+	if _, err := NewDatabaseRepoMapImpl(filePath, MapRepoOptions{Backups: 2}); err == nil {
+		t.Fatalf("loading a missing file with backups without restoring should fail")
+	}
+
+	restored, err := NewDatabaseRepoMapImpl(filePath, MapRepoOptions{Backups: 2, RestoreFromBackup: true})
+	if err != nil {
+		t.Fatalf("failed to restore from backup: %v", err)
+	}
+	if _, err := restored.GetDatabase(databaseID); err != nil {
+		t.Fatalf("GetDatabase: %v", err)
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		t.Fatalf("expected the restored data to be written back: %v", err)
+	}
+}
+
+func TestDatabaseRepoMapImpl_SetGlossaryTermSaveFailure(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "data.json")
+	databaseRepo, err := NewDatabaseRepoMapImpl(filePath, MapRepoOptions{})
+	if err != nil {
+		t.Fatalf("failed to create map repo: %v", err)
+	}
+	databaseID, err := databaseRepo.CreateNewDatabase(newTestDatabase())
+	if err != nil {
+		t.Fatalf("CreateNewDatabase: %v", err)
+	}
+
+	// Writing into a missing directory fails
+	mapRepo := databaseRepo.(*DatabaseRepoMapImpl)
+	mapRepo.filePath = filepath.Join(dir, "missing", "data.json")
+	if _, err := databaseRepo.SetGlossaryTerm(databaseID, GlossaryTerm{Term: "revenue", Definition: "amount"}); err == nil {
+		t.Fatalf("expected the save to fail")
+	}
+
+	mapRepo.filePath = filePath
+	termID, err := databaseRepo.SetGlossaryTerm(databaseID, GlossaryTerm{Term: "revenue", Definition: "amount"})
+	if err != nil {
+		t.Fatalf("SetGlossaryTerm: %v", err)
+	}
+	if termID != 1 {
+		t.Fatalf("expected the failed save not to use up an ID, got %d", termID)
+	}
+}
+
+func TestDatabaseRepoMapImpl_AddScheduleSaveFailure(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "data.json")
+	databaseRepo, err := NewDatabaseRepoMapImpl(filePath, MapRepoOptions{})
+	if err != nil {
+		t.Fatalf("failed to create map repo: %v", err)
+	}
+	databaseID, err := databaseRepo.CreateNewDatabase(newTestDatabase())
+	if err != nil {
+		t.Fatalf("CreateNewDatabase: %v", err)
+	}
+
+	// Writing into a missing directory fails
+	mapRepo := databaseRepo.(*DatabaseRepoMapImpl)
+	mapRepo.filePath = filepath.Join(dir, "missing", "data.json")
+	schedule := Schedule{DatabaseID: databaseID, Cron: "@daily", Question: "signups"}
+	if _, err := databaseRepo.AddSchedule(schedule); err == nil {
+		t.Fatalf("expected the save to fail")
+	}
+
+	mapRepo.filePath = filePath
+	scheduleID, err := databaseRepo.AddSchedule(schedule)
+	if err != nil {
+		t.Fatalf("AddSchedule: %v", err)
+	}
+	if scheduleID != 1 {
+		t.Fatalf("expected the failed save not to use up an ID, got %d", scheduleID)
+	}
+	if schedules, _ := databaseRepo.GetSchedules(); len(schedules) != 1 {
+		t.Fatalf("expected a single schedule, got %+v", schedules)
+	}
+}
+
+func TestDatabaseRepo_Schedules(t *testing.T) {
+	for name, databaseRepo := range newTestRepos(t) {
+		t.Run(name, func(t *testing.T) {
+			databaseID, err := databaseRepo.CreateNewDatabase(newTestDatabase())
+			if err != nil {
+				t.Fatalf("CreateNewDatabase: %v", err)
+			}
+
+			if _, err := databaseRepo.AddSchedule(Schedule{DatabaseID: databaseID + 1, Cron: "0 9 * * 1"}); err == nil {
+				t.Fatalf("adding a schedule of a missing database should fail")
+			}
+			scheduleID, err := databaseRepo.AddSchedule(Schedule{
+				UserID:     7,
+				DatabaseID: databaseID,
+				Connection: "postgres",
+				Cron:       "0 9 * * 1",
+				Question:   "weekly payment totals",
+			})
+			if err != nil {
+				t.Fatalf("AddSchedule: %v", err)
+			}
+
+			lastRun := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+			if err := databaseRepo.SetSchedulePaused(scheduleID, true); err != nil {
+				t.Fatalf("SetSchedulePaused: %v", err)
+			}
+			if err := databaseRepo.SetScheduleLastRun(scheduleID, lastRun); err != nil {
+				t.Fatalf("SetScheduleLastRun: %v", err)
+			}
+
+			schedules, err := databaseRepo.GetSchedules()
+			if err != nil {
+				t.Fatalf("GetSchedules: %v", err)
+			}
+			if len(schedules) != 1 || schedules[0].UserID != 7 || !schedules[0].Paused || !schedules[0].LastRunAt.Equal(lastRun) ||
+				schedules[0].CreatedAt.IsZero() {
+				t.Fatalf("unexpected schedules: %+v", schedules)
+			}
+
+			// Resuming skips the runs missed while paused
+			if err := databaseRepo.SetSchedulePaused(scheduleID, false); err != nil {
+				t.Fatalf("SetSchedulePaused: %v", err)
+			}
+			schedules, err = databaseRepo.GetSchedules()
+			if err != nil {
+				t.Fatalf("GetSchedules: %v", err)
+			}
+			if schedules[0].Paused || time.Since(schedules[0].LastRunAt) > time.Minute {
+				t.Fatalf("expected the resumed schedule to count from now: %+v", schedules[0])
+			}
+
+			if err := databaseRepo.DeleteSchedule(scheduleID); err != nil {
+				t.Fatalf("DeleteSchedule: %v", err)
+			}
+			if err := databaseRepo.DeleteSchedule(scheduleID); err == nil {
+				t.Fatalf("deleting a missing schedule should fail")
+			}
+
+			// Schedules are removed together with their database
+			if _, err := databaseRepo.AddSchedule(Schedule{DatabaseID: databaseID, Cron: "@daily", Question: "signups"}); err != nil {
+				t.Fatalf("AddSchedule: %v", err)
+			}
+			if err := databaseRepo.DeleteDatabase(databaseID); err != nil {
+				t.Fatalf("DeleteDatabase: %v", err)
+			}
+			if schedules, _ := databaseRepo.GetSchedules(); len(schedules) != 0 {
+				t.Fatalf("expected the schedules of the deleted database to be removed, got %+v", schedules)
+			}
+		})
+	}
+}
+
+func TestDatabaseRepo_TokenUsages(t *testing.T) {
+	for name, databaseRepo := range newTestRepos(t) {
+		t.Run(name, func(t *testing.T) {
+			first := TokenUsage{UserID: 7, Day: "2026-10-18", Month: "2026-10", DailyTokens: 120, MonthlyTokens: 900, Queries: 2}
+			second := TokenUsage{UserID: 3, Day: "2026-10-18", Month: "2026-10", DailyTokens: 40, MonthlyTokens: 40, Queries: 1}
+			for _, usage := range []TokenUsage{first, second} {
+				if err := databaseRepo.SetTokenUsage(usage); err != nil {
+					t.Fatalf("SetTokenUsage: %v", err)
+				}
+			}
+			first.DailyTokens, first.MonthlyTokens, first.Queries = 200, 980, 3
+			if err := databaseRepo.SetTokenUsage(first); err != nil {
+				t.Fatalf("SetTokenUsage: %v", err)
+			}
+
+			usages, err := databaseRepo.GetTokenUsages()
+			if err != nil {
+				t.Fatalf("GetTokenUsages: %v", err)
+			}
+			if len(usages) != 2 || usages[0] != second || usages[1] != first {
+				t.Fatalf("unexpected token usages: %+v", usages)
+			}
+
+			if err := databaseRepo.DeleteTokenUsage(first.UserID); err != nil {
+				t.Fatalf("DeleteTokenUsage: %v", err)
+			}
+			if usages, _ := databaseRepo.GetTokenUsages(); len(usages) != 1 || usages[0] != second {
+				t.Fatalf("expected only the usage of user 3 to be left, got %+v", usages)
+			}
+
+			if err := databaseRepo.ClearTokenUsages(); err != nil {
+				t.Fatalf("ClearTokenUsages: %v", err)
+			}
+			if usages, _ := databaseRepo.GetTokenUsages(); len(usages) != 0 {
+				t.Fatalf("expected no token usages, got %+v", usages)
+			}
+		})
+	}
+}
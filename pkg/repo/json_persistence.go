@@ -0,0 +1,155 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// persistenceVersion is the format version written to the JSON file.
+// Bump it together with appending a migration to persistenceMigrations.
+const persistenceVersion = 4
+
+// persistenceMigrations upgrade the decoded data of an older format version in place.
+// persistenceMigrations[i] upgrades version i to version i+1.
+var persistenceMigrations = []func(data *persistenceData){
+	// 0 -> 1: files written before versioning may lack the counters of newer entities
+	func(data *persistenceData) {
+		maxTermID, maxExampleID, maxFeedbackID := 0, 0, 0
+		for _, database := range data.Databases {
+			for _, term := range database.Glossary {
+				maxTermID = max(maxTermID, term.ID)
+			}
+			for _, example := range database.Examples {
+				maxExampleID = max(maxExampleID, example.ID)
+			}
+		}
+		for _, feedback := range data.Feedbacks {
+			maxFeedbackID = max(maxFeedbackID, feedback.ID)
+		}
+
+		data.NextDatabaseID = max(data.NextDatabaseID, 1)
+		data.NextTableID = max(data.NextTableID, 1)
+		data.NextColumnID = max(data.NextColumnID, 1)
+		data.NextGlossaryTermID = max(data.NextGlossaryTermID, maxTermID+1)
+		data.NextExampleID = max(data.NextExampleID, maxExampleID+1)
+		data.NextFeedbackID = max(data.NextFeedbackID, maxFeedbackID+1)
+	},
+	// 1 -> 2: databases record their driver, older snapshots were named after it
+	func(data *persistenceData) {
+		for _, database := range data.Databases {
+			if database.Driver == "" {
+				database.Driver = database.Name
+			}
+		}
+	},
+	// 2 -> 3: databases record their connection, which used to be identified by the driver
+	func(data *persistenceData) {
+		for _, database := range data.Databases {
+			if database.Connection == "" {
+				database.Connection = database.Driver
+			}
+		}
+	},
+	// 3 -> 4: schedules were added, their IDs start at 1
+	func(data *persistenceData) {
+		data.NextScheduleID = max(data.NextScheduleID, 1)
+	},
+}
+
+// decodePersistenceData parses the JSON file content and migrates it to the current version
+func decodePersistenceData(data []byte) (persistenceData, error) {
+	var persistData persistenceData
+	if err := json.Unmarshal(data, &persistData); err != nil {
+		return persistenceData{}, fmt.Errorf("failed to unmarshal repository data: %w", err)
+	}
+
+	if persistData.Version < 0 {
+		return persistenceData{}, fmt.Errorf("repository data version %d is invalid", persistData.Version)
+	}
+	if persistData.Version > persistenceVersion {
+		return persistenceData{}, fmt.Errorf("repository data version %d is newer than the supported version %d", persistData.Version, persistenceVersion)
+	}
+
+	for version := persistData.Version; version < persistenceVersion; version++ {
+		persistenceMigrations[version](&persistData)
+	}
+	persistData.Version = persistenceVersion
+
+	return persistData, nil
+}
+
+func backupFilePath(filePath string, index int) string {
+	return fmt.Sprintf("%s.%d", filePath, index)
+}
+
+// rotateBackups shifts the existing backups by one and stores the current content of the
+// file as the newest backup, keeping at most count backups
+func rotateBackups(filePath string, count int) error {
+	if count <= 0 {
+		return nil
+	}
+
+	current, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(backupFilePath(filePath, count)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := count - 1; i >= 1; i-- {
+		err := os.Rename(backupFilePath(filePath, i), backupFilePath(filePath, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return writeFileAtomic(backupFilePath(filePath, 1), current, 0644)
+}
+
+// writeFileAtomic writes data to a temporary file, syncs it to disk and renames it over
+// filePath, so that a crash leaves either the old or the new content but never a
+// truncated file
+func writeFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filePath)
+	tempFile, err := os.CreateTemp(dir, filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath) // no-op once renamed
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tempPath, perm); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempPath, filePath); err != nil {
+		return err
+	}
+
+	// Sync the directory so the rename itself survives a crash
+	dirFile, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	defer dirFile.Close()
+	_ = dirFile.Sync()
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// sqliteMigrations are applied in order, each one in its own transaction.
+// Never edit an existing migration; append a new one instead.
+var sqliteMigrations = []string{
+	// 1: initial schema
+	`
+	CREATE TABLE databases (
+		id   INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL
+	);
+
+	CREATE TABLE tables (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		database_id INTEGER NOT NULL REFERENCES databases (id) ON DELETE CASCADE,
+		name        TEXT    NOT NULL,
+		position    INTEGER NOT NULL,
+		UNIQUE (database_id, name)
+	);
+
+	CREATE TABLE columns (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		table_id  INTEGER NOT NULL REFERENCES tables (id) ON DELETE CASCADE,
+		name      TEXT    NOT NULL,
+		data_type TEXT    NOT NULL,
+		position  INTEGER NOT NULL,
+		UNIQUE (table_id, name)
+	);
+
+	CREATE TABLE descriptions (
+		database_id INTEGER NOT NULL REFERENCES databases (id) ON DELETE CASCADE,
+		field_type  INTEGER NOT NULL,
+		field_id    INTEGER NOT NULL,
+		description TEXT    NOT NULL,
+		PRIMARY KEY (field_type, field_id)
+	);
+
+	CREATE TABLE glossary_terms (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		database_id INTEGER NOT NULL REFERENCES databases (id) ON DELETE CASCADE,
+		term        TEXT    NOT NULL,
+		definition  TEXT    NOT NULL,
+		sql         TEXT    NOT NULL,
+		UNIQUE (database_id, term COLLATE NOCASE)
+	);
+
+	CREATE TABLE examples (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		database_id INTEGER NOT NULL REFERENCES databases (id) ON DELETE CASCADE,
+		question    TEXT    NOT NULL,
+		sql         TEXT    NOT NULL,
+		verified_by INTEGER NOT NULL,
+		created_at  INTEGER NOT NULL
+	);
+
+	CREATE TABLE feedbacks (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		database_id INTEGER NOT NULL,
+		user_id     INTEGER NOT NULL,
+		question    TEXT    NOT NULL,
+		sql         TEXT    NOT NULL,
+		model       TEXT    NOT NULL,
+		positive    INTEGER NOT NULL,
+		correction  TEXT    NOT NULL DEFAULT '',
+		created_at  INTEGER NOT NULL
+	);
+
+	CREATE INDEX feedbacks_created_at ON feedbacks (created_at);
+	`,
+	// 2: databases record their driver, older snapshots were named after it
+	`
+	ALTER TABLE databases ADD COLUMN driver TEXT NOT NULL DEFAULT '';
+	UPDATE databases SET driver = name;
+	CREATE INDEX databases_driver ON databases (driver);
+	`,
+	// 3: databases record their connection, which used to be identified by the driver
+	`
+	ALTER TABLE databases ADD COLUMN connection TEXT NOT NULL DEFAULT '';
+	UPDATE databases SET connection = driver;
+	`,
+	// 4: schedules, removed together with their database
+	`
+	CREATE TABLE schedules (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id     INTEGER NOT NULL,
+		database_id INTEGER NOT NULL REFERENCES databases (id) ON DELETE CASCADE,
+		connection  TEXT    NOT NULL,
+		cron        TEXT    NOT NULL,
+		question    TEXT    NOT NULL,
+		sql         TEXT    NOT NULL DEFAULT '',
+		paused      INTEGER NOT NULL DEFAULT 0,
+		last_run_at INTEGER NOT NULL DEFAULT 0,
+		created_at  INTEGER NOT NULL
+	);
+	`,
+	// 5: token usage of the users, one row per user
+	`
+	CREATE TABLE token_usages (
+		user_id        INTEGER PRIMARY KEY,
+		day            TEXT    NOT NULL,
+		month          TEXT    NOT NULL,
+		daily_tokens   INTEGER NOT NULL,
+		monthly_tokens INTEGER NOT NULL,
+		queries        INTEGER NOT NULL
+	);
+	`,
+}
+
+// migrateSqlite brings the schema of the database up to the latest migration
+func migrateSqlite(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var version int
+	err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("database schema version %d is newer than the supported version %d", version, len(sqliteMigrations))
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		if err := applySqliteMigration(db, i+1, sqliteMigrations[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applySqliteMigration(db *sql.DB, version int, migration string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(migration); err != nil {
+		return fmt.Errorf("failed to apply migration %d: %w", version, err)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", version, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,309 @@
+package sqlparser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Dialect selects the lexical rules of the server the SQL is sent to. They differ in how
+// strings and identifiers are quoted, which decides what is code and what is data.
+type Dialect int
+
+const (
+	// Postgres covers PostgreSQL and CockroachDB
+	Postgres Dialect = iota
+	MySQL
+)
+
+type TokenKind int
+
+const (
+	// TokenWord is an unquoted identifier or keyword
+	TokenWord TokenKind = iota
+	// TokenQuotedIdentifier is an identifier in double quotes or backticks, Value holds it unquoted
+	TokenQuotedIdentifier
+	TokenString
+	TokenNumber
+	// TokenParameter is a placeholder such as $1 or ?
+	TokenParameter
+	// TokenPunctuation is one of ( ) , ; . [ ] or a lone *
+	TokenPunctuation
+	TokenOperator
+)
+
+type Token struct {
+	Kind  TokenKind
+	Value string
+	// Pos and End are the byte offsets of the token in the SQL text
+	Pos int
+	End int
+}
+
+// is reports whether the token is the given punctuation or, case-insensitively, the given keyword
+func (t Token) is(value string) bool {
+	switch t.Kind {
+	case TokenPunctuation, TokenOperator:
+		return t.Value == value
+	case TokenWord:
+		return strings.EqualFold(t.Value, value)
+	default:
+		return false
+	}
+}
+
+func (t Token) isIdentifier() bool {
+	return t.Kind == TokenWord || t.Kind == TokenQuotedIdentifier
+}
+
+const operatorCharacters = "+-/<>=~!@#%^&|?:"
+
+// Tokenize splits the SQL text into tokens, dropping whitespace and comments.
+// Text it can not split unambiguously is an error rather than a guess.
+func Tokenize(sql string, dialect Dialect) ([]Token, error) {
+	l := lexer{sql: sql, dialect: dialect}
+	return l.run()
+}
+
+type lexer struct {
+	sql     string
+	dialect Dialect
+	pos     int
+	tokens  []Token
+	// inExecutableComment is set inside a MySQL /*! ... */ comment, whose content is executed
+	inExecutableComment bool
+}
+
+func (l *lexer) run() ([]Token, error) {
+	for l.pos < len(l.sql) {
+		if err := l.next(); err != nil {
+			return nil, err
+		}
+	}
+	if l.inExecutableComment {
+		return nil, l.errorf(len(l.sql), "unterminated comment")
+	}
+
+	return l.tokens, nil
+}
+
+func (l *lexer) errorf(pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("position %d: %s", pos, fmt.Sprintf(format, args...))
+}
+
+func (l *lexer) emit(kind TokenKind, value string, start int) {
+	l.tokens = append(l.tokens, Token{Kind: kind, Value: value, Pos: start, End: l.pos})
+}
+
+func (l *lexer) peek(offset int) byte {
+	if l.pos+offset >= len(l.sql) {
+		return 0
+	}
+	return l.sql[l.pos+offset]
+}
+
+func (l *lexer) next() error {
+	start := l.pos
+	c := l.sql[l.pos]
+
+	switch {
+	case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+		l.pos++
+	case c == '-' && l.peek(1) == '-':
+		l.skipLine()
+	case c == '#' && l.dialect == MySQL:
+		l.skipLine()
+	case c == '/' && l.peek(1) == '*':
+		return l.skipBlockComment()
+	case c == '*' && l.peek(1) == '/' && l.inExecutableComment:
+		l.inExecutableComment = false
+		l.pos += 2
+	case c == '\'':
+		return l.lexString(start, c, TokenString, l.dialect == MySQL, false)
+	case (c == 'u' || c == 'U') && l.peek(1) == '&' && (l.peek(2) == '\'' || l.peek(2) == '"') && l.dialect == Postgres:
+		// U&"d\0061ta" names the table data, and UESCAPE may change the escape character.
+		// Left undecoded, such names would get past the checks.
+		return l.errorf(start, "unicode escapes are not supported")
+	case (c == 'e' || c == 'E') && l.peek(1) == '\'' && l.dialect == Postgres:
+		l.pos++
+		return l.lexString(start, '\'', TokenString, true, true)
+	case c == '"' && l.dialect == MySQL:
+		// A string by default, an identifier with ANSI_QUOTES. Taking it for an identifier
+		// keeps its content visible to the checks.
+		return l.lexString(start, c, TokenQuotedIdentifier, true, false)
+	case c == '"' || c == '`':
+		return l.lexString(start, c, TokenQuotedIdentifier, false, true)
+	case c == '$' && l.dialect == Postgres:
+		return l.lexDollar(start)
+	case c >= '0' && c <= '9', c == '.' && l.peek(1) >= '0' && l.peek(1) <= '9':
+		l.lexNumber(start)
+	case strings.IndexByte("(),;.[]*", c) >= 0:
+		l.pos++
+		l.emit(TokenPunctuation, string(c), start)
+	case c == '?' && l.dialect == MySQL:
+		l.pos++
+		l.emit(TokenParameter, "?", start)
+	case strings.IndexByte(operatorCharacters, c) >= 0:
+		l.lexOperator(start)
+	default:
+		r, size := utf8.DecodeRuneInString(l.sql[l.pos:])
+		if r != '_' && !unicode.IsLetter(r) {
+			return l.errorf(start, "unexpected character %q", r)
+		}
+		l.pos += size
+		l.lexWord(start)
+	}
+
+	return nil
+}
+
+func (l *lexer) skipLine() {
+	for l.pos < len(l.sql) && l.sql[l.pos] != '\n' {
+		l.pos++
+	}
+}
+
+// skipBlockComment skips a comment, which nests in PostgreSQL. The content of MySQL
+// executable comments is lexed like any other code.
+func (l *lexer) skipBlockComment() error {
+	start := l.pos
+	if l.dialect == MySQL && l.peek(2) == '!' {
+		if l.inExecutableComment {
+			return l.errorf(start, "nested executable comment")
+		}
+		l.pos += 3
+		for l.pos < len(l.sql) && l.sql[l.pos] >= '0' && l.sql[l.pos] <= '9' {
+			l.pos++
+		}
+		l.inExecutableComment = true
+		return nil
+	}
+
+	depth := 0
+	for l.pos < len(l.sql) {
+		switch {
+		case l.peek(0) == '/' && l.peek(1) == '*':
+			depth++
+			l.pos += 2
+		case l.peek(0) == '*' && l.peek(1) == '/':
+			depth--
+			l.pos += 2
+			if depth == 0 || l.dialect == MySQL {
+				return nil
+			}
+		default:
+			l.pos++
+		}
+	}
+
+	return l.errorf(start, "unterminated comment")
+}
+
+// lexString reads a string or identifier quoted with quote. Whether a backslash escapes the
+// quote depends on server settings (standard_conforming_strings, NO_BACKSLASH_ESCAPES), so a
+// backslash in front of the quote is rejected unless escapes are certain, as in E” strings.
+func (l *lexer) lexString(start int, quote byte, kind TokenKind, backslashEscapes bool, certainEscapes bool) error {
+	l.pos++ // opening quote
+	var value strings.Builder
+	for l.pos < len(l.sql) {
+		c := l.sql[l.pos]
+		switch {
+		case c == '\\' && l.peek(1) == quote && !certainEscapes:
+			return l.errorf(l.pos, "ambiguous backslash before quote")
+		case c == '\\' && l.pos+1 < len(l.sql):
+			if !backslashEscapes {
+				value.WriteByte(c)
+			}
+			value.WriteByte(l.sql[l.pos+1])
+			l.pos += 2
+		case c == quote && l.peek(1) == quote:
+			value.WriteByte(quote)
+			l.pos += 2
+		case c == quote:
+			l.pos++
+			l.emit(kind, value.String(), start)
+			return nil
+		default:
+			value.WriteByte(c)
+			l.pos++
+		}
+	}
+
+	return l.errorf(start, "unterminated %c quote", quote)
+}
+
+// lexDollar reads a $1 parameter or a $tag$...$tag$ string
+func (l *lexer) lexDollar(start int) error {
+	end := l.pos + 1
+	for end < len(l.sql) && l.sql[end] >= '0' && l.sql[end] <= '9' {
+		end++
+	}
+	if end > l.pos+1 {
+		l.pos = end
+		l.emit(TokenParameter, l.sql[start:end], start)
+		return nil
+	}
+
+	for end < len(l.sql) && (l.sql[end] == '_' || isASCIILetter(l.sql[end]) || l.sql[end] >= '0' && l.sql[end] <= '9') {
+		end++
+	}
+	if end >= len(l.sql) || l.sql[end] != '$' {
+		return l.errorf(start, "unexpected character '$'")
+	}
+
+	tag := l.sql[start : end+1]
+	closing := strings.Index(l.sql[end+1:], tag)
+	if closing < 0 {
+		return l.errorf(start, "unterminated dollar-quoted string")
+	}
+	l.pos = end + 1 + closing + len(tag)
+	l.emit(TokenString, l.sql[end+1:end+1+closing], start)
+	return nil
+}
+
+func (l *lexer) lexNumber(start int) {
+	for l.pos < len(l.sql) {
+		c := l.sql[l.pos]
+		switch {
+		case c >= '0' && c <= '9', c == '.', c == '_':
+			l.pos++
+		case (c == 'e' || c == 'E') && (isDigit(l.peek(1)) || (l.peek(1) == '-' || l.peek(1) == '+') && isDigit(l.peek(2))):
+			l.pos += 2
+		default:
+			l.emit(TokenNumber, l.sql[start:l.pos], start)
+			return
+		}
+	}
+	l.emit(TokenNumber, l.sql[start:l.pos], start)
+}
+
+// lexOperator reads a run of operator characters, stopping before the start of a comment
+func (l *lexer) lexOperator(start int) {
+	for l.pos < len(l.sql) && strings.IndexByte(operatorCharacters, l.sql[l.pos]) >= 0 {
+		if l.pos > start && (l.peek(0) == '-' && l.peek(1) == '-' || l.peek(0) == '/' && l.peek(1) == '*') {
+			break
+		}
+		l.pos++
+	}
+	l.emit(TokenOperator, l.sql[start:l.pos], start)
+}
+
+func (l *lexer) lexWord(start int) {
+	for l.pos < len(l.sql) {
+		r, size := utf8.DecodeRuneInString(l.sql[l.pos:])
+		if r != '_' && r != '$' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		l.pos += size
+	}
+	l.emit(TokenWord, l.sql[start:l.pos], start)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,622 @@
+package sqlparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Statement is a parsed SQL statement, reduced to the tables, columns and functions it refers
+// to. It is meant for checks that must not be fooled, so anything the parser does not
+// understand is an error or, where harmless, recorded as a possible column.
+type Statement struct {
+	// Kind is the leading keyword in upper case, such as SELECT, WITH, INSERT or UPDATE
+	Kind  string
+	Scope *Scope
+}
+
+// Scope is a query block. A name used in a scope refers to one of its tables or to a table of
+// an enclosing scope.
+type Scope struct {
+	Parent    *Scope
+	Children  []*Scope
+	Tables    []TableRef
+	Columns   []ColumnRef
+	Stars     []StarRef
+	Functions []string
+	// CTEs are the names defined by WITH, which hide tables of the same name
+	CTEs []string
+	// Exists is set for EXISTS subqueries, whose select list is never read
+	Exists bool
+	// Renamed is set for subqueries used as values and CTEs with column names, whose result
+	// columns are read under other names than the ones selected
+	Renamed bool
+}
+
+type TableRef struct {
+	Schema string
+	Name   string
+	Alias  string
+	// Start and End are the byte offsets of the name, schema included, in the SQL text
+	Start int
+	End   int
+	// Derived is set for subqueries, CTEs and table functions, whose own references are
+	// recorded in their scope
+	Derived bool
+	// ColumnAliases is set if the columns are renamed, as in AS t(a, b)
+	ColumnAliases bool
+}
+
+// ColumnRef is a name used as a column. Table is the qualifier it was written with, if any.
+type ColumnRef struct {
+	Table string
+	Name  string
+	// Output is set for references in a select list or in the arguments of a table function,
+	// whose values may end up in the result
+	Output bool
+	// Bare is set for a whole item of a select list without an alias, whose result column is
+	// named after it. Items after UNION, INTERSECT or EXCEPT are named after the first query.
+	Bare bool
+}
+
+// StarRef is a * selecting every column, of the qualifying table if Table is set. Output and
+// Bare are as in ColumnRef.
+type StarRef struct {
+	Table  string
+	Output bool
+	Bare   bool
+}
+
+// Walk calls fn for the scope and every scope nested in it
+func (s *Scope) Walk(fn func(scope *Scope)) {
+	fn(s)
+	for _, child := range s.Children {
+		child.Walk(fn)
+	}
+}
+
+// VisibleTables returns the tables of the scope and of the enclosing scopes
+func (s *Scope) VisibleTables() []TableRef {
+	var tables []TableRef
+	for scope := s; scope != nil; scope = scope.Parent {
+		tables = append(tables, scope.Tables...)
+	}
+	return tables
+}
+
+// Lookup returns the visible tables a qualifier may refer to. Every match is returned, also
+// the ones hidden by a nearer scope, so callers err on the safe side.
+func (s *Scope) Lookup(qualifier string) []TableRef {
+	var tables []TableRef
+	for _, table := range s.VisibleTables() {
+		if strings.EqualFold(table.Alias, qualifier) || table.Alias == "" && strings.EqualFold(table.Name, qualifier) {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+func (s *Scope) newChild() *Scope {
+	child := &Scope{Parent: s}
+	s.Children = append(s.Children, child)
+	return child
+}
+
+func (s *Scope) isCTE(name string) bool {
+	for scope := s; scope != nil; scope = scope.Parent {
+		for _, cte := range scope.CTEs {
+			if strings.EqualFold(cte, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reservedWords are never used as columns or aliases
+var reservedWords = toSet(
+	"ALL", "AND", "ANY", "ARRAY", "AS", "ASC", "BETWEEN", "BY", "CASE", "CAST", "COLLATE", "CROSS",
+	"DEFAULT", "DELETE", "DESC", "DISTINCT", "ELSE", "END", "ESCAPE", "EXCEPT", "EXISTS", "FALSE",
+	"FETCH", "FOR", "FROM", "FULL", "GROUP", "HAVING", "ILIKE", "IN", "INNER", "INSERT", "INTERSECT",
+	"INTO", "IS", "JOIN", "LATERAL", "LEFT", "LIKE", "LIMIT", "MINUS", "NATURAL", "NOT", "NULL",
+	"OFFSET", "ON", "ONLY", "OR", "ORDER", "OUTER", "PARTITION", "RETURNING", "RIGHT", "SELECT",
+	"SET", "SIMILAR", "SOME", "STRAIGHT_JOIN", "TABLE", "TABLESAMPLE", "THEN", "TRUE", "UNION",
+	"UPDATE", "USING", "VALUES", "WHEN", "WHERE", "WINDOW", "WITH",
+)
+
+// typedLiteralWords start a literal when followed by a string, as in DATE '2024-01-01'
+var typedLiteralWords = toSet("DATE", "TIME", "TIMESTAMP", "INTERVAL")
+
+var joinWords = toSet("JOIN", "INNER", "LEFT", "RIGHT", "FULL", "OUTER", "CROSS", "NATURAL", "STRAIGHT_JOIN")
+
+// starWords may be followed by a * selecting every column
+var starWords = toSet("SELECT", "DISTINCT", "ALL", "RETURNING")
+
+// selectListEndWords end the select list of a query block
+var selectListEndWords = toSet(
+	"FROM", "INTO", "WHERE", "GROUP", "HAVING", "WINDOW", "ORDER", "LIMIT", "OFFSET", "FETCH", "FOR",
+	"UNION", "INTERSECT", "EXCEPT", "MINUS",
+)
+
+var setOperationWords = toSet("UNION", "INTERSECT", "EXCEPT", "MINUS")
+
+func toSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+func isWordIn(token Token, set map[string]bool) bool {
+	return token.Kind == TokenWord && set[strings.ToUpper(token.Value)]
+}
+
+// tokenEOF is returned when reading past the last token
+const tokenEOF TokenKind = -1
+
+// Parse splits the SQL text into statements and records what each of them refers to
+func Parse(sql string, dialect Dialect) ([]Statement, error) {
+	tokens, err := Tokenize(sql, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{tokens: tokens}
+	var statements []Statement
+	for p.peek(0).Kind != tokenEOF {
+		if p.peek(0).is(";") {
+			p.pos++
+			continue
+		}
+
+		statement := Statement{Kind: p.statementKind(), Scope: &Scope{}}
+		if err := p.parseQuery(statement.Scope); err != nil {
+			return nil, err
+		}
+		if p.peek(0).is(")") {
+			return nil, p.errorf("unbalanced parenthesis")
+		}
+		statements = append(statements, statement)
+	}
+
+	return statements, nil
+}
+
+type parser struct {
+	tokens []Token
+	pos    int
+	// output is set while parsing references whose values may end up in the result
+	output bool
+}
+
+func (p *parser) peek(offset int) Token {
+	if p.pos+offset >= len(p.tokens) {
+		return Token{Kind: tokenEOF}
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	token := p.peek(0)
+	if token.Kind == tokenEOF {
+		return fmt.Errorf("end of query: %s", fmt.Sprintf(format, args...))
+	}
+	return fmt.Errorf("position %d: %s", token.Pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) expect(value string) error {
+	if !p.peek(0).is(value) {
+		return p.errorf("expected %s", value)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) statementKind() string {
+	for i := p.pos; i < len(p.tokens); i++ {
+		if !p.tokens[i].is("(") {
+			return strings.ToUpper(p.tokens[i].Value)
+		}
+	}
+	return ""
+}
+
+// atQueryEnd reports whether the token ends the query block being parsed
+func atQueryEnd(token Token) bool {
+	return token.Kind == tokenEOF || token.is(")") || token.is(";")
+}
+
+// isSubqueryStart reports whether the token at offset starts a query inside parentheses
+func (p *parser) isSubqueryStart(offset int) bool {
+	for p.peek(offset).is("(") {
+		offset++
+	}
+	token := p.peek(offset)
+	return token.is("SELECT") || token.is("WITH") || token.is("VALUES") || token.is("TABLE")
+}
+
+// endsSelectItem reports whether the token ends an item of a select list
+func endsSelectItem(token Token) bool {
+	return atQueryEnd(token) || token.is(",") || isWordIn(token, selectListEndWords)
+}
+
+// parseQuery parses a query block up to the closing parenthesis or the end of the statement
+func (p *parser) parseQuery(scope *Scope) error {
+	output := p.output
+	defer func() { p.output = output }()
+
+	atStart := true
+	starAllowed := false
+	inSelectList := false
+	afterSetOperation := false
+	for !atQueryEnd(p.peek(0)) {
+		token := p.peek(0)
+		switch {
+		case token.is("SELECT"):
+			inSelectList = true
+		case isWordIn(token, selectListEndWords):
+			inSelectList = false
+			afterSetOperation = afterSetOperation || isWordIn(token, setOperationWords)
+		}
+		p.output = inSelectList
+		itemStart := inSelectList && starAllowed && !afterSetOperation
+
+		switch {
+		case atStart && token.is("WITH"):
+			p.pos++
+			if err := p.parseWith(scope); err != nil {
+				return err
+			}
+			continue
+		case token.is("FROM") || isWordIn(token, joinWords) && !isFunctionCall(token, p.peek(1)):
+			p.pos++
+			for isWordIn(p.peek(0), joinWords) {
+				p.pos++
+			}
+			if err := p.parseTableList(scope, true); err != nil {
+				return err
+			}
+		case token.is("INTO") || atStart && (token.is("UPDATE") || token.is("TABLE")):
+			p.pos++
+			if err := p.parseTableList(scope, false); err != nil {
+				return err
+			}
+		case token.is("DISTINCT") && p.peek(1).is("ON"):
+			p.pos += 2
+			if err := p.parseParenthesized(scope); err != nil {
+				return err
+			}
+		case token.is("*") && starAllowed:
+			p.pos++
+			scope.Stars = append(scope.Stars, StarRef{Output: p.output, Bare: itemStart})
+		default:
+			columns, stars, functions := len(scope.Columns), len(scope.Stars), len(scope.Functions)
+			if err := p.parseExpressionItem(scope); err != nil {
+				return err
+			}
+			if itemStart && token.isIdentifier() && endsSelectItem(p.peek(0)) && len(scope.Functions) == functions {
+				switch {
+				case len(scope.Columns) == columns+1 && len(scope.Stars) == stars:
+					scope.Columns[columns].Bare = true
+				case len(scope.Stars) == stars+1 && len(scope.Columns) == columns:
+					scope.Stars[stars].Bare = true
+				}
+			}
+		}
+
+		atStart = false
+		starAllowed = isWordIn(token, starWords) || token.is(",")
+	}
+
+	return nil
+}
+
+// isFunctionCall tells the LEFT and RIGHT string functions from joins
+func isFunctionCall(token Token, next Token) bool {
+	return (token.is("LEFT") || token.is("RIGHT")) && next.is("(")
+}
+
+// parseWith parses the common table expressions after WITH
+func (p *parser) parseWith(scope *Scope) error {
+	recursive := p.peek(0).is("RECURSIVE")
+	if recursive {
+		p.pos++
+	}
+
+	for {
+		name := p.peek(0)
+		if !name.isIdentifier() {
+			return p.errorf("expected a common table expression name")
+		}
+		p.pos++
+		// Only a recursive CTE sees its own name, in any other the name is the table it may
+		// be reading, as in WITH salaries AS (SELECT * FROM salaries)
+		if recursive {
+			scope.CTEs = append(scope.CTEs, name.Value)
+		}
+
+		renamed := p.peek(0).is("(")
+		if renamed {
+			if err := p.skipParenthesized(); err != nil {
+				return err
+			}
+		}
+		if err := p.expect("AS"); err != nil {
+			return err
+		}
+		for p.peek(0).is("NOT") || p.peek(0).is("MATERIALIZED") {
+			p.pos++
+		}
+		if err := p.expect("("); err != nil {
+			return err
+		}
+		body := scope.newChild()
+		body.Renamed = renamed
+		if err := p.parseQuery(body); err != nil {
+			return err
+		}
+		if err := p.expect(")"); err != nil {
+			return err
+		}
+		if !recursive {
+			scope.CTEs = append(scope.CTEs, name.Value)
+		}
+
+		if !p.peek(0).is(",") {
+			return nil
+		}
+		p.pos++
+	}
+}
+
+// parseTableList parses the table references of a FROM, JOIN, INTO or UPDATE clause with
+// their join conditions. Table functions are only allowed where tables are read.
+func (p *parser) parseTableList(scope *Scope, allowFunctions bool) error {
+	for {
+		if err := p.parseTableRef(scope, allowFunctions); err != nil {
+			return err
+		}
+
+	joins:
+		for {
+			token := p.peek(0)
+			switch {
+			case token.is(","):
+				p.pos++
+				break joins
+			case isWordIn(token, joinWords):
+				for isWordIn(p.peek(0), joinWords) {
+					p.pos++
+				}
+				break joins
+			case token.is("ON"):
+				p.pos++
+				if err := p.parseJoinCondition(scope); err != nil {
+					return err
+				}
+			case token.is("USING") && p.peek(1).is("("):
+				p.pos++
+				if err := p.parseParenthesized(scope); err != nil {
+					return err
+				}
+			case token.is("USING"):
+				// DELETE ... USING lists more tables
+				p.pos++
+				break joins
+			default:
+				return nil
+			}
+		}
+	}
+}
+
+func (p *parser) parseJoinCondition(scope *Scope) error {
+	for {
+		token := p.peek(0)
+		if atQueryEnd(token) || token.is(",") || isWordIn(token, joinWords) || token.is("USING") ||
+			token.is("WHERE") || token.is("GROUP") || token.is("HAVING") || token.is("ORDER") ||
+			token.is("LIMIT") || token.is("OFFSET") || token.is("FETCH") || token.is("WINDOW") ||
+			token.is("UNION") || token.is("INTERSECT") || token.is("EXCEPT") || token.is("FOR") ||
+			token.is("RETURNING") || token.is("SET") || token.is("INTO") {
+			return nil
+		}
+		if err := p.parseExpressionItem(scope); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *parser) parseTableRef(scope *Scope, allowFunctions bool) error {
+	for p.peek(0).is("LATERAL") || p.peek(0).is("ONLY") {
+		p.pos++
+	}
+
+	var table TableRef
+	token := p.peek(0)
+	switch {
+	case token.is("(") && p.isSubqueryStart(1):
+		p.pos++
+		if err := p.parseQuery(scope.newChild()); err != nil {
+			return err
+		}
+		if err := p.expect(")"); err != nil {
+			return err
+		}
+		table.Derived = true
+	case token.is("("):
+		// A parenthesized join, whose tables belong to this scope
+		p.pos++
+		if err := p.parseTableList(scope, allowFunctions); err != nil {
+			return err
+		}
+		if err := p.expect(")"); err != nil {
+			return err
+		}
+		table.Derived = true
+	case token.isIdentifier() && !isWordIn(token, reservedWords):
+		table.Start = token.Pos
+		names := p.parseName()
+		table.End = p.tokens[p.pos-1].End
+		if allowFunctions && p.peek(0).is("(") {
+			scope.Functions = append(scope.Functions, names[len(names)-1])
+			// The arguments of a table function, as in unnest(array[x]), may become its rows
+			p.output = true
+			err := p.parseParenthesized(scope)
+			p.output = false
+			if err != nil {
+				return err
+			}
+			table.Derived = true
+			break
+		}
+
+		table.Name = names[len(names)-1]
+		if len(names) > 1 {
+			table.Schema = names[len(names)-2]
+		}
+		table.Derived = len(names) == 1 && scope.isCTE(table.Name)
+	default:
+		return p.errorf("expected a table")
+	}
+
+	if p.peek(0).is("AS") {
+		p.pos++
+	}
+	if alias := p.peek(0); alias.isIdentifier() && !isWordIn(alias, reservedWords) {
+		p.pos++
+		table.Alias = alias.Value
+		if p.peek(0).is("(") {
+			table.ColumnAliases = true
+			if err := p.skipParenthesized(); err != nil {
+				return err
+			}
+		}
+	}
+
+	scope.Tables = append(scope.Tables, table)
+	return nil
+}
+
+// parseName reads a dotted name such as schema.table
+func (p *parser) parseName() []string {
+	names := []string{p.peek(0).Value}
+	p.pos++
+	for p.peek(0).is(".") && p.peek(1).isIdentifier() {
+		names = append(names, p.peek(1).Value)
+		p.pos += 2
+	}
+	return names
+}
+
+// parseParenthesized parses a subquery or a parenthesized expression list
+func (p *parser) parseParenthesized(scope *Scope) error {
+	exists := p.pos > 0 && p.tokens[p.pos-1].is("EXISTS")
+	if err := p.expect("("); err != nil {
+		return err
+	}
+
+	if p.isSubqueryStart(0) {
+		child := scope.newChild()
+		child.Exists = exists
+		child.Renamed = !exists
+		if err := p.parseQuery(child); err != nil {
+			return err
+		}
+		return p.expect(")")
+	}
+
+	for !atQueryEnd(p.peek(0)) {
+		if err := p.parseExpressionItem(scope); err != nil {
+			return err
+		}
+	}
+	return p.expect(")")
+}
+
+// skipParenthesized skips a list of names that are not references, like column aliases
+func (p *parser) skipParenthesized() error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	for depth := 1; depth > 0; p.pos++ {
+		switch token := p.peek(0); {
+		case token.Kind == tokenEOF:
+			return p.errorf("unbalanced parenthesis")
+		case token.is("("):
+			depth++
+		case token.is(")"):
+			depth--
+		}
+	}
+	return nil
+}
+
+// parseExpressionItem consumes one element of an expression: a name, a function call, a
+// parenthesized expression or any other token
+func (p *parser) parseExpressionItem(scope *Scope) error {
+	token := p.peek(0)
+	switch {
+	case token.is("("):
+		return p.parseParenthesized(scope)
+	case token.is("AS"):
+		// Aliases and the types of CAST are not references
+		p.pos++
+		if next := p.peek(0); next.isIdentifier() && !isWordIn(next, reservedWords) {
+			p.parseName()
+		}
+		return nil
+	case token.is("::"):
+		p.pos++
+		return p.skipTypeName()
+	case isWordIn(token, typedLiteralWords) && p.peek(1).Kind == TokenString:
+		p.pos += 2
+		return nil
+	case isWordIn(token, reservedWords) && !p.peek(1).is("."):
+		p.pos++
+		return nil
+	case token.isIdentifier():
+		return p.parseReference(scope)
+	default:
+		p.pos++
+		return nil
+	}
+}
+
+// parseReference parses a column, a qualified star or a function call
+func (p *parser) parseReference(scope *Scope) error {
+	names := p.parseName()
+	if p.peek(0).is(".") && p.peek(1).is("*") {
+		p.pos += 2
+		scope.Stars = append(scope.Stars, StarRef{Table: names[len(names)-1], Output: p.output})
+		return nil
+	}
+
+	if p.peek(0).is("(") {
+		scope.Functions = append(scope.Functions, names[len(names)-1])
+		return p.parseParenthesized(scope)
+	}
+
+	column := ColumnRef{Name: names[len(names)-1], Output: p.output}
+	if len(names) > 1 {
+		column.Table = names[len(names)-2]
+	}
+	scope.Columns = append(scope.Columns, column)
+	return nil
+}
+
+// skipTypeName skips the type of a :: cast, such as numeric(10, 2) or text[]
+func (p *parser) skipTypeName() error {
+	if !p.peek(0).isIdentifier() {
+		return p.errorf("expected a type name")
+	}
+	p.parseName()
+	if p.peek(0).is("(") {
+		if err := p.skipParenthesized(); err != nil {
+			return err
+		}
+	}
+	for p.peek(0).is("[") && p.peek(1).is("]") {
+		p.pos += 2
+	}
+	return nil
+}
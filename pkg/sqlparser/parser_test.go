@@ -0,0 +1,162 @@
+package sqlparser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	sql := `
+		WITH recent AS (SELECT * FROM orders WHERE created_at > now() - interval '1 day')
+		SELECT u.nickname, LEFT(u.email, 3), count(*) * 2 AS total, extract(year FROM r.created_at)::int
+		FROM public.user_account u
+		LEFT JOIN recent r ON r.user_id = u.id
+		WHERE EXISTS (SELECT * FROM payments p WHERE p.user_id = u.id)
+		GROUP BY 1, 2; SELECT 'a;b'`
+	statements, err := Parse(sql, Postgres)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(statements) != 2 || statements[0].Kind != "WITH" || statements[1].Kind != "SELECT" {
+		t.Fatalf("unexpected statements: %+v", statements)
+	}
+
+	root := statements[0].Scope
+	userAccount := strings.Index(sql, "public.user_account")
+	recent := strings.Index(sql, "recent r")
+	expectedTables := []TableRef{
+		{Schema: "public", Name: "user_account", Alias: "u", Start: userAccount, End: userAccount + len("public.user_account")},
+		{Name: "recent", Alias: "r", Start: recent, End: recent + len("recent"), Derived: true},
+	}
+	if !slices.Equal(root.Tables, expectedTables) {
+		t.Fatalf("unexpected tables: %+v", root.Tables)
+	}
+	if len(root.Stars) != 0 {
+		t.Fatalf("count(*) and multiplication are not stars: %+v", root.Stars)
+	}
+	for _, column := range []ColumnRef{
+		{Table: "u", Name: "nickname", Output: true, Bare: true},
+		{Table: "u", Name: "email", Output: true},
+		{Table: "r", Name: "user_id"},
+	} {
+		if !slices.Contains(root.Columns, column) {
+			t.Fatalf("missing column %+v in %+v", column, root.Columns)
+		}
+	}
+	if slices.Contains(root.Columns, ColumnRef{Name: "total"}) || slices.Contains(root.Columns, ColumnRef{Name: "int"}) {
+		t.Fatalf("aliases and types are not columns: %+v", root.Columns)
+	}
+
+	if len(root.Children) != 2 {
+		t.Fatalf("expected the CTE and the EXISTS subquery, got %d scopes", len(root.Children))
+	}
+	exists := root.Children[1]
+	if !exists.Exists || len(exists.Stars) != 1 || exists.Lookup("u")[0].Name != "user_account" {
+		t.Fatalf("unexpected EXISTS scope: %+v", exists)
+	}
+}
+
+func TestParse_WholeRowAndQualifiedStar(t *testing.T) {
+	statements, err := Parse("SELECT row_to_json(u), a.* FROM user_account u, accounts a", Postgres)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	scope := statements[0].Scope
+	if !slices.Contains(scope.Columns, ColumnRef{Name: "u", Output: true}) || !slices.Equal(scope.Stars, []StarRef{{Table: "a", Output: true, Bare: true}}) {
+		t.Fatalf("unexpected references: %+v %+v", scope.Columns, scope.Stars)
+	}
+}
+
+func TestParse_OutputNames(t *testing.T) {
+	sql := `SELECT card_number, c.phone AS p, lower(email), (SELECT max(id) FROM t) FROM cards c
+		WHERE national_id = '1' UNION SELECT nickname, a, b, c FROM people`
+	statements, err := Parse(sql, Postgres)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	scope := statements[0].Scope
+	expected := []ColumnRef{
+		{Name: "card_number", Output: true, Bare: true},
+		{Table: "c", Name: "phone", Output: true},
+		{Name: "email", Output: true},
+		{Name: "national_id"},
+		// Named after the first query
+		{Name: "nickname", Output: true},
+	}
+	for _, column := range expected {
+		if !slices.Contains(scope.Columns, column) {
+			t.Fatalf("missing column %+v in %+v", column, scope.Columns)
+		}
+	}
+	if len(scope.Children) != 1 || !scope.Children[0].Renamed {
+		t.Fatalf("expected the scalar subquery to be renamed: %+v", scope.Children)
+	}
+
+	statements, err = Parse("WITH x(n) AS (SELECT card_number FROM cards) SELECT n FROM x, unnest(array[phone]) AS u(p)", Postgres)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	scope = statements[0].Scope
+	if !scope.Children[0].Renamed || !scope.Tables[1].ColumnAliases || !slices.Contains(scope.Columns, ColumnRef{Name: "phone", Output: true}) {
+		t.Fatalf("expected the CTE and the table function to rename columns: %+v", scope)
+	}
+}
+
+func TestTokenize_Dialects(t *testing.T) {
+	// A backslash ends a standard PostgreSQL string but escapes the quote in MySQL
+	tokens, err := Tokenize(`SELECT E'it\'s', 'a\b', "col", $$body$$, $1`, Postgres)
+	if err != nil {
+		t.Fatalf("Tokenize: %v", err)
+	}
+	values := []string{"SELECT", "it's", ",", `a\b`, ",", "col", ",", "body", ",", "$1"}
+	for i, token := range tokens {
+		if token.Value != values[i] {
+			t.Fatalf("token %d is %q, expected %q", i, token.Value, values[i])
+		}
+	}
+
+	tokens, err = Tokenize("SELECT `col` /*!50000 , secret */ # comment", MySQL)
+	if err != nil {
+		t.Fatalf("Tokenize: %v", err)
+	}
+	if len(tokens) != 4 || tokens[3].Value != "secret" {
+		t.Fatalf("executable comments must be lexed: %+v", tokens)
+	}
+
+	for _, sql := range []string{`SELECT U&"account\005Fnumber" FROM t`, `SELECT U&'\0061'`, `SELECT * FROM u&"salarie\0073"`} {
+		if _, err := Tokenize(sql, Postgres); err == nil {
+			t.Fatalf("Tokenize(%q) should fail", sql)
+		}
+	}
+
+	for _, sql := range []string{`SELECT 'a\' , secret`, `SELECT 'open`, `SELECT /* open`, `SELECT "a\" , secret"`} {
+		if _, err := Tokenize(sql, MySQL); err == nil {
+			t.Fatalf("Tokenize(%q) should fail", sql)
+		}
+	}
+}
+
+func TestParse_CTENameInItsBody(t *testing.T) {
+	// Without RECURSIVE the name in the body is the table, with it the CTE itself
+	for sql, derived := range map[string]bool{
+		"WITH salaries AS (SELECT * FROM salaries) SELECT * FROM salaries":                              false,
+		"WITH RECURSIVE t AS (SELECT 1 AS n UNION ALL SELECT n + 1 FROM t WHERE n < 5) SELECT n FROM t": true,
+	} {
+		statements, err := Parse(sql, Postgres)
+		if err != nil {
+			t.Fatalf("Parse: %v", err)
+		}
+
+		body := statements[0].Scope.Children[0]
+		table := body.Tables[len(body.Tables)-1]
+		if table.Derived != derived {
+			t.Fatalf("%s: expected %s in the body to be derived: %v", sql, table.Name, derived)
+		}
+		if outer := statements[0].Scope.Tables[0]; !outer.Derived {
+			t.Fatalf("%s: expected the outer %s to be the CTE", sql, outer.Name)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/AliTaghipour1/Talk-to_DB/pkg/repo"
+)
+
+// Imports the databases, descriptions, glossary, examples and feedbacks of a JSON file
+// repository into a new SQLite repository.
+//
+//	go run ./repo_migration -json pkg/repo/data.json -sqlite pkg/repo/data.db
+func main() {
+	jsonPath := flag.String("json", "pkg/repo/data.json", "path of the JSON file repository to import")
+	sqlitePath := flag.String("sqlite", "pkg/repo/data.db", "path of the SQLite repository to create")
+	flag.Parse()
+
+	if _, err := os.Stat(*jsonPath); err != nil {
+		log.Fatalf("failed to read JSON repository: %v", err)
+	}
+
+	source, err := repo.NewDatabaseRepoMapImpl(*jsonPath, repo.MapRepoOptions{})
+	if err != nil {
+		log.Fatalf("failed to read JSON repository: %v", err)
+	}
+	databases, err := source.GetAllDatabases()
+	if err != nil {
+		log.Fatalf("failed to read JSON repository: %v", err)
+	}
+
+	target, err := repo.NewDatabaseRepoSqliteImpl(*sqlitePath)
+	if err != nil {
+		log.Fatalf("failed to open SQLite repository: %v", err)
+	}
+	defer target.Close()
+
+	if err := target.Import(source); err != nil {
+		log.Fatalf("failed to import repository: %v", err)
+	}
+
+	log.Printf("imported %d databases from %s into %s", len(databases), *jsonPath, *sqlitePath)
+}